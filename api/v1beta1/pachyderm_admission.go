@@ -0,0 +1,76 @@
+/*
+Copyright 2021 Pachyderm.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// pachydermValidator validates pachyderm resources like the
+// webhook.Validator handler, and adds deprecation warnings
+// to the admission response
+type pachydermValidator struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &pachydermValidator{}
+
+// InjectDecoder injects the decoder into the validator
+func (v *pachydermValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle validates the pachyderm resource in the admission request
+func (v *pachydermValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pd := &Pachyderm{}
+
+	switch req.Operation {
+	case admissionv1.Create:
+		if err := v.decoder.Decode(req, pd); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := pd.ValidateCreate(); err != nil {
+			return admission.Denied(err.Error())
+		}
+	case admissionv1.Update:
+		old := &Pachyderm{}
+		if err := v.decoder.DecodeRaw(req.Object, pd); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := pd.ValidateUpdate(old); err != nil {
+			return admission.Denied(err.Error())
+		}
+	case admissionv1.Delete:
+		if err := v.decoder.DecodeRaw(req.OldObject, pd); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := pd.ValidateDelete(); err != nil {
+			return admission.Denied(err.Error())
+		}
+		return admission.Allowed("")
+	}
+
+	return admission.Allowed("").WithWarnings(pd.Warnings()...)
+}
@@ -32,10 +32,25 @@ const (
 	GoogleStorageBackend string = "GOOGLE"
 	// Minio storage backend for pachd
 	MinioStorageBackend string = "MINIO"
+	// Generic S3-compatible storage backend for pachd
+	CustomStorageBackend string = "CUSTOM"
+	// Host path storage backend for development clusters
+	LocalStorageBackend string = "LOCAL"
 	// Pachyderm Pause Annotation
 	PachydermPauseAnnotation string = "operator.pachyderm.com/pause-cluster"
 	// Pachd Pod Count Annotation
 	PachdPodCountAnnotation string = "operator.pachyderm.com/pachd-podcount"
+	// Name of the pachyderm export that paused the cluster
+	PachydermPausedByAnnotation string = "operator.pachyderm.com/paused-by"
+	// Prefix of the paused-by annotation set by a pachyderm storage
+	// migration. Export names cannot contain a slash
+	StorageMigrationPausePrefix string = "pachydermstoragemigration/"
+	// Digest of the credentials consumed by pachd.
+	// Set on the pachd pod template to restart pachd when they change
+	PachdConfigHashAnnotation string = "operator.pachyderm.com/config-hash"
+	// Requests the operator to rotate the password of the pachd
+	// database user. Removed by the operator once rotated
+	RotatePostgresPasswordAnnotation string = "operator.pachyderm.com/rotate-postgres-password"
 )
 
 // PachydermSpec defines the desired state of Pachyderm
@@ -53,6 +68,8 @@ type PachydermSpec struct {
 	Worker WorkerOptions `json:"worker,omitempty"`
 	// Allows user to customize Postgresql database
 	Postgres PostgresOptions `json:"postgresql,omitempty"`
+	// Allows user to customize the pg-bouncer connection pooler
+	PGBouncer PGBouncerOptions `json:"pgBouncer,omitempty"`
 	// Allow user to provide an image pull secret
 	ImagePullSecret *string `json:"imagePullSecret,omitempty"`
 	// License for pachyderm enterprise.
@@ -139,7 +156,10 @@ type ServiceOverrides struct {
 // EtcdOptions allows users to change the etcd statefulset
 type EtcdOptions struct {
 	// Optional parameter to set the number of nodes in the Etcd statefulset.
-	// Analogous --dynamic-etcd-nodes argument to 'pachctl deploy'
+	// Analogous --dynamic-etcd-nodes argument to 'pachctl deploy'.
+	// Must be an odd number. Changing it adds or removes etcd
+	// members one at a time, and the cluster can not be scaled
+	// below the quorum of its current size in a single change
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Dynamic Nodes",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	DynamicNodes int32 `json:"dynamicNodes,omitempty"`
 	// Optional image overrides.
@@ -153,12 +173,91 @@ type EtcdOptions struct {
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Storage Class",xDescriptors={"urn:alm:descriptor:io.kubernetes:StorageClass","urn:alm:descriptor:io.kubernetes:custom"}
 	StorageClass string `json:"storageClass,omitempty"`
 	// The size of the storage to use for etcd.
+	// Increasing it expands the existing volumes when
+	// the storage class allows volume expansion.
 	// For example: "100Gi"
 	//+kubebuilder:default:="10Gi"
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Storage Size",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
 	StorageSize string `json:"storageSize,omitempty"`
 	// Service overrides
 	Service *ServiceOverrides `json:"service,omitempty"`
+	// Periodic compaction and defragmentation of the etcd database
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Maintenance"
+	Maintenance EtcdMaintenanceOptions `json:"maintenance,omitempty"`
+	// Encrypts the etcd peer and client traffic.
+	// Can only be set when the pachyderm resource is created
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="TLS",xDescriptors={"urn:alm:descriptor:io.kubernetes:advanced"}
+	TLS *EtcdTLSOptions `json:"tls,omitempty"`
+}
+
+const (
+	// EtcdTLSOperatorSource certificates are issued and rotated by the operator
+	EtcdTLSOperatorSource string = "Operator"
+	// EtcdTLSSecretSource certificates are read from existing secrets
+	EtcdTLSSecretSource string = "Secret"
+	// EtcdTLSServiceCASource certificates are issued by the OpenShift service CA
+	EtcdTLSServiceCASource string = "ServiceCA"
+	// EtcdServiceCABundleName is the config map the OpenShift
+	// service CA injects its certificate authority into
+	EtcdServiceCABundleName string = "etcd-ca-bundle"
+)
+
+// EtcdTLSOptions configures the certificates used
+// by the etcd members and their clients
+type EtcdTLSOptions struct {
+	// Source of the certificates. Operator issues a certificate
+	// authority and the server, peer and client certificates and
+	// replaces them before they expire. Secret reads them from the
+	// server, peer and client secrets. ServiceCA uses a serving
+	// certificate issued by the OpenShift service CA, which does
+	// not authenticate clients
+	//+kubebuilder:validation:Enum:=Operator;Secret;ServiceCA
+	//+kubebuilder:default:=Operator
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Source",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:Operator","urn:alm:descriptor:com.tectonic.ui:select:Secret","urn:alm:descriptor:com.tectonic.ui:select:ServiceCA"}
+	Source string `json:"source,omitempty"`
+	// Name of a kubernetes.io/tls secret with the certificate served
+	// to etcd clients and the certificate authority under the key ca.crt
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Server Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret","urn:alm:descriptor:io.kubernetes:advanced"}
+	ServerSecretName string `json:"serverSecret,omitempty"`
+	// Name of a kubernetes.io/tls secret with the certificate used
+	// between etcd members and the certificate authority under the key ca.crt
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Peer Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret","urn:alm:descriptor:io.kubernetes:advanced"}
+	PeerSecretName string `json:"peerSecret,omitempty"`
+	// Name of a kubernetes.io/tls secret with the certificate pachd and
+	// the operator present to etcd and the certificate authority under the key ca.crt
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Client Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret","urn:alm:descriptor:io.kubernetes:advanced"}
+	ClientSecretName string `json:"clientSecret,omitempty"`
+	// Validity of the certificates issued by the operator. Defaults to 8760h
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Validity",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	Validity *metav1.Duration `json:"validity,omitempty"`
+	// Time before expiry the certificates issued by
+	// the operator are replaced. Defaults to 720h
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Renew Before",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+	// Certificate authorities trusted by the etcd members and clients
+	CABundleData []byte `json:"-"`
+	// Client certificate used by the operator to connect to etcd
+	ClientCertificate []byte `json:"-"`
+	// Private key of the client certificate
+	ClientKey []byte `json:"-"`
+}
+
+// EtcdMaintenanceOptions schedules the compaction and
+// defragmentation of the etcd database, keeping it
+// below the space quota of the etcd members
+type EtcdMaintenanceOptions struct {
+	// If true, the operator does not compact or defragment etcd
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Disable",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Disable bool `json:"disable,omitempty"`
+	// Time between maintenance runs. Defaults to 24h
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Interval",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Percentage of the database file that must be unused
+	// before a member is defragmented. Defaults to 20
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=100
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Defragment Threshold",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:io.kubernetes:advanced"}
+	DefragmentThreshold *int32 `json:"defragmentThreshold,omitempty"`
 }
 
 // PachdOptions allows the user to customize pachd
@@ -204,6 +303,42 @@ type PachdOptions struct {
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
 	// Postgresql server connection credentials
 	Postgres PachdPostgresConfig `json:"postgresql,omitempty"`
+	// Serves the pachd API over TLS.
+	// Can only be set when the pachyderm resource is created
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="TLS",xDescriptors={"urn:alm:descriptor:io.kubernetes:advanced"}
+	TLS *PachdTLSOptions `json:"tls,omitempty"`
+}
+
+const (
+	// PachdTLSServiceCASource certificate is issued by the OpenShift service CA
+	PachdTLSServiceCASource string = "ServiceCA"
+	// PachdTLSSecretSource certificate is read from an existing secret
+	PachdTLSSecretSource string = "Secret"
+	// PachdServingSecretName is the secret the OpenShift
+	// service CA stores the pachd serving certificate in
+	PachdServingSecretName string = "pachd-tls"
+	// PachdServiceCABundleName is the config map the OpenShift
+	// service CA injects its certificate authority into
+	PachdServiceCABundleName string = "pachd-ca-bundle"
+)
+
+// PachdTLSOptions configures the certificate
+// served by pachd on its API port
+type PachdTLSOptions struct {
+	// Source of the certificate. ServiceCA requests a serving
+	// certificate for the pachd service from the OpenShift service CA.
+	// Secret reads the certificate from an existing secret
+	//+kubebuilder:validation:Enum:=ServiceCA;Secret
+	//+kubebuilder:default:=ServiceCA
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Source",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:ServiceCA","urn:alm:descriptor:com.tectonic.ui:select:Secret"}
+	Source string `json:"source,omitempty"`
+	// Name of a kubernetes.io/tls secret with the certificate served by
+	// pachd, valid for pachd.<namespace>.svc. The certificate authority
+	// is read from the key ca.crt, or from tls.crt if it is missing
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	SecretName string `json:"secretName,omitempty"`
+	// Certificate authorities trusted by the operator when connecting to pachd
+	CABundleData []byte `json:"-"`
 }
 
 // PostgresOptions allows user to customize Postgresql
@@ -215,10 +350,93 @@ type PostgresOptions struct {
 	// Storage class for the postgresql persistent storage
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Storage Class",xDescriptors={"urn:alm:descriptor:io.kubernetes:StorageClass","urn:alm:descriptor:io.kubernetes:custom"}
 	StorageClass string `json:"storageClass,omitempty"`
+	// The size of the storage to use for postgresql.
+	// Increasing it expands the existing volumes when
+	// the storage class allows volume expansion.
+	// For example: "100Gi"
+	//+kubebuilder:default:="10Gi"
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Storage Size",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	StorageSize string `json:"storageSize,omitempty"`
 	// Service overrides
 	Service ServiceOverrides `json:"service,omitempty"`
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Resources",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements"}
 	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Runs the embedded database as a highly available cluster
+	// managed by the Crunchy Data postgres operator, which must be
+	// installed in the cluster. Can only be set when the pachyderm
+	// resource is created
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="High Availability",xDescriptors={"urn:alm:descriptor:io.kubernetes:advanced"}
+	HighAvailability *PostgresHAOptions `json:"highAvailability,omitempty"`
+}
+
+// PostgresHAOptions configures the PostgresCluster
+// running the embedded database in high availability mode
+type PostgresHAOptions struct {
+	// Number of database instances. One instance is the primary and
+	// the others are streaming replicas promoted if the primary fails.
+	// Defaults to 2
+	//+kubebuilder:validation:Minimum:=2
+	//+kubebuilder:default:=2
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Replicas",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
+	Replicas int32 `json:"replicas,omitempty"`
+	// Major version of postgresql run by the postgres operator.
+	// Defaults to 13
+	//+kubebuilder:default:=13
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Postgres Version",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:io.kubernetes:advanced"}
+	PostgresVersion int32 `json:"postgresVersion,omitempty"`
+}
+
+// PGBouncerOptions configures the pg-bouncer connection
+// pooler placed between pachd and the database.
+// Pool settings left unset keep the defaults of the pachyderm chart
+type PGBouncerOptions struct {
+	// If true, pachd connects to the database directly
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Disable",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:io.kubernetes:advanced"}
+	Disable bool `json:"disable,omitempty"`
+	// Number of pg-bouncer pods.
+	// Defaults to 1
+	//+kubebuilder:validation:Minimum:=1
+	//+kubebuilder:default:=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Replicas",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
+	Replicas int32 `json:"replicas,omitempty"`
+	// When a server connection is returned to the pool.
+	// transaction releases it after each transaction,
+	// session when the client disconnects.
+	// Defaults to transaction
+	//+kubebuilder:validation:Enum:=session;transaction
+	//+kubebuilder:default:=transaction
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Pool Mode",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:session","urn:alm:descriptor:com.tectonic.ui:select:transaction","urn:alm:descriptor:io.kubernetes:advanced"}
+	PoolMode string `json:"poolMode,omitempty"`
+	// Maximum number of client connections accepted by each pod
+	//+kubebuilder:validation:Minimum:=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Max Client Connections",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:io.kubernetes:advanced"}
+	MaxClientConnections int32 `json:"maxClientConnections,omitempty"`
+	// Number of server connections allowed per user and database
+	//+kubebuilder:validation:Minimum:=1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Default Pool Size",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:io.kubernetes:advanced"}
+	DefaultPoolSize int32 `json:"defaultPoolSize,omitempty"`
+	// Number of server connections kept open in each pool
+	//+kubebuilder:validation:Minimum:=0
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Min Pool Size",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:io.kubernetes:advanced"}
+	MinPoolSize int32 `json:"minPoolSize,omitempty"`
+	// Number of additional server connections
+	// allowed when a pool is exhausted
+	//+kubebuilder:validation:Minimum:=0
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Reserve Pool Size",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:io.kubernetes:advanced"}
+	ReservePoolSize int32 `json:"reservePoolSize,omitempty"`
+	// Maximum number of server connections opened
+	// to each database by each pod
+	//+kubebuilder:validation:Minimum:=0
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Max Database Connections",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:io.kubernetes:advanced"}
+	MaxDBConnections int32 `json:"maxDBConnections,omitempty"`
+	// SSL mode of the connections from pg-bouncer to the database.
+	// Defaults to the SSL mode of spec.pachd.postgresql
+	//+kubebuilder:validation:Enum:=disable;allow;prefer;require;verify-ca;verify-full
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Server SSL Mode",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	ServerSSLMode string `json:"serverSSLMode,omitempty"`
+	// Optional resource requirements of the pg-bouncer pods
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Resources",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements"}
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 // PachdPostgresConfig sets up storage for pachd
@@ -232,9 +450,27 @@ type PachdPostgresConfig struct {
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Port",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:io.kubernetes:advanced"}
 	//+kubebuilder:default:=5432
 	Port int32 `json:"port,omitempty"`
+	// SSL mode used to connect to the database.
+	// Should be one of "disable", "allow", "prefer", "require", "verify-ca" or "verify-full".
+	// Defaults to disable
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="SSL",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	//+kubebuilder:validation:Enum:=disable;allow;prefer;require;verify-ca;verify-full
 	//+kubebuilder:default:=disable
 	SSL string `json:"ssl,omitempty"`
+	// Certificate authorities used to verify the database server
+	// certificate when the SSL mode is verify-ca or verify-full
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="CA Bundle",xDescriptors={"urn:alm:descriptor:io.kubernetes:advanced"}
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
+	// CA bundle read from the referenced config map or secret
+	CABundleData []byte `json:"-"`
+	// Name of a kubernetes.io/tls secret with the client certificate
+	// under the key tls.crt and its private key under the key tls.key
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Client Certificate Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret","urn:alm:descriptor:io.kubernetes:advanced"}
+	ClientCertificateSecretName string `json:"clientCertificateSecret,omitempty"`
+	// Client certificate read from the client certificate secret
+	ClientCertificate []byte `json:"-"`
+	// Client private key read from the client certificate secret
+	ClientKey []byte `json:"-"`
 	// Username to use to connect to the database.
 	// Defaults to pachyderm
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="User",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
@@ -245,14 +481,48 @@ type PachdPostgresConfig struct {
 	// read from the secret.
 	// Field is not visible to the user
 	Password string `json:"-"`
+	// Database role pachd and pg-bouncer log in as, read from the
+	// postgres secret. Password rotation alternates between the user
+	// and its rotation role so the previous password stays valid until
+	// the pods using it are replaced. Field is not visible to the user
+	LoginUser string `json:"-"`
 	// Name of the kubernetes secret containing the database password.
 	// Password should have the key postgres-password
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Password Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret","urn:alm:descriptor:io.kubernetes:advanced"}
 	PasswordSecretName string `json:"passwordSecret,omitempty"`
+	// Periodically replaces the password of the pachd database user.
+	// A rotation can also be requested at any time with the
+	// operator.pachyderm.com/rotate-postgres-password annotation
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Password Rotation",xDescriptors={"urn:alm:descriptor:io.kubernetes:advanced"}
+	PasswordRotation *PasswordRotationOptions `json:"passwordRotation,omitempty"`
 	// Name of the database into which the table schemas will live
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Database",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
 	//+kubebuilder:default:=pachyderm
 	Database string `json:"database,omitempty"`
+	// Initialization selects how the operator prepares the database.
+	// Create connects as the postgres superuser to create the databases
+	// and apply the init scripts. Verify connects as the pachd user and
+	// only checks the databases, role and grants exist, for managed
+	// databases where superuser access is not available.
+	// Defaults to Create
+	//+kubebuilder:validation:Enum:=Create;Verify
+	//+kubebuilder:default:=Create
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Initialization",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:Create","urn:alm:descriptor:com.tectonic.ui:select:Verify","urn:alm:descriptor:io.kubernetes:advanced"}
+	Initialization string `json:"initialization,omitempty"`
+	// Name of a config map with additional SQL scripts applied to the
+	// database after the built-in scripts. Keys ending in .sql are
+	// applied in lexical order and applied again when their content changes
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Init Scripts Config Map",xDescriptors={"urn:alm:descriptor:io.kubernetes:ConfigMap","urn:alm:descriptor:io.kubernetes:advanced"}
+	InitScriptsConfigMap string `json:"initScriptsConfigMap,omitempty"`
+}
+
+// PasswordRotationOptions configures the rotation
+// of the password of the pachd database user
+type PasswordRotationOptions struct {
+	// Time between rotations, e.g. 720h.
+	// When not set, the password is only rotated on request
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Interval",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	Interval *metav1.Duration `json:"interval,omitempty"`
 }
 
 // MetricsOptions allows the user to enable/disable pachyderm metrics
@@ -281,9 +551,9 @@ type ObjectStorageOptions struct {
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Upload File Concurrency Limit",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
 	UploadFileConcurrencyLimit int32 `json:"uploadFileConcurrencyLimit,omitempty"`
 	// Sets the type of storage backend.
-	// Should be one of "GOOGLE", "AMAZON", "MINIO" or "MICROSOFT"
+	// Should be one of "GOOGLE", "AMAZON", "MINIO", "MICROSOFT", "CUSTOM" or "LOCAL"
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Backend",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
-	//+kubebuilder:validation:Enum:=AMAZON;MINIO;MICROSOFT;GOOGLE
+	//+kubebuilder:validation:Enum:=AMAZON;MINIO;MICROSOFT;GOOGLE;CUSTOM;LOCAL
 	Backend string `json:"backend"`
 	// Configures the Amazon storage backend
 	Amazon *AmazonStorageOptions `json:"amazon,omitempty"`
@@ -293,6 +563,62 @@ type ObjectStorageOptions struct {
 	Microsoft *MicrosoftStorageOptions `json:"microsoft,omitempty"`
 	// Configures Minio object store
 	Minio *MinioStorageOptions `json:"minio,omitempty"`
+	// Configures a generic S3-compatible object store
+	Custom *CustomStorageOptions `json:"custom,omitempty"`
+	// Configures local storage for development clusters
+	Local *LocalStorageOptions `json:"local,omitempty"`
+}
+
+// CustomStorageOptions exposes options to configure
+// a generic S3-compatible object store
+type CustomStorageOptions struct {
+	// Name of the bucket to store pachd objects
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Bucket",xDescriptors={"urn:alm:descriptor:text"}
+	Bucket string `json:"bucket"`
+	// The hostname and port of the object store.
+	// Example: "s3.storage.example.com:443"
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Endpoint",xDescriptors={"urn:alm:descriptor:text"}
+	Endpoint string `json:"endpoint"`
+	// Region of the bucket.
+	// Defaults to us-east-1
+	//+kubebuilder:default:=us-east-1
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Region",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	Region string `json:"region,omitempty"`
+	// Name of secret with the access key ID under the key
+	// access-id and the secret key under the key access-secret
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credential Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	CredentialSecretName string `json:"credentialSecretName"`
+	// Access key ID read from the credential secret
+	ID string `json:"-"`
+	// Secret access key read from the credential secret
+	Secret string `json:"-"`
+	// If true, pachd connects to the object store without TLS
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Disable SSL",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:io.kubernetes:advanced"}
+	DisableSSL bool `json:"disableSSL,omitempty"`
+	// If true, pachd does not verify the certificate of the object store
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Insecure Skip Verify",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:io.kubernetes:advanced"}
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// Certificate authorities trusted by pachd and workers
+	// when connecting to an object store using a private CA
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="CA Bundle"
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
+	// Contents of the CA bundle resolved by the operator
+	CABundleData []byte `json:"-"`
+}
+
+// LocalStorageOptions exposes options to store pachd objects on
+// the node. Only intended for single node development and CI clusters
+type LocalStorageOptions struct {
+	// Path on the node where pachd and workers store objects.
+	// Must end in /. Defaults to /var/pachyderm/
+	//+kubebuilder:default:=/var/pachyderm/
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Host Path",xDescriptors={"urn:alm:descriptor:text"}
+	HostPath string `json:"hostPath,omitempty"`
+	// Name of a persistent volume claim mounted by pachd instead of
+	// the host path. Pipeline workers always mount the host path, so
+	// the claim must be bound to a volume at the same path on the node
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Claim Name",xDescriptors={"urn:alm:descriptor:io.kubernetes:PersistentVolumeClaim"}
+	ClaimName string `json:"claimName,omitempty"`
 }
 
 // GoogleStorageOptions exposes options to configure Google Cloud Storage
@@ -300,12 +626,15 @@ type GoogleStorageOptions struct {
 	// Name of GCS bucket to hold objects
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Bucket",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Bucket string `json:"bucket,omitempty"`
-	// Credentials json file
+	// Name of secret with the service account key under the key credentials.json.
+	// Can not be used together with serviceAccountName
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credential Secret",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:io.kubernetes:custom"}
 	CredentialSecret string `json:"credentialSecret,omitempty"`
 	// Contents of the "credentials.json" key from the CredentialSecret
 	CredentialsData []byte `json:"-"`
-	// ServiceAccount used for Google container storage
+	// Email of the Google service account impersonated by the pachd and
+	// worker service accounts using GKE workload identity.
+	// Example: "pachyderm@my-project.iam.gserviceaccount.com"
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Service Account Name",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:io.kubernetes:custom"}
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
 }
@@ -313,8 +642,10 @@ type GoogleStorageOptions struct {
 // AmazonStorageOptions exposes options to
 // configure Amazon s3 storage
 type AmazonStorageOptions struct {
-	// Name of the S3 bucket to hold objects
-	Bucket string `json:"-"`
+	// Name of the S3 bucket to hold objects.
+	// Overridden by the bucket key of the credential secret
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Bucket",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:io.kubernetes:custom"}
+	Bucket string `json:"bucket,omitempty"`
 	// CloudFrontDistribution sets the CloudFront distribution in the storage secrets.
 	// It is analogous to the --cloudfront-distribution argument to pachctl deploy.
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="CloudFront Distribution",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:io.kubernetes:custom"}
@@ -325,7 +656,9 @@ type AmazonStorageOptions struct {
 	// DisableSSL disables SSL.  It is analogous to the --disable-ssl
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Disable SSL",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:com.tectonic.ui:custom"}
 	DisableSSL bool `json:"disableSSL,omitempty"`
-	// IAM identity with the desired permissions
+	// ARN of the IAM role assumed by pachd and workers using
+	// a projected service account token. When set, static
+	// access keys are not read from the credential secret
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="IAM Role",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:io.kubernetes:custom"}
 	IAMRole string `json:"iamRole,omitempty"`
 	// The access ID for the AWS S3 storage solution
@@ -337,16 +670,20 @@ type AmazonStorageOptions struct {
 	// Default: 10000
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Max Upload Parts",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
 	MaxUploadParts int `json:"maxUploadParts,omitempty" default:"10000"`
-	// Skip SSL certificate verification.
-	// Typically used for enabling self-signed certificates
+	// Verify the SSL certificate of the object store.
+	// Set to false to accept self-signed certificates.
+	// Default: true
+	//+kubebuilder:default:=true
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Verify SSL",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:com.tectonic.ui:custom"}
-	VerifySSL bool `json:"verifySSL,omitempty"`
+	VerifySSL *bool `json:"verifySSL,omitempty" default:"true"`
 	// Set a custom part size for object storage uploads.
 	// Default: 5242880
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Part Size",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
 	PartSize int64 `json:"partSize,omitempty" default:"5242880"`
-	// Region for the object storage cluster
-	Region string `json:"-"`
+	// Region for the object storage cluster.
+	// Overridden by the region key of the credential secret
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Region",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text","urn:alm:descriptor:io.kubernetes:custom"}
+	Region string `json:"region,omitempty"`
 	// Set a custom number of retries for object storage requests.
 	// Default: 10
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Retries",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
@@ -370,20 +707,65 @@ type AmazonStorageOptions struct {
 	UploadACL string `json:"uploadACL,omitempty" default:"bucket-owner-full-control"`
 	// Container for storing archives
 	Vault *AmazonStorageVault `json:"vault,omitempty"`
-	// The name of the secret containing the credentials to the S3 storage
+	// The name of the secret containing the credentials to the S3 storage.
+	// Optional when an IAM role is set
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="S3 Credentials Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
 	CredentialSecretName string `json:"credentialSecretName,omitempty" default:"pachyderm-aws-secret"`
 }
 
-// AmazonStorageVault exposes options to configure
-// Amazon vault
+// AmazonStorageVault exposes options to retrieve dynamic
+// S3 credentials from the HashiCorp Vault AWS secrets engine
 type AmazonStorageVault struct {
+	// Address of the vault server.
+	// Example: "https://vault.vault.svc:8200"
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Address",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Address string `json:"address,omitempty"`
+	// Name of the role in the AWS secrets engine
+	// used to generate credentials for pachd
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Role",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Role string `json:"role,omitempty"`
+	// Path the AWS secrets engine is mounted at.
+	// Defaults to aws
+	//+kubebuilder:default:=aws
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="AWS Mount Path",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	AWSMountPath string `json:"awsMountPath,omitempty"`
+	// Vault token used to authenticate with vault.
+	// Deprecated: use tokenSecretName or kubernetes instead
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Token",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Token string `json:"token,omitempty"`
+	// Name of secret holding a vault token under the key token
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Token Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+	// Authenticate with the vault kubernetes auth method
+	// using a token issued to the pachd service account
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Kubernetes Auth"
+	Kubernetes *VaultKubernetesAuth `json:"kubernetes,omitempty"`
+	// Certificate authorities used to verify the vault
+	// server certificate when it is issued by a private CA
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="CA Bundle",xDescriptors={"urn:alm:descriptor:io.kubernetes:advanced"}
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
+	// CA bundle read from the referenced config map or secret
+	CABundleData []byte `json:"-"`
+	// Server name expected in the vault server certificate.
+	// Defaults to the host of the address
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="TLS Server Name",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	TLSServerName string `json:"tlsServerName,omitempty"`
+}
+
+// VaultKubernetesAuth configures the vault kubernetes auth method
+type VaultKubernetesAuth struct {
+	// Name of the vault role bound to the pachd service account
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Role",xDescriptors={"urn:alm:descriptor:text"}
+	Role string `json:"role"`
+	// Path the kubernetes auth method is mounted at.
+	// Defaults to kubernetes
+	//+kubebuilder:default:=kubernetes
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Mount Path",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	MountPath string `json:"mountPath,omitempty"`
+	// Audience of the service account token.
+	// Must match the audience configured on the vault role
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Audience",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	Audience string `json:"audience,omitempty"`
 }
 
 // MicrosoftStorageOptions exposes options to
@@ -391,10 +773,35 @@ type AmazonStorageVault struct {
 type MicrosoftStorageOptions struct {
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Container",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Container string `json:"container,omitempty"`
+	// Name of the storage account.
+	// Deprecated: use credentialSecretName instead
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="ID",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	ID string `json:"id,omitempty"`
+	// Access key of the storage account.
+	// Deprecated: use credentialSecretName instead
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Secret",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Secret string `json:"secret,omitempty"`
+	// Name of secret with the storage account name under the
+	// key account-name and the access key under the key account-key.
+	// The account-key is not required when using workload identity
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credential Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	CredentialSecretName string `json:"credentialSecretName,omitempty"`
+	// Authenticate to the storage account with Azure
+	// workload identity instead of the account key
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Workload Identity"
+	WorkloadIdentity *AzureWorkloadIdentity `json:"workloadIdentity,omitempty"`
+}
+
+// AzureWorkloadIdentity configures the pachd and worker
+// service accounts to federate with an Azure AD application
+type AzureWorkloadIdentity struct {
+	// Client ID of the Azure AD application or managed identity
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Client ID",xDescriptors={"urn:alm:descriptor:text"}
+	ClientID string `json:"clientID"`
+	// Tenant ID of the Azure AD application.
+	// Defaults to the tenant configured in the workload identity webhook
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Tenant ID",xDescriptors={"urn:alm:descriptor:text"}
+	TenantID string `json:"tenantID,omitempty"`
 }
 
 // MinioStorageOptions exposes options to
@@ -408,15 +815,50 @@ type MinioStorageOptions struct {
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Endpoint",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Endpoint string `json:"endpoint,omitempty"`
 	// The user access ID that is used to access minio object store.
+	// Deprecated: use credentialSecretName instead
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="ID",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	ID string `json:"id,omitempty"`
-	// The associated password that is used with the user access ID
+	// The associated password that is used with the user access ID.
+	// Deprecated: use credentialSecretName instead
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Secret",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Secret string `json:"secret,omitempty"`
+	// Name of secret with the user access ID under the key
+	// access-id and the password under the key access-secret
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credential Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	CredentialSecretName string `json:"credentialSecretName,omitempty"`
+	// Set to "true" for pachd to connect to the object store using TLS.
+	// Deprecated: use useTLS instead
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Secure",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Secure string `json:"secure,omitempty"`
+	// If true, pachd connects to the object store using TLS.
+	// Takes precedence over secure when set
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Use TLS",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:io.kubernetes:custom"}
+	UseTLS *bool `json:"useTLS,omitempty"`
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Signature",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:custom"}
 	Signature string `json:"signature,omitempty"`
+	// Certificate authorities trusted by pachd and workers
+	// when connecting to an object store using a private CA
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="CA Bundle"
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
+	// Contents of the CA bundle resolved by the operator
+	CABundleData []byte `json:"-"`
+}
+
+// CABundleSource references a key in a ConfigMap
+// or Secret holding PEM encoded certificate authorities.
+// Exactly one of configMapName or secretName must be set
+type CABundleSource struct {
+	// Name of the config map containing the CA bundle
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Config Map",xDescriptors={"urn:alm:descriptor:io.kubernetes:ConfigMap"}
+	ConfigMapName string `json:"configMapName,omitempty"`
+	// Name of the secret containing the CA bundle
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	SecretName string `json:"secretName,omitempty"`
+	// Key holding the CA bundle.
+	// Defaults to ca.crt
+	//+kubebuilder:default:=ca.crt
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Key",xDescriptors={"urn:alm:descriptor:text"}
+	Key string `json:"key,omitempty"`
 }
 
 // PachydermPhase defines the data type used
@@ -435,6 +877,29 @@ const (
 	PhaseUpgrading PachydermPhase = "Upgrading"
 )
 
+const (
+	// ConditionPaused reports if pachd is scaled down
+	// by the pause annotation
+	ConditionPaused string = "Paused"
+	// ConditionStorageReachable reports if the object
+	// store used by pachd passed the pre-flight check
+	ConditionStorageReachable string = "StorageReachable"
+	// ConditionDatabaseReady reports if the databases
+	// used by pachd exist and are usable by the pachd user
+	ConditionDatabaseReady string = "DatabaseReady"
+	// ConditionVolumesExpanded reports if the etcd and postgresql
+	// volumes have been expanded to the requested storage size
+	ConditionVolumesExpanded string = "VolumesExpanded"
+)
+
+const (
+	// Database is created and initialized by the operator
+	DatabaseCreateInitialization string = "Create"
+	// Database is created by the administrator and
+	// only verified by the operator
+	DatabaseVerifyInitialization string = "Verify"
+)
+
 // PachydermStatus defines the observed state of Pachyderm
 type PachydermStatus struct {
 	// Deployment phase of the pachyderm cluster
@@ -446,6 +911,76 @@ type PachydermStatus struct {
 	// Version of the deployed pachyderm cluster
 	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Version",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:version"}
 	CurrentVersion string `json:"currentVersion,omitempty"`
+	// Conditions report the state of the pachyderm cluster
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Conditions",xDescriptors={"urn:alm:descriptor:io.kubernetes.conditions"}
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Lease of the object storage credentials issued by vault
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Vault Lease"
+	VaultLease *VaultLeaseStatus `json:"vaultLease,omitempty"`
+	// Time the password of the pachd database user was last rotated
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Postgres Password Rotated At"
+	PostgresPasswordRotatedAt *metav1.Time `json:"postgresPasswordRotatedAt,omitempty"`
+	// Size of the persistent volumes of etcd and postgresql
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Volumes"
+	Volumes []VolumeStatus `json:"volumes,omitempty"`
+	// Database size of the etcd members and
+	// time the etcd maintenance last ran
+	//+operator-sdk:csv:customresourcedefinitions:type=status,displayName="Etcd"
+	Etcd *EtcdStatus `json:"etcd,omitempty"`
+}
+
+// EtcdStatus reports the state of the etcd database
+type EtcdStatus struct {
+	// Time the database size of the etcd members was last checked
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+	// Time the etcd database was last compacted and defragmented
+	LastMaintenanceTime *metav1.Time `json:"lastMaintenanceTime,omitempty"`
+	// Time the etcd certificates issued by the operator expire
+	CertificatesExpireTime *metav1.Time `json:"certificatesExpireTime,omitempty"`
+	// Database size reported by each etcd member
+	Members []EtcdMemberStatus `json:"members,omitempty"`
+}
+
+// EtcdMemberStatus reports the database size of an etcd member
+type EtcdMemberStatus struct {
+	// Name of the etcd member
+	Name string `json:"name"`
+	// Size of the database file in bytes
+	DBSize int64 `json:"dbSize"`
+	// Bytes of the database file in use.
+	// The remainder is reclaimed by defragmentation
+	DBSizeInUse int64 `json:"dbSizeInUse"`
+	// Alarms raised by the member, such as NOSPACE
+	Alarms []string `json:"alarms,omitempty"`
+}
+
+// VolumeStatus reports the size of a persistent volume claim
+type VolumeStatus struct {
+	// Name of the persistent volume claim
+	ClaimName string `json:"claimName"`
+	// Size requested by the storage size option
+	Requested string `json:"requested"`
+	// Size of the provisioned volume
+	Capacity string `json:"capacity,omitempty"`
+	// Resizing is true while the volume
+	// is expanded to the requested size
+	Resizing bool `json:"resizing,omitempty"`
+}
+
+// VaultLeaseStatus reports the lease of the
+// credentials retrieved from vault
+type VaultLeaseStatus struct {
+	// ID of the vault lease
+	ID string `json:"id,omitempty"`
+	// If true, the lease is extended before it expires.
+	// Otherwise new credentials are requested
+	Renewable bool `json:"renewable,omitempty"`
+	// Time the lease is renewed or replaced
+	RenewTime *metav1.Time `json:"renewTime,omitempty"`
+	// Time the credentials expire
+	ExpireTime *metav1.Time `json:"expireTime,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -22,10 +22,12 @@ import (
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 
 	"sort"
 
 	"github.com/creasty/defaults"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -37,8 +39,19 @@ import (
 // log is for logging in this package.
 var pachydermlog = logf.Log.WithName("pachyderm-resource")
 
+// size of the etcd and postgresql volumes
+// when the resource does not set one
+const defaultStorageSize string = "10Gi"
+
 // SetupWebhookWithManager setups the webhook
 func (r *Pachyderm) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	// The validating webhook is registered ahead of the builder
+	// so that deprecation warnings are returned to clients
+	mgr.GetWebhookServer().Register(
+		"/validate-aiml-pachyderm-com-v1beta1-pachyderm",
+		&webhook.Admission{Handler: &pachydermValidator{}},
+	)
+
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -58,9 +71,28 @@ func (r *Pachyderm) Default() {
 		}
 	}
 
+	if r.Spec.Pachd.Storage.Backend == LocalStorageBackend &&
+		r.Spec.Pachd.Storage.Local == nil {
+		r.Spec.Pachd.Storage.Local = &LocalStorageOptions{
+			HostPath: "/var/pachyderm/",
+		}
+	}
+
 	if r.Spec.Version == "" {
 		r.Spec.Version = getDefaultVersion()
 	}
+
+	// the schema defaults are not applied when
+	// spec.etcd or spec.postgresql are omitted
+	r.Spec.Etcd.StorageSize = r.EtcdStorageSize()
+	r.Spec.Postgres.StorageSize = r.PostgresStorageSize()
+
+	// the postgres operator only accepts encrypted connections
+	if r.IsPostgresHA() {
+		if ssl := r.Spec.Pachd.Postgres.SSL; ssl == "" || ssl == "disable" {
+			r.Spec.Pachd.Postgres.SSL = "require"
+		}
+	}
 }
 
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
@@ -72,19 +104,56 @@ var _ webhook.Validator = &Pachyderm{}
 func (r *Pachyderm) ValidateCreate() error {
 	pachydermlog.Info("validate create", "name", r.Name)
 
-	if r.isUsingGCS() && r.Spec.Pachd.Storage.Google.CredentialSecret == "" {
-		return errors.New("spec.pachd.storage.google.credentialSecret can not be empty")
+	if err := r.validatePostgres(); err != nil {
+		return err
 	}
 
-	return nil
+	if err := r.validateVolumeSizes(nil); err != nil {
+		return err
+	}
+
+	if err := r.validateEtcd(nil); err != nil {
+		return err
+	}
+
+	if err := r.validatePachdTLS(nil); err != nil {
+		return err
+	}
+
+	return r.validateStorage()
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *Pachyderm) ValidateUpdate(old runtime.Object) error {
 	pachydermlog.Info("validate update", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object update.
-	return nil
+	// finalizers are removed from resources being deleted
+	if r.IsDeleted() {
+		return nil
+	}
+
+	if err := r.validatePostgres(); err != nil {
+		return err
+	}
+
+	if previous, ok := old.(*Pachyderm); ok && previous.IsPostgresHA() != r.IsPostgresHA() {
+		return errors.New("spec.postgresql.highAvailability can not be added or removed after the pachyderm resource is created")
+	}
+
+	previous, _ := old.(*Pachyderm)
+	if err := r.validateVolumeSizes(previous); err != nil {
+		return err
+	}
+
+	if err := r.validateEtcd(previous); err != nil {
+		return err
+	}
+
+	if err := r.validatePachdTLS(previous); err != nil {
+		return err
+	}
+
+	return r.validateStorage()
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -95,6 +164,407 @@ func (r *Pachyderm) ValidateDelete() error {
 	return nil
 }
 
+// Warnings returns the deprecation warnings
+// sent to clients creating or updating the resource
+func (r *Pachyderm) Warnings() []string {
+	warnings := []string{}
+
+	if microsoft := r.Spec.Pachd.Storage.Microsoft; microsoft != nil {
+		if microsoft.ID != "" || microsoft.Secret != "" {
+			warnings = append(warnings,
+				"spec.pachd.storage.microsoft.id and spec.pachd.storage.microsoft.secret are deprecated; use spec.pachd.storage.microsoft.credentialSecretName",
+			)
+		}
+	}
+
+	if amazon := r.Spec.Pachd.Storage.Amazon; amazon != nil && amazon.Vault != nil {
+		if amazon.Vault.Token != "" {
+			warnings = append(warnings,
+				"spec.pachd.storage.amazon.vault.token is deprecated; use spec.pachd.storage.amazon.vault.tokenSecretName",
+			)
+		}
+	}
+
+	if r.Spec.Pachd.Storage.Backend == LocalStorageBackend {
+		warnings = append(warnings,
+			"spec.pachd.storage.backend LOCAL stores data on a single node and is only intended for development and CI clusters",
+		)
+	}
+
+	if minio := r.Spec.Pachd.Storage.Minio; minio != nil {
+		if minio.ID != "" || minio.Secret != "" {
+			warnings = append(warnings,
+				"spec.pachd.storage.minio.id and spec.pachd.storage.minio.secret are deprecated; use spec.pachd.storage.minio.credentialSecretName",
+			)
+		}
+		if minio.Secure != "" {
+			warnings = append(warnings,
+				"spec.pachd.storage.minio.secure is deprecated; use spec.pachd.storage.minio.useTLS",
+			)
+		}
+	}
+
+	return warnings
+}
+
+// validatePostgres checks the TLS settings and verify-only
+// initialization are only set for a database that supports them and
+// that password rotation has an admin connection to use
+func (r *Pachyderm) validatePostgres() error {
+	postgres := r.Spec.Pachd.Postgres
+
+	if postgres.CABundle != nil {
+		if (postgres.CABundle.ConfigMapName == "") == (postgres.CABundle.SecretName == "") {
+			return errors.New("exactly one of spec.pachd.postgresql.caBundle.configMapName or spec.pachd.postgresql.caBundle.secretName must be set")
+		}
+	}
+
+	if r.DeployPostgres() && !r.IsPostgresHA() && r.RequiresPostgresTLS() {
+		return errors.New("spec.pachd.postgresql TLS settings require an external or highly available database")
+	}
+
+	if r.IsPostgresHA() && !r.RequiresPostgresTLS() {
+		return errors.New("spec.pachd.postgresql.ssl must be require, verify-ca or verify-full for a highly available database")
+	}
+
+	if r.DeployPostgres() && postgres.Initialization == DatabaseVerifyInitialization {
+		return errors.New("spec.pachd.postgresql.initialization Verify requires an external database; set spec.postgresql.disable to true")
+	}
+
+	if postgres.PasswordRotation != nil && postgres.Initialization == DatabaseVerifyInitialization {
+		return errors.New("spec.pachd.postgresql.passwordRotation requires the Create initialization")
+	}
+
+	return nil
+}
+
+// validateVolumeSizes checks the etcd and postgresql storage
+// sizes are valid quantities that only grow, as persistent
+// volume claims can not shrink
+func (r *Pachyderm) validateVolumeSizes(previous *Pachyderm) error {
+	sizes := []struct {
+		field     string
+		requested string
+		previous  string
+	}{
+		{field: "spec.etcd.storageSize", requested: r.Spec.Etcd.StorageSize},
+		{field: "spec.postgresql.storageSize", requested: r.Spec.Postgres.StorageSize},
+	}
+	if previous != nil {
+		sizes[0].previous = previous.Spec.Etcd.StorageSize
+		sizes[1].previous = previous.Spec.Postgres.StorageSize
+	}
+
+	for _, size := range sizes {
+		if size.requested == "" {
+			continue
+		}
+		requested, err := resource.ParseQuantity(size.requested)
+		if err != nil {
+			return fmt.Errorf("%s: %w", size.field, err)
+		}
+
+		current, err := resource.ParseQuantity(size.previous)
+		if err == nil && requested.Cmp(current) < 0 {
+			return fmt.Errorf("%s can not be decreased from %s to %s", size.field, size.previous, size.requested)
+		}
+	}
+
+	return nil
+}
+
+// validateEtcd checks the etcd cluster is resized to an odd number
+// of members and is not scaled below the quorum of its current size,
+// and that the TLS source does not change on a running cluster
+func (r *Pachyderm) validateEtcd(previous *Pachyderm) error {
+	// resources created with an even size remain updatable
+	nodes := r.EtcdNodes()
+	if (previous == nil || previous.EtcdNodes() != nodes) && nodes%2 == 0 {
+		return fmt.Errorf("spec.etcd.dynamicNodes must be an odd number, got %d", nodes)
+	}
+
+	if tls := r.Spec.Etcd.TLS; tls != nil && tls.Source == EtcdTLSSecretSource {
+		if tls.ServerSecretName == "" || tls.PeerSecretName == "" || tls.ClientSecretName == "" {
+			return errors.New("spec.etcd.tls.serverSecret, spec.etcd.tls.peerSecret and spec.etcd.tls.clientSecret are required for the Secret source")
+		}
+	}
+
+	if previous == nil {
+		return nil
+	}
+
+	if (previous.Spec.Etcd.TLS == nil) != (r.Spec.Etcd.TLS == nil) ||
+		(r.Spec.Etcd.TLS != nil && previous.Spec.Etcd.TLS.Source != r.Spec.Etcd.TLS.Source) {
+		return errors.New("spec.etcd.tls can not be added, removed or change source after the pachyderm resource is created")
+	}
+
+	if quorum := previous.EtcdNodes()/2 + 1; nodes < quorum {
+		return fmt.Errorf("spec.etcd.dynamicNodes can not be scaled from %d to %d, below the quorum of %d members",
+			previous.EtcdNodes(), nodes, quorum)
+	}
+
+	return nil
+}
+
+// validatePachdTLS checks the secret holding the pachd certificate
+// is set for the Secret source and that the TLS source does not change
+// on a running cluster. The serving certificate of the service CA is
+// requested when the pachd service is created
+func (r *Pachyderm) validatePachdTLS(previous *Pachyderm) error {
+	if tls := r.Spec.Pachd.TLS; tls != nil && tls.Source == PachdTLSSecretSource && tls.SecretName == "" {
+		return errors.New("spec.pachd.tls.secretName is required for the Secret source")
+	}
+
+	if previous == nil {
+		return nil
+	}
+
+	if (previous.Spec.Pachd.TLS == nil) != (r.Spec.Pachd.TLS == nil) ||
+		(r.Spec.Pachd.TLS != nil && previous.Spec.Pachd.TLS.Source != r.Spec.Pachd.TLS.Source) {
+		return errors.New("spec.pachd.tls can not be added, removed or change source after the pachyderm resource is created")
+	}
+
+	return nil
+}
+
+// validateStorage checks the object storage
+// options of the selected backend
+func (r *Pachyderm) validateStorage() error {
+	validators := []func() error{
+		r.validateGoogleStorage,
+		r.validateAmazonStorage,
+		r.validateMicrosoftStorage,
+		r.validateMinioStorage,
+		r.validateCustomStorage,
+		r.validateLocalStorage,
+	}
+	for _, validate := range validators {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateGoogleStorage checks GCS is accessed using either
+// a service account key or workload identity, but not both
+func (r *Pachyderm) validateGoogleStorage() error {
+	if !r.isUsingGCS() {
+		return nil
+	}
+
+	google := r.Spec.Pachd.Storage.Google
+	if (google.CredentialSecret == "") == (google.ServiceAccountName == "") {
+		return errors.New("exactly one of spec.pachd.storage.google.credentialSecret or spec.pachd.storage.google.serviceAccountName must be set")
+	}
+
+	return nil
+}
+
+// validateAmazonStorage checks the S3 credentials are
+// provided by either a secret or an IAM role
+func (r *Pachyderm) validateAmazonStorage() error {
+	if r.Spec.Pachd.Storage.Backend != AmazonStorageBackend {
+		return nil
+	}
+
+	amazon := r.Spec.Pachd.Storage.Amazon
+	if amazon == nil {
+		return errors.New("spec.pachd.storage.amazon can not be empty")
+	}
+
+	if amazon.IAMRole == "" && amazon.CredentialSecretName == "" {
+		return errors.New("spec.pachd.storage.amazon.credentialSecretName can not be empty")
+	}
+
+	if vault := amazon.Vault; vault != nil {
+		if amazon.IAMRole != "" {
+			return errors.New("spec.pachd.storage.amazon.iamRole and spec.pachd.storage.amazon.vault can not be used together")
+		}
+		if vault.Address == "" {
+			return errors.New("spec.pachd.storage.amazon.vault.address can not be empty")
+		}
+		if vault.Role == "" {
+			return errors.New("spec.pachd.storage.amazon.vault.role can not be empty")
+		}
+
+		methods := 0
+		for _, set := range []bool{vault.Token != "", vault.TokenSecretName != "", vault.Kubernetes != nil} {
+			if set {
+				methods++
+			}
+		}
+		if methods != 1 {
+			return errors.New("exactly one of spec.pachd.storage.amazon.vault.tokenSecretName or spec.pachd.storage.amazon.vault.kubernetes must be set")
+		}
+		if vault.Kubernetes != nil && vault.Kubernetes.Role == "" {
+			return errors.New("spec.pachd.storage.amazon.vault.kubernetes.role can not be empty")
+		}
+		if vault.CABundle != nil {
+			if (vault.CABundle.ConfigMapName == "") == (vault.CABundle.SecretName == "") {
+				return errors.New("exactly one of spec.pachd.storage.amazon.vault.caBundle.configMapName or spec.pachd.storage.amazon.vault.caBundle.secretName must be set")
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateMicrosoftStorage checks the Azure storage account
+// credentials are provided by one of the supported methods
+func (r *Pachyderm) validateMicrosoftStorage() error {
+	if r.Spec.Pachd.Storage.Backend != MicrosoftStorageBackend {
+		return nil
+	}
+
+	microsoft := r.Spec.Pachd.Storage.Microsoft
+	if microsoft == nil {
+		return errors.New("spec.pachd.storage.microsoft can not be empty")
+	}
+
+	if microsoft.CredentialSecretName == "" && microsoft.ID == "" {
+		return errors.New("spec.pachd.storage.microsoft.credentialSecretName can not be empty")
+	}
+
+	if microsoft.WorkloadIdentity != nil {
+		if microsoft.WorkloadIdentity.ClientID == "" {
+			return errors.New("spec.pachd.storage.microsoft.workloadIdentity.clientID can not be empty")
+		}
+		if microsoft.Secret != "" {
+			return errors.New("spec.pachd.storage.microsoft.secret can not be set when using workload identity")
+		}
+	}
+
+	return nil
+}
+
+// validateMinioStorage checks the minio credentials
+// and CA bundle reference are valid
+func (r *Pachyderm) validateMinioStorage() error {
+	if r.Spec.Pachd.Storage.Backend != MinioStorageBackend {
+		return nil
+	}
+
+	minio := r.Spec.Pachd.Storage.Minio
+	if minio == nil {
+		return errors.New("spec.pachd.storage.minio can not be empty")
+	}
+
+	if minio.CredentialSecretName == "" && minio.ID == "" {
+		return errors.New("spec.pachd.storage.minio.credentialSecretName can not be empty")
+	}
+
+	if minio.Secure != "" {
+		if _, err := strconv.ParseBool(minio.Secure); err != nil {
+			return errors.New("spec.pachd.storage.minio.secure must be true or false")
+		}
+	}
+
+	if minio.CABundle != nil {
+		if (minio.CABundle.ConfigMapName == "") == (minio.CABundle.SecretName == "") {
+			return errors.New("exactly one of spec.pachd.storage.minio.caBundle.configMapName or spec.pachd.storage.minio.caBundle.secretName must be set")
+		}
+	}
+
+	return nil
+}
+
+// validateCustomStorage checks the S3-compatible
+// object store endpoint and credentials are set
+func (r *Pachyderm) validateCustomStorage() error {
+	if r.Spec.Pachd.Storage.Backend != CustomStorageBackend {
+		return nil
+	}
+
+	custom := r.Spec.Pachd.Storage.Custom
+	if custom == nil {
+		return errors.New("spec.pachd.storage.custom can not be empty")
+	}
+
+	if custom.Endpoint == "" {
+		return errors.New("spec.pachd.storage.custom.endpoint can not be empty")
+	}
+
+	if custom.Bucket == "" {
+		return errors.New("spec.pachd.storage.custom.bucket can not be empty")
+	}
+
+	if custom.CredentialSecretName == "" {
+		return errors.New("spec.pachd.storage.custom.credentialSecretName can not be empty")
+	}
+
+	if custom.CABundle != nil {
+		if (custom.CABundle.ConfigMapName == "") == (custom.CABundle.SecretName == "") {
+			return errors.New("exactly one of spec.pachd.storage.custom.caBundle.configMapName or spec.pachd.storage.custom.caBundle.secretName must be set")
+		}
+	}
+
+	return nil
+}
+
+// validateLocalStorage checks the host path used by local storage
+func (r *Pachyderm) validateLocalStorage() error {
+	if r.Spec.Pachd.Storage.Backend != LocalStorageBackend {
+		return nil
+	}
+
+	local := r.Spec.Pachd.Storage.Local
+	if local != nil && local.HostPath != "" {
+		if !strings.HasPrefix(local.HostPath, "/") || !strings.HasSuffix(local.HostPath, "/") {
+			return errors.New("spec.pachd.storage.local.hostPath must be an absolute path ending in /")
+		}
+	}
+
+	return nil
+}
+
+// IsUsingAmazonIAMRole returns true if pachd authenticates
+// to S3 using a web identity token for the IAM role
+func (r *Pachyderm) IsUsingAmazonIAMRole() bool {
+	return r.Spec.Pachd.Storage.Backend == AmazonStorageBackend &&
+		r.Spec.Pachd.Storage.Amazon != nil &&
+		r.Spec.Pachd.Storage.Amazon.IAMRole != ""
+}
+
+// IsUsingVault returns true if the S3 credentials
+// used by pachd are retrieved from vault
+func (r *Pachyderm) IsUsingVault() bool {
+	return r.Spec.Pachd.Storage.Backend == AmazonStorageBackend &&
+		r.Spec.Pachd.Storage.Amazon != nil &&
+		r.Spec.Pachd.Storage.Amazon.Vault != nil
+}
+
+// IsUsingAzureWorkloadIdentity returns true if pachd authenticates
+// to Azure storage using workload identity
+func (r *Pachyderm) IsUsingAzureWorkloadIdentity() bool {
+	return r.Spec.Pachd.Storage.Backend == MicrosoftStorageBackend &&
+		r.Spec.Pachd.Storage.Microsoft != nil &&
+		r.Spec.Pachd.Storage.Microsoft.WorkloadIdentity != nil
+}
+
+// IsUsingGCSWorkloadIdentity returns true if pachd authenticates
+// to Google Cloud Storage using GKE workload identity
+func (r *Pachyderm) IsUsingGCSWorkloadIdentity() bool {
+	return r.isUsingGCS() && r.Spec.Pachd.Storage.Google.ServiceAccountName != ""
+}
+
+// IsSecure returns true if pachd connects
+// to the minio object store using TLS
+func (m *MinioStorageOptions) IsSecure() bool {
+	if m.UseTLS != nil {
+		return *m.UseTLS
+	}
+	secure, _ := strconv.ParseBool(m.Secure)
+	return secure
+}
+
+// IsVerifyingSSL returns true unless certificate
+// verification of the object store is disabled
+func (o *AmazonStorageOptions) IsVerifyingSSL() bool {
+	return o.VerifySSL == nil || *o.VerifySSL
+}
+
 // returns true if Pachd storage is using Google Container storage
 func (r *Pachyderm) isUsingGCS() bool {
 	return r.Spec.Pachd.Storage.Google != nil && r.Spec.Pachd.Storage.Backend == GoogleStorageBackend
@@ -147,16 +617,177 @@ func (r *Pachyderm) SetGoogleCredentials(credentials []byte) {
 	r.Spec.Pachd.Storage.Google.CredentialsData = credentials
 }
 
+// ReferencedSecrets returns the names of the secrets
+// read by the operator to configure the pachyderm cluster
+func (r *Pachyderm) ReferencedSecrets() []string {
+	names := []string{
+		r.Spec.License,
+		r.Spec.Pachd.Postgres.PasswordSecretName,
+		r.Spec.Pachd.Postgres.ClientCertificateSecretName,
+	}
+	if r.Spec.Pachd.Postgres.CABundle != nil {
+		names = append(names, r.Spec.Pachd.Postgres.CABundle.SecretName)
+	}
+
+	storage := r.Spec.Pachd.Storage
+	if storage.Amazon != nil {
+		names = append(names, storage.Amazon.CredentialSecretName)
+		if vault := storage.Amazon.Vault; vault != nil {
+			names = append(names, vault.TokenSecretName)
+			if vault.CABundle != nil {
+				names = append(names, vault.CABundle.SecretName)
+			}
+		}
+	}
+	if storage.Google != nil {
+		names = append(names, storage.Google.CredentialSecret)
+	}
+	if storage.Microsoft != nil {
+		names = append(names, storage.Microsoft.CredentialSecretName)
+	}
+	if storage.Minio != nil {
+		names = append(names, storage.Minio.CredentialSecretName)
+		if storage.Minio.CABundle != nil {
+			names = append(names, storage.Minio.CABundle.SecretName)
+		}
+	}
+	if storage.Custom != nil {
+		names = append(names, storage.Custom.CredentialSecretName)
+		if storage.Custom.CABundle != nil {
+			names = append(names, storage.Custom.CABundle.SecretName)
+		}
+	}
+
+	if tls := r.Spec.Etcd.TLS; tls != nil && tls.Source == EtcdTLSSecretSource {
+		names = append(names, tls.ServerSecretName, tls.PeerSecretName, tls.ClientSecretName)
+	}
+	if tls := r.Spec.Pachd.TLS; tls != nil && tls.Source == PachdTLSSecretSource {
+		names = append(names, tls.SecretName)
+	}
+
+	return nonEmpty(names)
+}
+
+// ReferencedConfigMaps returns the names of the config
+// maps read by the operator to configure the pachyderm cluster
+func (r *Pachyderm) ReferencedConfigMaps() []string {
+	names := []string{
+		r.Spec.Pachd.Postgres.InitScriptsConfigMap,
+	}
+
+	if postgres := r.Spec.Pachd.Postgres; postgres.CABundle != nil {
+		names = append(names, postgres.CABundle.ConfigMapName)
+	}
+	if minio := r.Spec.Pachd.Storage.Minio; minio != nil && minio.CABundle != nil {
+		names = append(names, minio.CABundle.ConfigMapName)
+	}
+	if amazon := r.Spec.Pachd.Storage.Amazon; amazon != nil && amazon.Vault != nil && amazon.Vault.CABundle != nil {
+		names = append(names, amazon.Vault.CABundle.ConfigMapName)
+	}
+	if custom := r.Spec.Pachd.Storage.Custom; custom != nil && custom.CABundle != nil {
+		names = append(names, custom.CABundle.ConfigMapName)
+	}
+	if tls := r.Spec.Etcd.TLS; tls != nil && tls.Source == EtcdTLSServiceCASource {
+		names = append(names, EtcdServiceCABundleName)
+	}
+	if tls := r.Spec.Pachd.TLS; tls != nil && tls.Source != PachdTLSSecretSource {
+		names = append(names, PachdServiceCABundleName)
+	}
+
+	return nonEmpty(names)
+}
+
+func nonEmpty(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
 // IsDeleted returns true if the pachyderm resource
 // has been marked for deletion
 func (r *Pachyderm) IsDeleted() bool {
 	return r.ObjectMeta.DeletionTimestamp != nil
 }
 
+// EtcdNodes returns the number of members of the etcd cluster
+func (r *Pachyderm) EtcdNodes() int32 {
+	if r.Spec.Etcd.DynamicNodes <= 0 {
+		return 1
+	}
+	return r.Spec.Etcd.DynamicNodes
+}
+
+// EtcdStorageSize returns the size of the etcd volumes,
+// defaulting to 10Gi when spec.etcd is omitted
+func (r *Pachyderm) EtcdStorageSize() string {
+	if r.Spec.Etcd.StorageSize == "" {
+		return defaultStorageSize
+	}
+	return r.Spec.Etcd.StorageSize
+}
+
+// PostgresStorageSize returns the size of the postgresql
+// volumes, defaulting to 10Gi when spec.postgresql is omitted
+func (r *Pachyderm) PostgresStorageSize() string {
+	if r.Spec.Postgres.StorageSize == "" {
+		return defaultStorageSize
+	}
+	return r.Spec.Postgres.StorageSize
+}
+
+// PostgresLoginUser returns the database role pachd logs in as
+func (r *Pachyderm) PostgresLoginUser() string {
+	if r.Spec.Pachd.Postgres.LoginUser == "" {
+		return r.Spec.Pachd.Postgres.User
+	}
+	return r.Spec.Pachd.Postgres.LoginUser
+}
+
 func (r *Pachyderm) DeployPostgres() bool {
 	return !r.Spec.Postgres.Disable
 }
 
+// PachdTLSSecretName returns the name of the secret holding
+// the certificate served by pachd, if TLS is enabled
+func (r *Pachyderm) PachdTLSSecretName() string {
+	tls := r.Spec.Pachd.TLS
+	if tls == nil {
+		return ""
+	}
+	if tls.Source == PachdTLSSecretSource {
+		return tls.SecretName
+	}
+	return PachdServingSecretName
+}
+
+// IsPostgresHA returns true if the embedded database
+// is a PostgresCluster managed by the postgres operator
+func (r *Pachyderm) IsPostgresHA() bool {
+	return r.DeployPostgres() && r.Spec.Postgres.HighAvailability != nil
+}
+
+// RequiresPostgresTLS returns true if connections
+// to the database must be encrypted
+func (r *Pachyderm) RequiresPostgresTLS() bool {
+	postgres := r.Spec.Pachd.Postgres
+	switch postgres.SSL {
+	case "require", "verify-ca", "verify-full":
+		return true
+	}
+	return postgres.CABundle != nil || postgres.ClientCertificateSecretName != ""
+}
+
+// VerifyDatabaseOnly returns true if the operator must not
+// create the databases and only check they are usable by pachd
+func (r *Pachyderm) VerifyDatabaseOnly() bool {
+	return !r.DeployPostgres() &&
+		r.Spec.Pachd.Postgres.Initialization == DatabaseVerifyInitialization
+}
+
 func (r *Pachyderm) IsPaused() bool {
 	pauseState, ok := r.Annotations[PachydermPauseAnnotation]
 	if !ok {
@@ -169,3 +800,19 @@ func (r *Pachyderm) IsPaused() bool {
 	}
 	return pause
 }
+
+// PausedBy returns the name of the pachyderm export
+// that paused the cluster, if any
+func (r *Pachyderm) PausedBy() string {
+	return r.Annotations[PachydermPausedByAnnotation]
+}
+
+// PausedByMigration returns the name of the pachyderm
+// storage migration that paused the cluster, if any
+func (r *Pachyderm) PausedByMigration() string {
+	pausedBy := r.PausedBy()
+	if !strings.HasPrefix(pausedBy, StorageMigrationPausePrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(pausedBy, StorageMigrationPausePrefix)
+}
@@ -0,0 +1,142 @@
+package v1beta1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newValidPachyderm returns a pachyderm resource
+// with the given number of etcd members
+func newValidPachyderm(etcdNodes int32) *Pachyderm {
+	return &Pachyderm{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pachyderm",
+			Namespace: "default",
+		},
+		Spec: PachydermSpec{
+			Etcd: EtcdOptions{
+				DynamicNodes: etcdNodes,
+			},
+			Pachd: PachdOptions{
+				Storage: ObjectStorageOptions{
+					Backend: LocalStorageBackend,
+				},
+			},
+		},
+	}
+}
+
+func TestValidateEtcdUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous int32
+		nodes    int32
+		mutate   func(pd *Pachyderm)
+		failed   bool
+	}{
+		{
+			name:     "scale to an odd size",
+			previous: 1,
+			nodes:    3,
+		},
+		{
+			name:     "scale to an even size",
+			previous: 3,
+			nodes:    4,
+			failed:   true,
+		},
+		{
+			name:     "update keeping an even size",
+			previous: 2,
+			nodes:    2,
+			mutate: func(pd *Pachyderm) {
+				pd.Labels = map[string]string{"team": "ml"}
+			},
+		},
+		{
+			name:     "scale below quorum",
+			previous: 5,
+			nodes:    1,
+			failed:   true,
+		},
+		{
+			name:     "remove finalizer while deleting",
+			previous: 3,
+			nodes:    4,
+			mutate: func(pd *Pachyderm) {
+				deleted := metav1.Now()
+				pd.DeletionTimestamp = &deleted
+				pd.Finalizers = nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previous := newValidPachyderm(test.previous)
+			pd := newValidPachyderm(test.nodes)
+			if test.mutate != nil {
+				test.mutate(pd)
+			}
+
+			err := pd.ValidateUpdate(previous)
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+		})
+	}
+}
+
+func TestValidatePachdTLSUpdate(t *testing.T) {
+	serviceCA := &PachdTLSOptions{Source: PachdTLSServiceCASource}
+	secret := &PachdTLSOptions{Source: PachdTLSSecretSource, SecretName: "pachd-certificate"}
+
+	tests := []struct {
+		name     string
+		previous *PachdTLSOptions
+		tls      *PachdTLSOptions
+		failed   bool
+	}{
+		{
+			name:     "keep service CA",
+			previous: serviceCA,
+			tls:      serviceCA,
+		},
+		{
+			name:     "replace secret",
+			previous: secret,
+			tls:      &PachdTLSOptions{Source: PachdTLSSecretSource, SecretName: "renewed-certificate"},
+		},
+		{
+			name:   "add TLS",
+			tls:    serviceCA,
+			failed: true,
+		},
+		{
+			name:     "remove TLS",
+			previous: serviceCA,
+			failed:   true,
+		},
+		{
+			name:     "change source",
+			previous: serviceCA,
+			tls:      secret,
+			failed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previous := newValidPachyderm(1)
+			previous.Spec.Pachd.TLS = test.previous
+			pd := newValidPachyderm(1)
+			pd.Spec.Pachd.TLS = test.tls
+
+			err := pd.ValidateUpdate(previous)
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+		})
+	}
+}
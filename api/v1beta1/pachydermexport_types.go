@@ -30,15 +30,156 @@ type PachydermExportSpec struct {
 	//+kubebuilder:validation:required=true
 	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="S3 Upload Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
 	StorageSecret string `json:"storageSecret,omitempty"`
+
+	// Destination the backup is written to by the operator.
+	// When set, the backup handler and storageSecret are not used
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Destination"
+	Destination *ExportDestination `json:"destination,omitempty"`
+
+	// If true, the database dump is restored into a temporary
+	// postgresql pod and checked before the export is marked completed
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Verify",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
+	Verify bool `json:"verify,omitempty"`
+
+	// Maximum duration the verification pod may take to restore
+	// and check the database dump. The export fails once it elapses.
+	// Defaults to 30m
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Verification Timeout",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	VerificationTimeout *metav1.Duration `json:"verificationTimeout,omitempty"`
+
+	// Maximum duration the pachyderm cluster is kept paused
+	// while the backup runs. The export fails and the cluster
+	// resumes once the timeout elapses.
+	// Defaults to 1h
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Pause Timeout",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:text"}
+	PauseTimeout *metav1.Duration `json:"pauseTimeout,omitempty"`
+
+	// Mode of the export. Offline exports pause pachd while the
+	// backup runs. Online exports keep pachd running and rely on
+	// pg_dump and etcd snapshots being consistent on their own.
+	// Defaults to Offline
+	//+kubebuilder:validation:Enum:=Offline;Online
+	//+kubebuilder:default:=Offline
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Export Mode",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:Offline","urn:alm:descriptor:com.tectonic.ui:select:Online"}
+	Mode string `json:"mode,omitempty"`
+
+	// If true, a snapshot of the etcd cluster is
+	// uploaded alongside the database dump
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Include Etcd",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
+	IncludeEtcd bool `json:"includeEtcd,omitempty"`
+}
+
+// ExportDestination configures where the backup is stored.
+// Exactly one destination must be set
+type ExportDestination struct {
+	// Upload the backup to an S3-compatible object store
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="S3"
+	S3 *S3Destination `json:"s3,omitempty"`
+	// Upload the backup to a Google Cloud Storage bucket
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="GCS"
+	GCS *GCSDestination `json:"gcs,omitempty"`
+	// Upload the backup to an Azure Blob Storage container
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Azure"
+	Azure *AzureDestination `json:"azure,omitempty"`
+	// Write the backup to a persistent volume claim
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="PVC"
+	PVC *PVCDestination `json:"pvc,omitempty"`
+}
+
+// S3Destination configures an S3-compatible backup destination.
+// Backups are uploaded in parts of 1 GiB, which limits
+// the size of a backup to 100 GiB
+type S3Destination struct {
+	// Name of secret with the keys bucket, region, access-id,
+	// access-secret and optionally custom-endpoint. Set the
+	// custom-endpoint to use an S3-compatible store like minio
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credential Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	CredentialSecret string `json:"credentialSecret"`
+	// Prefix prepended to the name of the backup object
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Prefix",xDescriptors={"urn:alm:descriptor:text"}
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// GCSDestination configures a Google Cloud Storage backup destination
+type GCSDestination struct {
+	// Name of the GCS bucket
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Bucket",xDescriptors={"urn:alm:descriptor:text"}
+	Bucket string `json:"bucket"`
+	// Name of secret with the service account
+	// credentials under the key credentials.json.
+	// When empty, the workload identity of the operator
+	// is used to sign the upload of the backup
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credential Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	CredentialSecret string `json:"credentialSecret,omitempty"`
+	// Prefix prepended to the name of the backup object
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Prefix",xDescriptors={"urn:alm:descriptor:text"}
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// AzureDestination configures an Azure Blob Storage backup destination.
+// Backups are uploaded with a single Put Blob request, which
+// limits the size of a backup to 5000 MiB
+type AzureDestination struct {
+	// Name of the blob container
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Container",xDescriptors={"urn:alm:descriptor:text"}
+	Container string `json:"container"`
+	// Name of secret with the keys account-name,
+	// account-key and optionally custom-endpoint
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Credential Secret",xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
+	CredentialSecret string `json:"credentialSecret"`
+	// Prefix prepended to the name of the backup blob
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Prefix",xDescriptors={"urn:alm:descriptor:text"}
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// PVCDestination configures a persistent volume backup destination
+type PVCDestination struct {
+	// Name of the persistent volume claim
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Claim Name",xDescriptors={"urn:alm:descriptor:io.kubernetes:PersistentVolumeClaim"}
+	ClaimName string `json:"claimName"`
+	// Directory on the volume backups are written to
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Path",xDescriptors={"urn:alm:descriptor:text"}
+	Path string `json:"path,omitempty"`
 }
 
+const (
+	// Export mode that pauses pachd during the backup
+	ExportOfflineMode string = "Offline"
+	// Export mode that keeps pachd running during the backup
+	ExportOnlineMode string = "Online"
+)
+
 const (
 	// Set status of Pachyderm Export to running
 	ExportRunningStatus string = "Running"
 	// Sets status of Pachyderm export to completed
 	ExportCompletedStatus string = "Completed"
+	// Sets status of Pachyderm export to verifying
+	ExportVerifyingStatus string = "Verifying"
+	// Sets status of Pachyderm export to failed
+	ExportFailedStatus string = "Failed"
 )
 
+// ExportArtifact describes a file stored in the backup tarball
+type ExportArtifact struct {
+	// Name of the file in the backup tarball
+	Name string `json:"name"`
+	// Hex encoded SHA-256 digest of the file contents
+	SHA256 string `json:"sha256"`
+	// Size of the file in bytes
+	Size int64 `json:"size"`
+}
+
+// ExportSnapshot describes the etcd snapshot taken by an export
+type ExportSnapshot struct {
+	// Unique ID of the snapshot backup
+	ID string `json:"id,omitempty"`
+	// Phase of the snapshot backup
+	Phase string `json:"phase,omitempty"`
+	// Location of the etcd snapshot on the S3 bucket
+	Location string `json:"location,omitempty"`
+}
+
 // PachydermExportStatus defines the observed state of PachydermExport
 type PachydermExportStatus struct {
 	// Phase of the export status
@@ -49,12 +190,29 @@ type PachydermExportStatus struct {
 	StartedAt string `json:"startedAt,omitempty"`
 	// Time the backup process completed
 	CompletedAt string `json:"completedAt,omitempty"`
+	// Time the pachyderm cluster was paused by the export.
+	// It is cleared once the cluster resumes
+	PausedAt string `json:"pausedAt,omitempty"`
 	// Name of backup resource created
 	Name string `json:"name,omitempty"`
 	// Location of pachyderm backup on the S3 bucket
 	Location string `json:"location,omitempty"`
 	// Status reports the state of the restore request
 	Status string `json:"status,omitempty"`
+	// Digests and sizes of the files in the backup
+	Artifacts []ExportArtifact `json:"artifacts,omitempty"`
+	// Verified is true when the database dump was
+	// successfully restored into a test database
+	Verified bool `json:"verified,omitempty"`
+	// Time the verification pod was created
+	VerificationStartedAt string `json:"verificationStartedAt,omitempty"`
+	// Mode used to take the backup
+	Mode string `json:"mode,omitempty"`
+	// Consistency describes the guarantees
+	// provided by the backup for the mode used
+	Consistency string `json:"consistency,omitempty"`
+	// Etcd snapshot taken with the backup
+	EtcdSnapshot *ExportSnapshot `json:"etcdSnapshot,omitempty"`
 }
 
 //+kubebuilder:object:root=true
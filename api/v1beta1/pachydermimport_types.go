@@ -55,6 +55,9 @@ type PachydermImportStatus struct {
 	CompletedAt string `json:"completedAt,omitempty"`
 	// Status reports the state of the restore request
 	Status string `json:"status,omitempty"`
+	// Verified is true when the backup contents match
+	// the digests recorded by the pachyderm export
+	Verified bool `json:"verified,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 Pachyderm.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PachydermStorageMigrationSpec defines the desired state of PachydermStorageMigration
+type PachydermStorageMigrationSpec struct {
+	// Name of Pachyderm instance whose objects are migrated
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Target",xDescriptors={"urn:alm:descriptor:text"}
+	Target string `json:"target"`
+
+	// Object storage the objects are copied to. The storage
+	// options of the pachyderm instance are replaced with it
+	// once the copy is verified
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Destination"
+	Destination ObjectStorageOptions `json:"destination"`
+
+	// Verification method used after the copy.
+	// Checksum compares the sizes and hashes reported by both
+	// object stores, falling back to sizes when they share no hash.
+	// Download reads back and compares the content of every object.
+	// Defaults to Checksum
+	//+kubebuilder:validation:Enum:=Checksum;Download
+	//+kubebuilder:default:=Checksum
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Verification",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:Checksum","urn:alm:descriptor:com.tectonic.ui:select:Download"}
+	Verification string `json:"verification,omitempty"`
+
+	// Number of objects copied in parallel.
+	// Defaults to 8
+	//+kubebuilder:validation:Minimum:=1
+	//+kubebuilder:default:=8
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Transfers",xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:io.kubernetes:advanced"}
+	Transfers int32 `json:"transfers,omitempty"`
+
+	// Image of the rclone container that copies the objects.
+	// Defaults to the rclone image shipped with the version of pachyderm
+	//+operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Image",xDescriptors={"urn:alm:descriptor:text","urn:alm:descriptor:io.kubernetes:advanced"}
+	Image string `json:"image,omitempty"`
+}
+
+const (
+	// Verification comparing object sizes and hashes
+	MigrationChecksumVerification string = "Checksum"
+	// Verification comparing the content of every object
+	MigrationDownloadVerification string = "Download"
+)
+
+const (
+	// Sets status of the migration to pausing while pachd scales down
+	MigrationPausingStatus string = "Pausing"
+	// Sets status of the migration to copying
+	MigrationCopyingStatus string = "Copying"
+	// Sets status of the migration to verifying
+	MigrationVerifyingStatus string = "Verifying"
+	// Sets status of the migration to completed
+	MigrationCompletedStatus string = "Completed"
+	// Sets status of the migration to failed
+	MigrationFailedStatus string = "Failed"
+)
+
+// StorageMigrationStats describes the objects
+// found in a bucket by the migration job
+type StorageMigrationStats struct {
+	// Number of objects in the bucket
+	Objects int64 `json:"objects"`
+	// Total size of the objects in bytes
+	Bytes int64 `json:"bytes"`
+}
+
+// PachydermStorageMigrationStatus defines the observed state of PachydermStorageMigration
+type PachydermStorageMigrationStatus struct {
+	// Phase of the migration
+	Phase string `json:"phase,omitempty"`
+	// Time the migration commenced
+	StartedAt string `json:"startedAt,omitempty"`
+	// Time the migration completed or failed
+	CompletedAt string `json:"completedAt,omitempty"`
+	// Location the objects are copied from
+	Source string `json:"source,omitempty"`
+	// Location the objects are copied to
+	Destination string `json:"destination,omitempty"`
+	// Backend the pachyderm instance used before the migration
+	PreviousBackend string `json:"previousBackend,omitempty"`
+	// Objects found in the source bucket after the copy
+	SourceStats *StorageMigrationStats `json:"sourceStats,omitempty"`
+	// Objects found in the destination bucket after the copy
+	DestinationStats *StorageMigrationStats `json:"destinationStats,omitempty"`
+	// Verified is true when the objects in both
+	// buckets matched the verification method
+	Verified bool `json:"verified,omitempty"`
+	// Status reports the state of the migration request
+	Status string `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// PachydermStorageMigration is the Schema for the pachydermstoragemigrations API
+type PachydermStorageMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PachydermStorageMigrationSpec   `json:"spec,omitempty"`
+	Status PachydermStorageMigrationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PachydermStorageMigrationList contains a list of PachydermStorageMigration
+type PachydermStorageMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PachydermStorageMigration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PachydermStorageMigration{}, &PachydermStorageMigrationList{})
+}
@@ -23,12 +23,18 @@ package v1beta1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AmazonStorageOptions) DeepCopyInto(out *AmazonStorageOptions) {
 	*out = *in
+	if in.VerifySSL != nil {
+		in, out := &in.VerifySSL, &out.VerifySSL
+		*out = new(bool)
+		**out = **in
+	}
 	if in.Reverse != nil {
 		in, out := &in.Reverse, &out.Reverse
 		*out = new(bool)
@@ -37,7 +43,7 @@ func (in *AmazonStorageOptions) DeepCopyInto(out *AmazonStorageOptions) {
 	if in.Vault != nil {
 		in, out := &in.Vault, &out.Vault
 		*out = new(AmazonStorageVault)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -54,6 +60,21 @@ func (in *AmazonStorageOptions) DeepCopy() *AmazonStorageOptions {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AmazonStorageVault) DeepCopyInto(out *AmazonStorageVault) {
 	*out = *in
+	if in.Kubernetes != nil {
+		in, out := &in.Kubernetes, &out.Kubernetes
+		*out = new(VaultKubernetesAuth)
+		**out = **in
+	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(CABundleSource)
+		**out = **in
+	}
+	if in.CABundleData != nil {
+		in, out := &in.CABundleData, &out.CABundleData
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmazonStorageVault.
@@ -66,6 +87,51 @@ func (in *AmazonStorageVault) DeepCopy() *AmazonStorageVault {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureDestination) DeepCopyInto(out *AzureDestination) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureDestination.
+func (in *AzureDestination) DeepCopy() *AzureDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureWorkloadIdentity) DeepCopyInto(out *AzureWorkloadIdentity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureWorkloadIdentity.
+func (in *AzureWorkloadIdentity) DeepCopy() *AzureWorkloadIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureWorkloadIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CABundleSource) DeepCopyInto(out *CABundleSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CABundleSource.
+func (in *CABundleSource) DeepCopy() *CABundleSource {
+	if in == nil {
+		return nil
+	}
+	out := new(CABundleSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConsoleOptions) DeepCopyInto(out *ConsoleOptions) {
 	*out = *in
@@ -96,6 +162,76 @@ func (in *ConsoleOptions) DeepCopy() *ConsoleOptions {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomStorageOptions) DeepCopyInto(out *CustomStorageOptions) {
+	*out = *in
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(CABundleSource)
+		**out = **in
+	}
+	if in.CABundleData != nil {
+		in, out := &in.CABundleData, &out.CABundleData
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomStorageOptions.
+func (in *CustomStorageOptions) DeepCopy() *CustomStorageOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomStorageOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdMaintenanceOptions) DeepCopyInto(out *EtcdMaintenanceOptions) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.DefragmentThreshold != nil {
+		in, out := &in.DefragmentThreshold, &out.DefragmentThreshold
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdMaintenanceOptions.
+func (in *EtcdMaintenanceOptions) DeepCopy() *EtcdMaintenanceOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdMaintenanceOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdMemberStatus) DeepCopyInto(out *EtcdMemberStatus) {
+	*out = *in
+	if in.Alarms != nil {
+		in, out := &in.Alarms, &out.Alarms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdMemberStatus.
+func (in *EtcdMemberStatus) DeepCopy() *EtcdMemberStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdMemberStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EtcdOptions) DeepCopyInto(out *EtcdOptions) {
 	*out = *in
@@ -114,6 +250,12 @@ func (in *EtcdOptions) DeepCopyInto(out *EtcdOptions) {
 		*out = new(ServiceOverrides)
 		(*in).DeepCopyInto(*out)
 	}
+	in.Maintenance.DeepCopyInto(&out.Maintenance)
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(EtcdTLSOptions)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdOptions.
@@ -126,6 +268,160 @@ func (in *EtcdOptions) DeepCopy() *EtcdOptions {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdStatus) DeepCopyInto(out *EtcdStatus) {
+	*out = *in
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastMaintenanceTime != nil {
+		in, out := &in.LastMaintenanceTime, &out.LastMaintenanceTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CertificatesExpireTime != nil {
+		in, out := &in.CertificatesExpireTime, &out.CertificatesExpireTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]EtcdMemberStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdStatus.
+func (in *EtcdStatus) DeepCopy() *EtcdStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdTLSOptions) DeepCopyInto(out *EtcdTLSOptions) {
+	*out = *in
+	if in.Validity != nil {
+		in, out := &in.Validity, &out.Validity
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RenewBefore != nil {
+		in, out := &in.RenewBefore, &out.RenewBefore
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.CABundleData != nil {
+		in, out := &in.CABundleData, &out.CABundleData
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientCertificate != nil {
+		in, out := &in.ClientCertificate, &out.ClientCertificate
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientKey != nil {
+		in, out := &in.ClientKey, &out.ClientKey
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdTLSOptions.
+func (in *EtcdTLSOptions) DeepCopy() *EtcdTLSOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdTLSOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportArtifact) DeepCopyInto(out *ExportArtifact) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportArtifact.
+func (in *ExportArtifact) DeepCopy() *ExportArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportDestination) DeepCopyInto(out *ExportDestination) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3Destination)
+		**out = **in
+	}
+	if in.GCS != nil {
+		in, out := &in.GCS, &out.GCS
+		*out = new(GCSDestination)
+		**out = **in
+	}
+	if in.Azure != nil {
+		in, out := &in.Azure, &out.Azure
+		*out = new(AzureDestination)
+		**out = **in
+	}
+	if in.PVC != nil {
+		in, out := &in.PVC, &out.PVC
+		*out = new(PVCDestination)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportDestination.
+func (in *ExportDestination) DeepCopy() *ExportDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportSnapshot) DeepCopyInto(out *ExportSnapshot) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportSnapshot.
+func (in *ExportSnapshot) DeepCopy() *ExportSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCSDestination) DeepCopyInto(out *GCSDestination) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSDestination.
+func (in *GCSDestination) DeepCopy() *GCSDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(GCSDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GoogleStorageOptions) DeepCopyInto(out *GoogleStorageOptions) {
 	*out = *in
@@ -161,6 +457,21 @@ func (in *ImageOverride) DeepCopy() *ImageOverride {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalStorageOptions) DeepCopyInto(out *LocalStorageOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalStorageOptions.
+func (in *LocalStorageOptions) DeepCopy() *LocalStorageOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalStorageOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricsOptions) DeepCopyInto(out *MetricsOptions) {
 	*out = *in
@@ -179,6 +490,11 @@ func (in *MetricsOptions) DeepCopy() *MetricsOptions {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MicrosoftStorageOptions) DeepCopyInto(out *MicrosoftStorageOptions) {
 	*out = *in
+	if in.WorkloadIdentity != nil {
+		in, out := &in.WorkloadIdentity, &out.WorkloadIdentity
+		*out = new(AzureWorkloadIdentity)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MicrosoftStorageOptions.
@@ -194,6 +510,21 @@ func (in *MicrosoftStorageOptions) DeepCopy() *MicrosoftStorageOptions {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MinioStorageOptions) DeepCopyInto(out *MinioStorageOptions) {
 	*out = *in
+	if in.UseTLS != nil {
+		in, out := &in.UseTLS, &out.UseTLS
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(CABundleSource)
+		**out = **in
+	}
+	if in.CABundleData != nil {
+		in, out := &in.CABundleData, &out.CABundleData
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MinioStorageOptions.
@@ -222,11 +553,21 @@ func (in *ObjectStorageOptions) DeepCopyInto(out *ObjectStorageOptions) {
 	if in.Microsoft != nil {
 		in, out := &in.Microsoft, &out.Microsoft
 		*out = new(MicrosoftStorageOptions)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Minio != nil {
 		in, out := &in.Minio, &out.Minio
 		*out = new(MinioStorageOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Custom != nil {
+		in, out := &in.Custom, &out.Custom
+		*out = new(CustomStorageOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Local != nil {
+		in, out := &in.Local, &out.Local
+		*out = new(LocalStorageOptions)
 		**out = **in
 	}
 }
@@ -241,6 +582,41 @@ func (in *ObjectStorageOptions) DeepCopy() *ObjectStorageOptions {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PGBouncerOptions) DeepCopyInto(out *PGBouncerOptions) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PGBouncerOptions.
+func (in *PGBouncerOptions) DeepCopy() *PGBouncerOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PGBouncerOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PVCDestination) DeepCopyInto(out *PVCDestination) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCDestination.
+func (in *PVCDestination) DeepCopy() *PVCDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(PVCDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PachdOptions) DeepCopyInto(out *PachdOptions) {
 	*out = *in
@@ -261,7 +637,12 @@ func (in *PachdOptions) DeepCopyInto(out *PachdOptions) {
 		(*in).DeepCopyInto(*out)
 	}
 	out.Metrics = in.Metrics
-	out.Postgres = in.Postgres
+	in.Postgres.DeepCopyInto(&out.Postgres)
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(PachdTLSOptions)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachdOptions.
@@ -277,6 +658,31 @@ func (in *PachdOptions) DeepCopy() *PachdOptions {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PachdPostgresConfig) DeepCopyInto(out *PachdPostgresConfig) {
 	*out = *in
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(CABundleSource)
+		**out = **in
+	}
+	if in.CABundleData != nil {
+		in, out := &in.CABundleData, &out.CABundleData
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientCertificate != nil {
+		in, out := &in.ClientCertificate, &out.ClientCertificate
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientKey != nil {
+		in, out := &in.ClientKey, &out.ClientKey
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.PasswordRotation != nil {
+		in, out := &in.PasswordRotation, &out.PasswordRotation
+		*out = new(PasswordRotationOptions)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachdPostgresConfig.
@@ -289,13 +695,33 @@ func (in *PachdPostgresConfig) DeepCopy() *PachdPostgresConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PachdTLSOptions) DeepCopyInto(out *PachdTLSOptions) {
+	*out = *in
+	if in.CABundleData != nil {
+		in, out := &in.CABundleData, &out.CABundleData
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachdTLSOptions.
+func (in *PachdTLSOptions) DeepCopy() *PachdTLSOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PachdTLSOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Pachyderm) DeepCopyInto(out *Pachyderm) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pachyderm.
@@ -321,8 +747,8 @@ func (in *PachydermExport) DeepCopyInto(out *PachydermExport) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachydermExport.
@@ -378,6 +804,21 @@ func (in *PachydermExportList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PachydermExportSpec) DeepCopyInto(out *PachydermExportSpec) {
 	*out = *in
+	if in.Destination != nil {
+		in, out := &in.Destination, &out.Destination
+		*out = new(ExportDestination)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VerificationTimeout != nil {
+		in, out := &in.VerificationTimeout, &out.VerificationTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PauseTimeout != nil {
+		in, out := &in.PauseTimeout, &out.PauseTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachydermExportSpec.
@@ -393,6 +834,16 @@ func (in *PachydermExportSpec) DeepCopy() *PachydermExportSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PachydermExportStatus) DeepCopyInto(out *PachydermExportStatus) {
 	*out = *in
+	if in.Artifacts != nil {
+		in, out := &in.Artifacts, &out.Artifacts
+		*out = make([]ExportArtifact, len(*in))
+		copy(*out, *in)
+	}
+	if in.EtcdSnapshot != nil {
+		in, out := &in.EtcdSnapshot, &out.EtcdSnapshot
+		*out = new(ExportSnapshot)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachydermExportStatus.
@@ -535,6 +986,7 @@ func (in *PachydermSpec) DeepCopyInto(out *PachydermSpec) {
 	in.Console.DeepCopyInto(&out.Console)
 	in.Worker.DeepCopyInto(&out.Worker)
 	in.Postgres.DeepCopyInto(&out.Postgres)
+	in.PGBouncer.DeepCopyInto(&out.PGBouncer)
 	if in.ImagePullSecret != nil {
 		in, out := &in.ImagePullSecret, &out.ImagePullSecret
 		*out = new(string)
@@ -555,6 +1007,32 @@ func (in *PachydermSpec) DeepCopy() *PachydermSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PachydermStatus) DeepCopyInto(out *PachydermStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VaultLease != nil {
+		in, out := &in.VaultLease, &out.VaultLease
+		*out = new(VaultLeaseStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostgresPasswordRotatedAt != nil {
+		in, out := &in.PostgresPasswordRotatedAt, &out.PostgresPasswordRotatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]VolumeStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Etcd != nil {
+		in, out := &in.Etcd, &out.Etcd
+		*out = new(EtcdStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachydermStatus.
@@ -567,6 +1045,141 @@ func (in *PachydermStatus) DeepCopy() *PachydermStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PachydermStorageMigration) DeepCopyInto(out *PachydermStorageMigration) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachydermStorageMigration.
+func (in *PachydermStorageMigration) DeepCopy() *PachydermStorageMigration {
+	if in == nil {
+		return nil
+	}
+	out := new(PachydermStorageMigration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PachydermStorageMigration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PachydermStorageMigrationList) DeepCopyInto(out *PachydermStorageMigrationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PachydermStorageMigration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachydermStorageMigrationList.
+func (in *PachydermStorageMigrationList) DeepCopy() *PachydermStorageMigrationList {
+	if in == nil {
+		return nil
+	}
+	out := new(PachydermStorageMigrationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PachydermStorageMigrationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PachydermStorageMigrationSpec) DeepCopyInto(out *PachydermStorageMigrationSpec) {
+	*out = *in
+	in.Destination.DeepCopyInto(&out.Destination)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachydermStorageMigrationSpec.
+func (in *PachydermStorageMigrationSpec) DeepCopy() *PachydermStorageMigrationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PachydermStorageMigrationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PachydermStorageMigrationStatus) DeepCopyInto(out *PachydermStorageMigrationStatus) {
+	*out = *in
+	if in.SourceStats != nil {
+		in, out := &in.SourceStats, &out.SourceStats
+		*out = new(StorageMigrationStats)
+		**out = **in
+	}
+	if in.DestinationStats != nil {
+		in, out := &in.DestinationStats, &out.DestinationStats
+		*out = new(StorageMigrationStats)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PachydermStorageMigrationStatus.
+func (in *PachydermStorageMigrationStatus) DeepCopy() *PachydermStorageMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PachydermStorageMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PasswordRotationOptions) DeepCopyInto(out *PasswordRotationOptions) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordRotationOptions.
+func (in *PasswordRotationOptions) DeepCopy() *PasswordRotationOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PasswordRotationOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresHAOptions) DeepCopyInto(out *PostgresHAOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresHAOptions.
+func (in *PostgresHAOptions) DeepCopy() *PostgresHAOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresHAOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresOptions) DeepCopyInto(out *PostgresOptions) {
 	*out = *in
@@ -576,6 +1189,11 @@ func (in *PostgresOptions) DeepCopyInto(out *PostgresOptions) {
 		*out = new(v1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HighAvailability != nil {
+		in, out := &in.HighAvailability, &out.HighAvailability
+		*out = new(PostgresHAOptions)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresOptions.
@@ -603,6 +1221,21 @@ func (in *RestoreDestination) DeepCopy() *RestoreDestination {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3Destination) DeepCopyInto(out *S3Destination) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3Destination.
+func (in *S3Destination) DeepCopy() *S3Destination {
+	if in == nil {
+		return nil
+	}
+	out := new(S3Destination)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceOverrides) DeepCopyInto(out *ServiceOverrides) {
 	*out = *in
@@ -623,6 +1256,74 @@ func (in *ServiceOverrides) DeepCopy() *ServiceOverrides {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageMigrationStats) DeepCopyInto(out *StorageMigrationStats) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageMigrationStats.
+func (in *StorageMigrationStats) DeepCopy() *StorageMigrationStats {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageMigrationStats)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultKubernetesAuth) DeepCopyInto(out *VaultKubernetesAuth) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultKubernetesAuth.
+func (in *VaultKubernetesAuth) DeepCopy() *VaultKubernetesAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultKubernetesAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultLeaseStatus) DeepCopyInto(out *VaultLeaseStatus) {
+	*out = *in
+	if in.RenewTime != nil {
+		in, out := &in.RenewTime, &out.RenewTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpireTime != nil {
+		in, out := &in.ExpireTime, &out.ExpireTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultLeaseStatus.
+func (in *VaultLeaseStatus) DeepCopy() *VaultLeaseStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultLeaseStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeStatus) DeepCopyInto(out *VolumeStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeStatus.
+func (in *VolumeStatus) DeepCopy() *VolumeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkerOptions) DeepCopyInto(out *WorkerOptions) {
 	*out = *in
@@ -0,0 +1,304 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/destinations"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	// directory backups are stored under in a destination
+	backupPrefix string = "backups"
+	// path the backup volume is mounted at
+	storageMountPath string = "/backups"
+)
+
+// backupKey returns the key of a backup in its destination
+func backupKey(location string) string {
+	return path.Join(backupPrefix, path.Base(location))
+}
+
+// secretValues returns the values of the keys in the secret.
+// Optional keys are left empty when missing
+func (r *PachydermExportReconciler) secretValues(ctx context.Context, namespace, name string, required, optional []string) (map[string]string, error) {
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, key := range required {
+		data, ok := secret.Data[key]
+		if !ok {
+			return nil, NewKeyError(
+				fmt.Sprintf("the key %s missing in secret %s",
+					key,
+					secretKey.Name),
+			)
+		}
+		values[key] = string(data)
+	}
+
+	for _, key := range optional {
+		values[key] = string(secret.Data[key])
+	}
+
+	return values, nil
+}
+
+// exportDestination returns the destination the backup of the
+// export is stored in. Exports without a destination are stored
+// in the S3 bucket the backup handler uploads to
+func (r *PachydermExportReconciler) exportDestination(ctx context.Context, export *aimlv1beta1.PachydermExport) (destinations.Destination, error) {
+	destination := export.Spec.Destination
+	if destination == nil {
+		return r.s3Destination(ctx, export, export.Spec.StorageSecret, "")
+	}
+
+	configured := 0
+	for _, set := range []bool{
+		destination.S3 != nil,
+		destination.GCS != nil,
+		destination.Azure != nil,
+		destination.PVC != nil,
+	} {
+		if set {
+			configured++
+		}
+	}
+	if configured != 1 {
+		return nil, fmt.Errorf("%w: exactly one export destination must be set", ErrBackupFailed)
+	}
+
+	switch {
+	case destination.S3 != nil:
+		return r.s3Destination(ctx, export, destination.S3.CredentialSecret, destination.S3.Prefix)
+	case destination.GCS != nil:
+		opts := destinations.GCSOptions{
+			Bucket: destination.GCS.Bucket,
+			Prefix: destination.GCS.Prefix,
+		}
+		// without a secret, the operator signs
+		// uploads with its workload identity
+		if destination.GCS.CredentialSecret != "" {
+			values, err := r.secretValues(ctx, export.Namespace, destination.GCS.CredentialSecret,
+				[]string{"credentials.json"},
+				nil,
+			)
+			if err != nil {
+				return nil, err
+			}
+			opts.CredentialsJSON = []byte(values["credentials.json"])
+		}
+		return destinations.NewGCS(ctx, opts)
+	case destination.Azure != nil:
+		values, err := r.secretValues(ctx, export.Namespace, destination.Azure.CredentialSecret,
+			[]string{"account-name", "account-key"},
+			[]string{"custom-endpoint"},
+		)
+		if err != nil {
+			return nil, err
+		}
+		return destinations.NewAzure(destinations.AzureOptions{
+			Container:   destination.Azure.Container,
+			AccountName: values["account-name"],
+			AccountKey:  values["account-key"],
+			Endpoint:    values["custom-endpoint"],
+			Prefix:      destination.Azure.Prefix,
+		})
+	default:
+		return r.pvcDestination(ctx, export)
+	}
+}
+
+func (r *PachydermExportReconciler) s3Destination(ctx context.Context, export *aimlv1beta1.PachydermExport, secretName, prefix string) (destinations.Destination, error) {
+	values, err := r.secretValues(ctx, export.Namespace, secretName,
+		[]string{"bucket", "region", "access-id", "access-secret"},
+		[]string{"custom-endpoint"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return destinations.NewS3(destinations.S3Options{
+		Bucket:       values["bucket"],
+		Region:       values["region"],
+		AccessID:     values["access-id"],
+		AccessSecret: values["access-secret"],
+		Endpoint:     values["custom-endpoint"],
+		Prefix:       prefix,
+	})
+}
+
+// pvcDestination writes backups to a persistent volume
+// through a pod that mounts the volume claim
+type pvcDestination struct {
+	config *rest.Config
+	pod    types.NamespacedName
+	claim  string
+	root   string
+}
+
+func (d *pvcDestination) filename(key string) string {
+	return path.Join(storageMountPath, d.root, key)
+}
+
+func (d *pvcDestination) Upload(ctx context.Context, key string, content io.Reader) error {
+	_, err := execInPod(d.config, d.pod, "storage",
+		[]string{"sh", "-c", `mkdir -p "$(dirname "$1")" && cat > "$1.partial" && mv "$1.partial" "$1"`, "sh", d.filename(key)},
+		content,
+	)
+	return err
+}
+
+func (d *pvcDestination) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	if _, err := execInPod(d.config, d.pod, "storage", []string{"test", "-f", d.filename(key)}, nil); err != nil {
+		return nil, fmt.Errorf("%w: %s", destinations.ErrObjectNotFound, key)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := streamInPod(d.config, d.pod, "storage", []string{"cat", d.filename(key)}, nil, writer)
+		writer.CloseWithError(err)
+	}()
+
+	return reader, nil
+}
+
+func (d *pvcDestination) Location(key string) string {
+	return pvcLocation(d.claim, d.root, key)
+}
+
+// pvcLocation returns the location of the key on the volume claim
+func pvcLocation(claim, root, key string) string {
+	return fmt.Sprintf("pvc://%s/%s", claim, path.Join(root, key))
+}
+
+func storagePodName(export *aimlv1beta1.PachydermExport) string {
+	return fmt.Sprintf("%s-storage", export.Name)
+}
+
+// pvcDestination returns a destination backed by the volume claim.
+// The pod mounting the claim is created if it does not exist
+func (r *PachydermExportReconciler) pvcDestination(ctx context.Context, export *aimlv1beta1.PachydermExport) (destinations.Destination, error) {
+	pvc := export.Spec.Destination.PVC
+
+	pod := &corev1.Pod{}
+	podKey := types.NamespacedName{
+		Name:      storagePodName(export),
+		Namespace: export.Namespace,
+	}
+	if err := r.Get(ctx, podKey, pod); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+
+		pd, err := r.pachydermForBackup(ctx, export)
+		if err != nil {
+			return nil, err
+		}
+
+		pod, err := newStoragePod(export, pd)
+		if err != nil {
+			return nil, err
+		}
+		if err := controllerutil.SetControllerReference(export, pod, r.Scheme); err != nil {
+			return nil, err
+		}
+		if err := r.Create(ctx, pod); err != nil {
+			return nil, err
+		}
+		return nil, ErrStoragePodNotReady
+	}
+
+	if !isPodReady(pod) {
+		return nil, ErrStoragePodNotReady
+	}
+
+	return &pvcDestination{
+		config: r.Config,
+		pod:    podKey,
+		claim:  pvc.ClaimName,
+		root:   pvc.Path,
+	}, nil
+}
+
+func newStoragePod(export *aimlv1beta1.PachydermExport, pd *aimlv1beta1.Pachyderm) (*corev1.Pod, error) {
+	image, err := generators.PostgresImage(pd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      storagePodName(export),
+			Namespace: export.Namespace,
+			Labels: map[string]string{
+				"app":   "pachyderm-export-storage",
+				"suite": "pachyderm",
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:            "storage",
+					Image:           image.Name(),
+					ImagePullPolicy: image.ImagePullPolicy(),
+					Command:         []string{"sh", "-c", "trap 'exit 0' TERM; sleep infinity & wait"},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "backups",
+							MountPath: storageMountPath,
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "backups",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: export.Spec.Destination.PVC.ClaimName,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// deleteStoragePod removes the pod mounting the backup volume
+func (r *PachydermExportReconciler) deleteStoragePod(ctx context.Context, export *aimlv1beta1.PachydermExport) error {
+	if export.Spec.Destination == nil || export.Spec.Destination.PVC == nil {
+		return nil
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      storagePodName(export),
+			Namespace: export.Namespace,
+		},
+	}
+	if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,599 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/destinations"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	// duration the upload requests handed to the backup job are valid
+	backupUploadExpiry time.Duration = 24 * time.Hour
+	// annotations recording the backup written by a backup job
+	backupNameAnnotation       string = "operator.pachyderm.com/backup-name"
+	backupLocationAnnotation   string = "operator.pachyderm.com/backup-location"
+	snapshotLocationAnnotation string = "operator.pachyderm.com/snapshot-location"
+	// annotations recording the multipart uploads started for a backup job
+	backupUploadAnnotation   string = "operator.pachyderm.com/backup-upload-id"
+	snapshotUploadAnnotation string = "operator.pachyderm.com/snapshot-upload-id"
+	// size of the parts of multipart uploads and the number of parts
+	// presigned, bounding the size of the backup to 100 GiB
+	backupPartSize int64 = 1 << 30
+	backupMaxParts int   = 100
+	// path the backup job secret is mounted at
+	backupConfigPath string = "/etc/pachyderm-backup"
+	// path of the scratch volume the backup is assembled in
+	backupWorkPath string = "/backup"
+)
+
+// backupJobScript dumps the database, packs the backup in the
+// layout used by the backup handler and copies it to a volume
+// or uploads it with the presigned requests in the job secret.
+// Destinations limiting the size of a single upload receive the
+// files in parts, one presigned request per line of the parts file.
+// The digest and size of each file are reported as JSON
+// through the termination message of the container
+const backupJobScript = `set -euo pipefail
+
+upload() {
+  local file="$1" config="/etc/pachyderm-backup/$2"
+  if [ ! -f "${config}.parts" ]; then
+    curl --fail --silent --show-error --config "${config}.conf" --upload-file "${file}"
+    return
+  fi
+
+  local parts size part=0 offset=0
+  mapfile -t parts < "${config}.parts"
+  size="$(stat -c %s "${file}")"
+  if [ "${size}" -gt $(( ${#parts[@]} * BACKUP_PART_SIZE )) ]; then
+    echo "$(basename "${file}") holds ${size} bytes, more than the ${#parts[@]} parts of ${BACKUP_PART_SIZE} bytes the upload accepts" >&2
+    exit 1
+  fi
+  while [ "${part}" -eq 0 ] || [ "${offset}" -lt "${size}" ]; do
+    tail -c "+$(( offset + 1 ))" "${file}" | head -c "${BACKUP_PART_SIZE}" > "${file}.part"
+    curl --fail --silent --show-error --request PUT --upload-file "${file}.part" --url "${parts[${part}]}"
+    part=$(( part + 1 ))
+    offset=$(( offset + BACKUP_PART_SIZE ))
+  done
+  rm -f "${file}.part"
+}
+
+workdir="/backup/${BACKUP_NAME}"
+mkdir -p "${workdir}"
+cp /etc/pachyderm-backup/cr.json "${workdir}/cr.json"
+
+echo "dumping database ${PGDATABASE} from ${PGHOST}:${PGPORT}"
+pg_dump --format=tar > "${workdir}/database.sql"
+
+artifacts=""
+for file in cr.json database.sql; do
+  sum="$(sha256sum "${workdir}/${file}" | cut -d ' ' -f 1)"
+  size="$(stat -c %s "${workdir}/${file}")"
+  artifacts="${artifacts:+${artifacts},}{\"name\":\"${file}\",\"sha256\":\"${sum}\",\"size\":${size}}"
+done
+tar -C /backup -czf "/backup/${BACKUP_NAME}.tar.gz" "${BACKUP_NAME}"
+
+if [ -n "${BACKUP_DIRECTORY:-}" ]; then
+  echo "copying backup to ${BACKUP_DIRECTORY}"
+  mkdir -p "${BACKUP_DIRECTORY}"
+  cp "/backup/${BACKUP_NAME}.tar.gz" "${BACKUP_DIRECTORY}/${BACKUP_NAME}.tar.gz.partial"
+  mv "${BACKUP_DIRECTORY}/${BACKUP_NAME}.tar.gz.partial" "${BACKUP_DIRECTORY}/${BACKUP_NAME}.tar.gz"
+  if [ -f /backup/etcd.db ]; then
+    cp /backup/etcd.db "${BACKUP_DIRECTORY}/${BACKUP_NAME}-etcd.db"
+  fi
+else
+  echo "uploading backup ${BACKUP_NAME}"
+  upload "/backup/${BACKUP_NAME}.tar.gz" backup
+  if [ -f /backup/etcd.db ]; then
+    echo "uploading etcd snapshot"
+    upload /backup/etcd.db etcd
+  fi
+fi
+
+printf '{"artifacts":[%s]}' "${artifacts}" > /dev/termination-log
+echo "backup ${BACKUP_NAME} completed"
+`
+
+func backupJobName(export *aimlv1beta1.PachydermExport) string {
+	return fmt.Sprintf("%s-backup", export.Name)
+}
+
+// curlConfig renders the presigned request as a curl config file
+func curlConfig(request *destinations.PresignedRequest) string {
+	quote := func(value string) string {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		return fmt.Sprintf(`"%s"`, strings.ReplaceAll(value, `"`, `\"`))
+	}
+
+	config := &strings.Builder{}
+	fmt.Fprintf(config, "url = %s\n", quote(request.URL))
+	fmt.Fprintf(config, "request = %s\n", quote(request.Method))
+
+	headers := []string{}
+	for name := range request.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	for _, name := range headers {
+		fmt.Fprintf(config, "header = %s\n", quote(fmt.Sprintf("%s: %s", name, request.Headers[name])))
+	}
+
+	return config.String()
+}
+
+// backupJobConfig returns the contents of the secret mounted in the
+// backup job and the annotations recording the locations the backup
+// and snapshot are written to and the multipart uploads started
+func (r *PachydermExportReconciler) backupJobConfig(ctx context.Context, export *aimlv1beta1.PachydermExport, pd *aimlv1beta1.Pachyderm, name string) (map[string]string, map[string]string, error) {
+	resource, err := json.Marshal(pd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	backupKey := path.Join(backupPrefix, fmt.Sprintf("%s.tar.gz", name))
+	snapshotKey := path.Join(backupPrefix, fmt.Sprintf("%s-etcd.db", name))
+	config := map[string]string{
+		"cr.json": string(resource),
+	}
+	locations := map[string]string{}
+
+	// volume destinations are mounted in the job
+	if pvc := exportVolume(export); pvc != nil {
+		locations[backupLocationAnnotation] = pvcLocation(pvc.ClaimName, pvc.Path, backupKey)
+		if export.Spec.IncludeEtcd {
+			locations[snapshotLocationAnnotation] = pvcLocation(pvc.ClaimName, pvc.Path, snapshotKey)
+		}
+		return config, locations, nil
+	}
+
+	destination, err := r.exportDestination(ctx, export)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	presigner, ok := destination.(destinations.Presigner)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: destination does not support uploads from a job", ErrBackupFailed)
+	}
+
+	// files are named after the upload in the job secret
+	type jobUpload struct {
+		file       string
+		key        string
+		annotation string
+	}
+	uploads := []jobUpload{
+		{file: "backup", key: backupKey, annotation: backupUploadAnnotation},
+	}
+	locations[backupLocationAnnotation] = destination.Location(backupKey)
+	if export.Spec.IncludeEtcd {
+		uploads = append(uploads, jobUpload{file: "etcd", key: snapshotKey, annotation: snapshotUploadAnnotation})
+		locations[snapshotLocationAnnotation] = destination.Location(snapshotKey)
+	}
+
+	for _, upload := range uploads {
+		if multipart, ok := destination.(destinations.MultipartPresigner); ok {
+			started, err := multipart.PresignMultipartUpload(ctx, upload.key, backupMaxParts, backupUploadExpiry)
+			if err != nil {
+				return nil, nil, err
+			}
+			locations[upload.annotation] = started.ID
+
+			parts := &strings.Builder{}
+			for _, part := range started.Parts {
+				fmt.Fprintln(parts, part.URL)
+			}
+			config[upload.file+".parts"] = parts.String()
+			continue
+		}
+
+		request, err := presigner.PresignUpload(ctx, upload.key, backupUploadExpiry)
+		if err != nil {
+			return nil, nil, err
+		}
+		config[upload.file+".conf"] = curlConfig(request)
+	}
+
+	return config, locations, nil
+}
+
+// exportVolume returns the volume claim
+// destination of the export, if any
+func exportVolume(export *aimlv1beta1.PachydermExport) *aimlv1beta1.PVCDestination {
+	if export.Spec.Destination == nil {
+		return nil
+	}
+	return export.Spec.Destination.PVC
+}
+
+func newBackupJob(export *aimlv1beta1.PachydermExport, pd *aimlv1beta1.Pachyderm, name string, locations map[string]string) (*batchv1.Job, error) {
+	image, err := generators.PostgresImage(pd)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := map[string]string{
+		backupNameAnnotation: name,
+	}
+	for key, value := range locations {
+		annotations[key] = value
+	}
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "BACKUP_NAME",
+			Value: name,
+		},
+		{
+			Name:  "BACKUP_PART_SIZE",
+			Value: strconv.FormatInt(backupPartSize, 10),
+		},
+		{
+			Name:  "PGHOST",
+			Value: "postgres",
+		},
+		{
+			Name:  "PGPORT",
+			Value: "5432",
+		},
+		{
+			Name:  "PGUSER",
+			Value: pd.PostgresLoginUser(),
+		},
+		{
+			Name:  "PGDATABASE",
+			Value: pd.Spec.Pachd.Postgres.Database,
+		},
+		{
+			Name: "PGPASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: "postgres",
+					},
+					Key: "postgresql-password",
+				},
+			},
+		},
+	}
+
+	mounts := []corev1.VolumeMount{
+		{
+			Name:      "workspace",
+			MountPath: backupWorkPath,
+		},
+		{
+			Name:      "config",
+			MountPath: backupConfigPath,
+			ReadOnly:  true,
+		},
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name: "workspace",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+		{
+			Name: "config",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: backupJobName(export),
+				},
+			},
+		},
+	}
+
+	if pvc := exportVolume(export); pvc != nil {
+		env = append(env, corev1.EnvVar{
+			Name:  "BACKUP_DIRECTORY",
+			Value: path.Join(storageMountPath, pvc.Path, backupPrefix),
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      "backups",
+			MountPath: storageMountPath,
+		})
+		volumes = append(volumes, corev1.Volume{
+			Name: "backups",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvc.ClaimName,
+				},
+			},
+		})
+	}
+
+	// the etcd snapshot is saved before the database is dumped
+	initContainers := []corev1.Container{}
+	if export.Spec.IncludeEtcd {
+		etcdImage, err := generators.EtcdImage(pd)
+		if err != nil {
+			return nil, err
+		}
+
+		etcdFlags, etcdVolumes, etcdMounts := generators.EtcdClientOptions(pd)
+		volumes = append(volumes, etcdVolumes...)
+
+		command := append([]string{"etcdctl"}, etcdFlags...)
+		initContainers = append(initContainers, corev1.Container{
+			Name:            "etcd-snapshot",
+			Image:           etcdImage.Name(),
+			ImagePullPolicy: etcdImage.ImagePullPolicy(),
+			Command: append(command,
+				"snapshot", "save",
+				path.Join(backupWorkPath, "etcd.db"),
+			),
+			Env: []corev1.EnvVar{
+				{
+					Name:  "ETCDCTL_API",
+					Value: "3",
+				},
+			},
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+			VolumeMounts: append([]corev1.VolumeMount{
+				{
+					Name:      "workspace",
+					MountPath: backupWorkPath,
+				},
+			}, etcdMounts...),
+		})
+	}
+
+	var backoffLimit int32 = 0
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        backupJobName(export),
+			Namespace:   export.Namespace,
+			Annotations: annotations,
+			Labels: map[string]string{
+				"app":   "pachyderm-export",
+				"suite": "pachyderm",
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app":   "pachyderm-export",
+						"suite": "pachyderm",
+					},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:  corev1.RestartPolicyNever,
+					InitContainers: initContainers,
+					Containers: []corev1.Container{
+						{
+							Name:            "backup",
+							Image:           image.Name(),
+							ImagePullPolicy: image.ImagePullPolicy(),
+							Command:         []string{"bash", "-c", backupJobScript},
+							Env:             env,
+							VolumeMounts:    mounts,
+							// failures are reported with the end of the log
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}, nil
+}
+
+// startBackupJob creates the backup job and the secret holding its
+// configuration. The existing job is returned if it was already created
+func (r *PachydermExportReconciler) startBackupJob(ctx context.Context, export *aimlv1beta1.PachydermExport, pd *aimlv1beta1.Pachyderm) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+	jobKey := types.NamespacedName{
+		Name:      backupJobName(export),
+		Namespace: export.Namespace,
+	}
+	if err := r.Get(ctx, jobKey, job); err == nil {
+		return job, nil
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("pachyderm-backup-%s-%s", export.Name, time.Now().UTC().Format("200601021504"))
+	config, locations, err := r.backupJobConfig(ctx, export, pd, name)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupJobName(export),
+			Namespace: export.Namespace,
+			Labels: map[string]string{
+				"app":   "pachyderm-export",
+				"suite": "pachyderm",
+			},
+		},
+		StringData: config,
+	}
+	if err := controllerutil.SetControllerReference(export, secret, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, secret); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return nil, err
+		}
+
+		// replace the upload requests left by an earlier attempt
+		existing := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(secret), existing); err != nil {
+			return nil, err
+		}
+		existing.StringData = config
+		if err := r.Update(ctx, existing); err != nil {
+			return nil, err
+		}
+	}
+
+	// the dump is taken as the role the
+	// password in the postgres secret belongs to
+	if err := postgresLoginUser(ctx, r.Client, pd); err != nil {
+		return nil, err
+	}
+
+	job, err = newBackupJob(export, pd, name, locations)
+	if err != nil {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(export, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func isJobFailed(job *batchv1.Job) (bool, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return true, condition.Message
+		}
+	}
+	return false, ""
+}
+
+// finishUploads completes or aborts the multipart uploads started
+// for the backup job, forgetting each upload once it is finished
+func (r *PachydermExportReconciler) finishUploads(ctx context.Context, export *aimlv1beta1.PachydermExport, job *batchv1.Job, complete bool) error {
+	locations := map[string]string{
+		backupUploadAnnotation:   backupLocationAnnotation,
+		snapshotUploadAnnotation: snapshotLocationAnnotation,
+	}
+
+	var multipart destinations.MultipartPresigner
+	for _, annotation := range []string{backupUploadAnnotation, snapshotUploadAnnotation} {
+		id := job.Annotations[annotation]
+		if id == "" {
+			continue
+		}
+
+		if multipart == nil {
+			destination, err := r.exportDestination(ctx, export)
+			if err != nil {
+				return err
+			}
+			presigner, ok := destination.(destinations.MultipartPresigner)
+			if !ok {
+				return fmt.Errorf("destination does not support multipart uploads")
+			}
+			multipart = presigner
+		}
+
+		finish := multipart.AbortMultipartUpload
+		if complete {
+			finish = multipart.CompleteMultipartUpload
+		}
+		if err := finish(ctx, backupKey(job.Annotations[locations[annotation]]), id); err != nil {
+			return err
+		}
+
+		current := job.DeepCopy()
+		delete(job.Annotations, annotation)
+		if err := r.Patch(ctx, job, client.MergeFrom(current)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// jobPod returns the pod started by the job
+func jobPod(ctx context.Context, c client.Reader, job *batchv1.Job) (*corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	listOptions := &client.ListOptions{
+		Namespace:     job.Namespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{"job-name": job.Name}),
+	}
+	if err := c.List(ctx, pods, listOptions); err != nil {
+		return nil, err
+	}
+
+	if len(pods.Items) == 0 {
+		return nil, nil
+	}
+
+	return &pods.Items[0], nil
+}
+
+// containerTermination returns the state of the
+// named container of the pod once it has exited
+func containerTermination(pod *corev1.Pod, name string) *corev1.ContainerStateTerminated {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if status.Name == name {
+				return status.State.Terminated
+			}
+		}
+	}
+	return nil
+}
+
+// stopBackupJob deletes the backup job if it is still
+// running and discards the parts it has uploaded
+func (r *PachydermExportReconciler) stopBackupJob(ctx context.Context, export *aimlv1beta1.PachydermExport) error {
+	job := &batchv1.Job{}
+	jobKey := types.NamespacedName{
+		Name:      backupJobName(export),
+		Namespace: export.Namespace,
+	}
+	if err := r.Get(ctx, jobKey, job); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if err := r.finishUploads(ctx, export, job, false); err != nil {
+		return err
+	}
+
+	if job.Status.Active == 0 {
+		return nil
+	}
+
+	return r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+}
+
+// deleteBackupJobSecret removes the upload credentials
+// once the backup job no longer needs them
+func (r *PachydermExportReconciler) deleteBackupJobSecret(ctx context.Context, export *aimlv1beta1.PachydermExport) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupJobName(export),
+			Namespace: export.Namespace,
+		},
+	}
+	if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
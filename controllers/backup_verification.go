@@ -0,0 +1,549 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/destinations"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	// name of the file holding the pachyderm resource in the backup
+	backupResourceFile string = "cr.json"
+	// name of the file holding the database dump in the backup
+	backupDatabaseFile string = "database.sql"
+	// query used to check the restored database contains pachyderm tables
+	verificationQuery string = "SELECT count(*) FROM information_schema.tables WHERE table_schema NOT IN ('pg_catalog', 'information_schema');"
+	// duration the verification may take when
+	// spec.verificationTimeout is not set
+	defaultVerificationTimeout time.Duration = 30 * time.Minute
+	// path the verification secret is mounted at
+	verificationConfigPath string = "/etc/pachyderm-verify"
+	// path of the volume the database dump is extracted to
+	verificationRestorePath string = "/restore"
+)
+
+// verificationFetchScript extracts the database dump from the backup,
+// read from the volume claim or downloaded with the presigned request
+const verificationFetchScript = `set -euo pipefail
+if [ -n "${BACKUP_FILE:-}" ]; then
+  echo "reading backup ${BACKUP_FILE}"
+  tar -xzf "${BACKUP_FILE}" -C /restore --strip-components=1 --wildcards '*/database.sql'
+else
+  echo "downloading backup"
+  curl --fail --silent --show-error --config /etc/pachyderm-verify/download.conf \
+    | tar -xzf - -C /restore --strip-components=1 --wildcards '*/database.sql'
+fi
+`
+
+// verificationScript starts postgresql, restores the dump
+// and reports the number of restored tables as JSON
+const verificationScript = `set -euo pipefail
+run-postgresql &
+server=$!
+until pg_isready --host 127.0.0.1 --quiet; do
+  if ! kill -0 "${server}" 2>/dev/null; then
+    echo "postgresql exited before accepting connections"
+    exit 1
+  fi
+  sleep 1
+done
+
+connection=(--host 127.0.0.1 --username "${POSTGRESQL_USER}" --dbname "${POSTGRESQL_DATABASE}")
+echo "restoring database ${POSTGRESQL_DATABASE}"
+pg_restore --no-owner --no-privileges --exit-on-error "${connection[@]}" /restore/database.sql
+tables="$(psql --tuples-only --no-align --command "${VERIFICATION_QUERY}" "${connection[@]}")"
+printf '{"tables":%d}' "${tables}" > /dev/termination-log
+`
+
+// backupJobResult holds the digests reported
+// by the backup container of the backup job
+type backupJobResult struct {
+	Artifacts []aimlv1beta1.ExportArtifact `json:"artifacts"`
+}
+
+func digest(name string, content io.Reader) (*aimlv1beta1.ExportArtifact, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aimlv1beta1.ExportArtifact{
+		Name:   name,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+		Size:   size,
+	}, nil
+}
+
+// recordBackupArtifacts records the digest and size of the files
+// in the backup, as reported by the backup job, in the export status
+func (r *PachydermExportReconciler) recordBackupArtifacts(ctx context.Context, export *aimlv1beta1.PachydermExport, job *batchv1.Job) error {
+	if len(export.Status.Artifacts) > 0 {
+		return nil
+	}
+
+	pod, err := jobPod(ctx, r.Client, job)
+	if err != nil || pod == nil {
+		return err
+	}
+
+	// backups without digests are restored without checking them
+	terminated := containerTermination(pod, "backup")
+	if terminated == nil || terminated.Message == "" {
+		return nil
+	}
+
+	result := &backupJobResult{}
+	if err := json.Unmarshal([]byte(terminated.Message), result); err != nil {
+		log.FromContext(ctx).Info("unable to read the backup digests", "export", export.Name, "error", err.Error())
+		return nil
+	}
+	export.Status.Artifacts = result.Artifacts
+
+	return nil
+}
+
+func verificationPodName(export *aimlv1beta1.PachydermExport) string {
+	return fmt.Sprintf("%s-verify", export.Name)
+}
+
+// verificationTimeout returns the duration the verification may take
+func verificationTimeout(export *aimlv1beta1.PachydermExport) time.Duration {
+	if export.Spec.VerificationTimeout != nil {
+		return export.Spec.VerificationTimeout.Duration
+	}
+	return defaultVerificationTimeout
+}
+
+func newVerificationPod(export *aimlv1beta1.PachydermExport, pd *aimlv1beta1.Pachyderm) (*corev1.Pod, error) {
+	image, err := generators.PostgresImage(pd)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchEnv := []corev1.EnvVar{}
+	fetchMounts := []corev1.VolumeMount{
+		{
+			Name:      "restore",
+			MountPath: verificationRestorePath,
+		},
+	}
+	volumes := []corev1.Volume{
+		{
+			Name: "data",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+		{
+			Name: "restore",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+	}
+
+	// volume destinations are read in place, other
+	// destinations with the presigned request in the secret
+	if pvc := exportVolume(export); pvc != nil {
+		fetchEnv = append(fetchEnv, corev1.EnvVar{
+			Name:  "BACKUP_FILE",
+			Value: path.Join(storageMountPath, pvc.Path, backupKey(export.Status.Location)),
+		})
+		fetchMounts = append(fetchMounts, corev1.VolumeMount{
+			Name:      "backups",
+			MountPath: storageMountPath,
+			ReadOnly:  true,
+		})
+		volumes = append(volumes, corev1.Volume{
+			Name: "backups",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvc.ClaimName,
+					ReadOnly:  true,
+				},
+			},
+		})
+	} else {
+		fetchMounts = append(fetchMounts, corev1.VolumeMount{
+			Name:      "config",
+			MountPath: verificationConfigPath,
+			ReadOnly:  true,
+		})
+		volumes = append(volumes, corev1.Volume{
+			Name: "config",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: verificationPodName(export),
+				},
+			},
+		})
+	}
+
+	deadline := int64(verificationTimeout(export).Seconds())
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      verificationPodName(export),
+			Namespace: export.Namespace,
+			Labels: map[string]string{
+				"app":   "pachyderm-export-verify",
+				"suite": "pachyderm",
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:         corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			InitContainers: []corev1.Container{
+				{
+					Name:                     "fetch",
+					Image:                    image.Name(),
+					ImagePullPolicy:          image.ImagePullPolicy(),
+					Command:                  []string{"bash", "-c", verificationFetchScript},
+					Env:                      fetchEnv,
+					VolumeMounts:             fetchMounts,
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:            "postgres",
+					Image:           image.Name(),
+					ImagePullPolicy: image.ImagePullPolicy(),
+					Command:         []string{"bash", "-c", verificationScript},
+					Env: []corev1.EnvVar{
+						{
+							Name:  "POSTGRESQL_USER",
+							Value: pd.Spec.Pachd.Postgres.User,
+						},
+						{
+							Name:  "POSTGRESQL_PASSWORD",
+							Value: password,
+						},
+						{
+							Name:  "POSTGRESQL_DATABASE",
+							Value: pd.Spec.Pachd.Postgres.Database,
+						},
+						{
+							Name:  "PGPASSWORD",
+							Value: password,
+						},
+						{
+							Name:  "VERIFICATION_QUERY",
+							Value: verificationQuery,
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "data",
+							MountPath: "/var/lib/pgsql/data",
+						},
+						{
+							Name:      "restore",
+							MountPath: verificationRestorePath,
+							ReadOnly:  true,
+						},
+					},
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+			Volumes: volumes,
+		},
+	}, nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// startVerification creates the verification pod and the secret
+// holding the request it downloads the backup with
+func (r *PachydermExportReconciler) startVerification(ctx context.Context, export *aimlv1beta1.PachydermExport) error {
+	pd, err := r.pachydermForBackup(ctx, export)
+	if err != nil {
+		return err
+	}
+
+	if exportVolume(export) == nil {
+		destination, err := r.exportDestination(ctx, export)
+		if err != nil {
+			return err
+		}
+
+		presigner, ok := destination.(destinations.Presigner)
+		if !ok {
+			return r.failVerification(ctx, export, goerrors.New("destination does not support downloads from a pod"))
+		}
+
+		download, err := presigner.PresignDownload(ctx, backupKey(export.Status.Location), verificationTimeout(export))
+		if err != nil {
+			return err
+		}
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      verificationPodName(export),
+				Namespace: export.Namespace,
+				Labels: map[string]string{
+					"app":   "pachyderm-export-verify",
+					"suite": "pachyderm",
+				},
+			},
+			StringData: map[string]string{
+				"download.conf": curlConfig(download),
+			},
+		}
+		if err := controllerutil.SetControllerReference(export, secret, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, secret); err != nil {
+			if !errors.IsAlreadyExists(err) {
+				return err
+			}
+
+			// replace the request left by an earlier attempt
+			existing := &corev1.Secret{}
+			if err := r.Get(ctx, client.ObjectKeyFromObject(secret), existing); err != nil {
+				return err
+			}
+			existing.StringData = secret.StringData
+			if err := r.Update(ctx, existing); err != nil {
+				return err
+			}
+		}
+	}
+
+	pod, err := newVerificationPod(export, pd)
+	if err != nil {
+		return err
+	}
+	if err := controllerutil.SetControllerReference(export, pod, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, pod); err != nil {
+		return err
+	}
+	if export.Status.VerificationStartedAt == "" {
+		export.Status.VerificationStartedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	return nil
+}
+
+// verifyBackup restores the database dump into a temporary postgresql
+// pod, which runs a sanity query against it and reports the result
+// through its termination message
+func (r *PachydermExportReconciler) verifyBackup(ctx context.Context, export *aimlv1beta1.PachydermExport) error {
+	pod := &corev1.Pod{}
+	podKey := types.NamespacedName{
+		Name:      verificationPodName(export),
+		Namespace: export.Namespace,
+	}
+	if err := r.Get(ctx, podKey, pod); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		return r.startVerification(ctx, export)
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		tables, err := verificationResult(pod)
+		if err != nil {
+			return r.failVerification(ctx, export, err)
+		}
+		if tables == 0 {
+			return r.failVerification(ctx, export, ErrEmptyDatabase)
+		}
+	case corev1.PodFailed:
+		return r.failVerification(ctx, export, verificationFailure(pod))
+	default:
+		if verificationTimeoutExpired(export, pod) {
+			return r.failVerification(ctx, export, ErrVerificationTimeout)
+		}
+		return nil
+	}
+
+	export.Status.Phase = aimlv1beta1.ExportCompletedStatus
+	export.Status.Verified = true
+
+	return r.deleteVerificationPod(ctx, export)
+}
+
+// verificationResult returns the number of tables
+// reported by the postgres container of the pod
+func verificationResult(pod *corev1.Pod) (int, error) {
+	terminated := containerTermination(pod, "postgres")
+	if terminated == nil {
+		return 0, goerrors.New("the verification pod did not report the restored tables")
+	}
+
+	result := struct {
+		Tables int `json:"tables"`
+	}{}
+	if err := json.Unmarshal([]byte(terminated.Message), &result); err != nil {
+		return 0, fmt.Errorf("unable to read the restored tables: %w", err)
+	}
+
+	return result.Tables, nil
+}
+
+// verificationFailure describes why the verification pod failed,
+// from the termination message of the container that failed
+func verificationFailure(pod *corev1.Pod) error {
+	if pod.Status.Reason == "DeadlineExceeded" {
+		return ErrVerificationTimeout
+	}
+
+	for _, name := range []string{"fetch", "postgres"} {
+		terminated := containerTermination(pod, name)
+		if terminated != nil && terminated.ExitCode != 0 {
+			return fmt.Errorf("container %s of pod %s failed: %s", name, pod.Name, strings.TrimSpace(terminated.Message))
+		}
+	}
+
+	return fmt.Errorf("verification pod %s failed: %s", pod.Name, pod.Status.Message)
+}
+
+// failVerification marks the export failed, deletes the verification
+// pod and resumes the pachyderm cluster if the export paused it
+func (r *PachydermExportReconciler) failVerification(ctx context.Context, export *aimlv1beta1.PachydermExport, reason error) error {
+	export.Status.Phase = aimlv1beta1.ExportFailedStatus
+	export.Status.Status = fmt.Sprintf("backup verification failed: %s", reason.Error())
+
+	if err := r.deleteVerificationPod(ctx, export); err != nil {
+		return err
+	}
+
+	return r.resumePachyderm(ctx, export)
+}
+
+// deleteVerificationPod removes the verification
+// pod and the secret holding its download request
+func (r *PachydermExportReconciler) deleteVerificationPod(ctx context.Context, export *aimlv1beta1.PachydermExport) error {
+	meta := metav1.ObjectMeta{
+		Name:      verificationPodName(export),
+		Namespace: export.Namespace,
+	}
+	for _, obj := range []client.Object{&corev1.Pod{ObjectMeta: meta}, &corev1.Secret{ObjectMeta: meta}} {
+		if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verificationTimeoutExpired returns true if the verification
+// pod has been running longer than the verification timeout
+func verificationTimeoutExpired(export *aimlv1beta1.PachydermExport, pod *corev1.Pod) bool {
+	startedAt, err := time.Parse(time.RFC3339, export.Status.VerificationStartedAt)
+	if err != nil {
+		startedAt = pod.CreationTimestamp.Time
+	}
+
+	return time.Since(startedAt) > verificationTimeout(export)
+}
+
+func randomString(length int) (string, error) {
+	data := make([]byte, length)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// exportForBackup returns the pachyderm export that created the backup
+func (r *PachydermImportReconciler) exportForBackup(ctx context.Context, req *aimlv1beta1.PachydermImport) (*aimlv1beta1.PachydermExport, error) {
+	exports := &aimlv1beta1.PachydermExportList{}
+	if err := r.List(ctx, exports, client.InNamespace(req.Namespace)); err != nil {
+		return nil, err
+	}
+
+	for i, export := range exports.Items {
+		if export.Status.Location == "" {
+			continue
+		}
+		if export.Status.Location == req.Spec.BackupName {
+			return &exports.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// verifyBackupContent compares the restored backup against
+// the digests recorded when the backup was exported
+func (r *PachydermImportReconciler) verifyBackupContent(ctx context.Context, req *aimlv1beta1.PachydermImport, bk *backupContent) error {
+	if req.Status.Verified {
+		return nil
+	}
+
+	export, err := r.exportForBackup(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// backups created before digests were recorded can not be verified
+	if export == nil || len(export.Status.Artifacts) == 0 {
+		return nil
+	}
+
+	contents := map[string][]byte{
+		backupResourceFile: bk.resource,
+		backupDatabaseFile: bk.database,
+	}
+	for _, expected := range export.Status.Artifacts {
+		content, ok := contents[expected.Name]
+		if !ok {
+			continue
+		}
+
+		actual, err := digest(expected.Name, bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+
+		if actual.SHA256 != expected.SHA256 || actual.Size != expected.Size {
+			req.Status.Status = fmt.Sprintf("checksum of %s does not match export %s", expected.Name, export.Name)
+			if err := r.Status().Update(ctx, req); err != nil {
+				return err
+			}
+			return ErrChecksumMismatch
+		}
+	}
+
+	req.Status.Verified = true
+	return nil
+}
@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+func TestVerifyBackup(t *testing.T) {
+	terminated := func(name string, exitCode int32, message string) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name: name,
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: exitCode,
+					Message:  message,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		status    corev1.PodStatus
+		startedAt time.Time
+		verified  bool
+		failed    bool
+		// expected in the status message of failed exports
+		reason string
+	}{
+		{
+			name:      "pod starting",
+			status:    corev1.PodStatus{Phase: corev1.PodPending},
+			startedAt: time.Now(),
+		},
+		{
+			name: "restore succeeded",
+			status: corev1.PodStatus{
+				Phase:             corev1.PodSucceeded,
+				ContainerStatuses: []corev1.ContainerStatus{terminated("postgres", 0, `{"tables":12}`)},
+			},
+			startedAt: time.Now(),
+			verified:  true,
+		},
+		{
+			name: "empty database",
+			status: corev1.PodStatus{
+				Phase:             corev1.PodSucceeded,
+				ContainerStatuses: []corev1.ContainerStatus{terminated("postgres", 0, `{"tables":0}`)},
+			},
+			startedAt: time.Now(),
+			failed:    true,
+			reason:    ErrEmptyDatabase.Error(),
+		},
+		{
+			name: "download failed",
+			status: corev1.PodStatus{
+				Phase:                 corev1.PodFailed,
+				InitContainerStatuses: []corev1.ContainerStatus{terminated("fetch", 22, "curl: (22) The requested URL returned error: 403\n")},
+			},
+			startedAt: time.Now(),
+			failed:    true,
+			reason:    "container fetch of pod backup-verify failed: curl: (22) The requested URL returned error: 403",
+		},
+		{
+			name: "restore failed",
+			status: corev1.PodStatus{
+				Phase:                 corev1.PodFailed,
+				InitContainerStatuses: []corev1.ContainerStatus{terminated("fetch", 0, "")},
+				ContainerStatuses:     []corev1.ContainerStatus{terminated("postgres", 1, "pg_restore: error: could not read input file")},
+			},
+			startedAt: time.Now(),
+			failed:    true,
+			reason:    "container postgres of pod backup-verify failed: pg_restore: error: could not read input file",
+		},
+		{
+			name: "deadline exceeded",
+			status: corev1.PodStatus{
+				Phase:  corev1.PodFailed,
+				Reason: "DeadlineExceeded",
+			},
+			startedAt: time.Now(),
+			failed:    true,
+			reason:    ErrVerificationTimeout.Error(),
+		},
+		{
+			name:      "timeout expired",
+			status:    corev1.PodStatus{Phase: corev1.PodPending},
+			startedAt: time.Now().Add(-time.Hour),
+			failed:    true,
+			reason:    ErrVerificationTimeout.Error(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := &aimlv1beta1.Pachyderm{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachyderm",
+					Namespace: "default",
+					Annotations: map[string]string{
+						aimlv1beta1.PachydermPauseAnnotation:    "true",
+						aimlv1beta1.PachydermPausedByAnnotation: "backup",
+					},
+				},
+			}
+			export := &aimlv1beta1.PachydermExport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "backup",
+					Namespace: "default",
+				},
+				Spec: aimlv1beta1.PachydermExportSpec{
+					Target:              pd.Name,
+					Verify:              true,
+					VerificationTimeout: &metav1.Duration{Duration: 30 * time.Minute},
+				},
+				Status: aimlv1beta1.PachydermExportStatus{
+					Phase:                 aimlv1beta1.ExportVerifyingStatus,
+					VerificationStartedAt: test.startedAt.UTC().Format(time.RFC3339),
+				},
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      verificationPodName(export),
+					Namespace: export.Namespace,
+				},
+				Status: test.status,
+			}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      verificationPodName(export),
+					Namespace: export.Namespace,
+				},
+			}
+
+			c, scheme := newFakeClient(t, pd, export, pod, secret)
+			r := &PachydermExportReconciler{Client: c, Scheme: scheme}
+
+			ctx := context.Background()
+			if err := r.verifyBackup(ctx, export); err != nil {
+				t.Fatal(err)
+			}
+
+			if failed := export.Status.Phase == aimlv1beta1.ExportFailedStatus; failed != test.failed {
+				t.Fatalf("expected failed %t, got phase %s", test.failed, export.Status.Phase)
+			}
+			if export.Status.Verified != test.verified {
+				t.Fatalf("expected verified %t", test.verified)
+			}
+			if test.failed && !strings.HasSuffix(export.Status.Status, test.reason) {
+				t.Fatalf("expected status %q to end with %q", export.Status.Status, test.reason)
+			}
+
+			finished := test.failed || test.verified
+			for _, obj := range []client.Object{&corev1.Pod{}, &corev1.Secret{}} {
+				err := c.Get(ctx, types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, obj)
+				if deleted := errors.IsNotFound(err); deleted != finished {
+					t.Fatalf("expected %T deleted %t, got %v", obj, finished, err)
+				}
+			}
+
+			resumed := &aimlv1beta1.Pachyderm{}
+			if err := c.Get(ctx, types.NamespacedName{Name: pd.Name, Namespace: pd.Namespace}, resumed); err != nil {
+				t.Fatal(err)
+			}
+			if resumed.IsPaused() == test.failed {
+				t.Fatalf("expected paused %t", !test.failed)
+			}
+		})
+	}
+}
+
+func TestRecordBackupArtifacts(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected []aimlv1beta1.ExportArtifact
+	}{
+		{
+			name:    "digests reported",
+			message: `{"artifacts":[{"name":"cr.json","sha256":"ca3d16","size":3},{"name":"database.sql","sha256":"71766c","size":5}]}`,
+			expected: []aimlv1beta1.ExportArtifact{
+				{Name: "cr.json", SHA256: "ca3d16", Size: 3},
+				{Name: "database.sql", SHA256: "71766c", Size: 5},
+			},
+		},
+		{
+			name: "no digests reported",
+		},
+		{
+			name:    "unreadable digests",
+			message: "backup completed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			export := &aimlv1beta1.PachydermExport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "backup",
+					Namespace: "default",
+				},
+			}
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupJobName(export),
+					Namespace: export.Namespace,
+				},
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "backup-backup-x7k2p",
+					Namespace: export.Namespace,
+					Labels:    map[string]string{"job-name": job.Name},
+				},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodSucceeded,
+					ContainerStatuses: []corev1.ContainerStatus{
+						{
+							Name: "backup",
+							State: corev1.ContainerState{
+								Terminated: &corev1.ContainerStateTerminated{Message: test.message},
+							},
+						},
+					},
+				},
+			}
+
+			c, scheme := newFakeClient(t, export, job, pod)
+			r := &PachydermExportReconciler{Client: c, Scheme: scheme}
+
+			if err := r.recordBackupArtifacts(context.Background(), export, job); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(export.Status.Artifacts, test.expected) {
+				t.Fatalf("expected artifacts %+v, got %+v", test.expected, export.Status.Artifacts)
+			}
+		})
+	}
+}
+
+func TestExportForBackup(t *testing.T) {
+	exports := []client.Object{}
+	for name, location := range map[string]string{
+		"daily":   "s3://backups/daily/backups/pachyderm-backup-20221001.tar.gz",
+		"weekly":  "s3://backups/weekly/backups/pachyderm-backup-20221001.tar.gz",
+		"pending": "",
+	} {
+		exports = append(exports, &aimlv1beta1.PachydermExport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Status: aimlv1beta1.PachydermExportStatus{
+				Location: location,
+			},
+		})
+	}
+
+	c, scheme := newFakeClient(t, exports...)
+	r := &PachydermImportReconciler{Client: c, Scheme: scheme}
+
+	tests := []struct {
+		backup   string
+		expected string
+	}{
+		{
+			backup:   "s3://backups/weekly/backups/pachyderm-backup-20221001.tar.gz",
+			expected: "weekly",
+		},
+		{
+			// exports with the same file name in other
+			// destinations do not hold the backup
+			backup: "s3://backups/monthly/backups/pachyderm-backup-20221001.tar.gz",
+		},
+		{
+			backup: "pachyderm-backup-20221001.tar.gz",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.backup, func(t *testing.T) {
+			req := &aimlv1beta1.PachydermImport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "restore",
+					Namespace: "default",
+				},
+				Spec: aimlv1beta1.PachydermImportSpec{
+					BackupName: test.backup,
+				},
+			}
+
+			export, err := r.exportForBackup(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			name := ""
+			if export != nil {
+				name = export.Name
+			}
+			if name != test.expected {
+				t.Fatalf("expected export %q, got %q", test.expected, name)
+			}
+		})
+	}
+}
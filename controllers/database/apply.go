@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	// Table recording the scripts applied to the database
+	TrackingTable = "pachyderm_operator_schema_migrations"
+
+	// advisory lock held while the scripts are applied so that
+	// concurrent reconciles never run the same script twice
+	advisoryLockID int64 = 0x70616368
+)
+
+// Apply runs the scripts, in order, that are not recorded
+// in the tracking table or whose checksum has changed.
+// It returns the ids of the scripts that were applied
+func Apply(ctx context.Context, db *sql.DB, scripts []*Script) ([]string, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockID)
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id text PRIMARY KEY,
+		checksum text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`, TrackingTable)); err != nil {
+		return nil, err
+	}
+
+	recorded, err := appliedScripts(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []string{}
+	for _, script := range scripts {
+		if checksum, ok := recorded[script.ID]; ok && checksum == script.Checksum {
+			continue
+		}
+
+		if err := applyScript(ctx, conn, script); err != nil {
+			return applied, fmt.Errorf("script %s: %w", script.ID, err)
+		}
+		applied = append(applied, script.ID)
+	}
+
+	return applied, nil
+}
+
+func appliedScripts(ctx context.Context, conn *sql.Conn) (map[string]string, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT id, checksum FROM %s", TrackingTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recorded := map[string]string{}
+	for rows.Next() {
+		var id, checksum string
+		if err := rows.Scan(&id, &checksum); err != nil {
+			return nil, err
+		}
+		recorded[id] = checksum
+	}
+
+	return recorded, rows.Err()
+}
+
+func applyScript(ctx context.Context, conn *sql.Conn, script *Script) error {
+	skip := false
+	if script.SkipIf != "" {
+		if err := conn.QueryRowContext(ctx, script.SkipIf).Scan(&skip); err != nil {
+			return err
+		}
+	}
+
+	if !script.Transactional {
+		if !skip {
+			if _, err := conn.ExecContext(ctx, script.SQL); err != nil {
+				return err
+			}
+		}
+		return recordScript(ctx, conn, script)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !skip {
+		if _, err := tx.ExecContext(ctx, script.SQL); err != nil {
+			return err
+		}
+	}
+	if err := recordScript(ctx, tx, script); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func recordScript(ctx context.Context, db execer, script *Script) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (id, checksum) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET checksum = EXCLUDED.checksum, applied_at = now()`, TrackingTable),
+		script.ID, script.Checksum)
+	return err
+}
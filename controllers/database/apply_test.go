@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestApply(t *testing.T) {
+	errExec := errors.New("permission denied")
+
+	newScript := func(id, content string) *Script {
+		script, err := NewScript(id, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return script
+	}
+	create := newScript("v2.0.0/0001_create", "CREATE TABLE jobs (id text);")
+	database := newScript("v2.0.0/0002_database", "-- +operator no-transaction\n-- +operator skip-if SELECT exists_dex()\nCREATE DATABASE dex;")
+
+	tests := []struct {
+		name string
+		// checksums recorded in the tracking table
+		recorded map[string]string
+		// result of the skip-if query of the database script
+		skip bool
+		// error running the create script
+		execErr  error
+		expected []string
+		failed   bool
+	}{
+		{
+			name:     "apply every script",
+			recorded: map[string]string{},
+			expected: []string{create.ID, database.ID},
+		},
+		{
+			name: "skip recorded scripts",
+			recorded: map[string]string{
+				create.ID:   create.Checksum,
+				database.ID: database.Checksum,
+			},
+			expected: []string{},
+		},
+		{
+			name: "apply again on checksum mismatch",
+			recorded: map[string]string{
+				create.ID:   "previous",
+				database.ID: database.Checksum,
+			},
+			expected: []string{create.ID},
+		},
+		{
+			name: "record script when skip-if holds",
+			recorded: map[string]string{
+				create.ID: create.Checksum,
+			},
+			skip:     true,
+			expected: []string{database.ID},
+		},
+		{
+			name:     "roll back failed script",
+			recorded: map[string]string{},
+			execErr:  errExec,
+			expected: []string{},
+			failed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).
+				WithArgs(advisoryLockID).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("CREATE TABLE IF NOT EXISTS " + TrackingTable).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			rows := sqlmock.NewRows([]string{"id", "checksum"})
+			for id, checksum := range test.recorded {
+				rows.AddRow(id, checksum)
+			}
+			mock.ExpectQuery("SELECT id, checksum FROM " + TrackingTable).WillReturnRows(rows)
+
+			if checksum, ok := test.recorded[create.ID]; !ok || checksum != create.Checksum {
+				mock.ExpectBegin()
+				if test.execErr != nil {
+					mock.ExpectExec("CREATE TABLE jobs").WillReturnError(test.execErr)
+					mock.ExpectRollback()
+				} else {
+					mock.ExpectExec("CREATE TABLE jobs").WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec("INSERT INTO "+TrackingTable).
+						WithArgs(create.ID, create.Checksum).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				}
+			}
+			if checksum, ok := test.recorded[database.ID]; test.execErr == nil && (!ok || checksum != database.Checksum) {
+				mock.ExpectQuery(`SELECT exists_dex\(\)`).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(test.skip))
+				if !test.skip {
+					mock.ExpectExec("CREATE DATABASE dex").WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+				mock.ExpectExec("INSERT INTO "+TrackingTable).
+					WithArgs(database.ID, database.Checksum).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).
+				WithArgs(advisoryLockID).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			applied, err := Apply(context.Background(), db, []*Script{create, database})
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+			if test.execErr != nil && !errors.Is(err, test.execErr) {
+				t.Fatalf("expected error %v, got %v", test.execErr, err)
+			}
+			if !reflect.DeepEqual(applied, test.expected) {
+				t.Fatalf("expected applied scripts %v, got %v", test.expected, applied)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
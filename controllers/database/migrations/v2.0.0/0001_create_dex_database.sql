@@ -0,0 +1,5 @@
+-- Database used by the dex identity provider embedded in pachd.
+-- Databases can not be created in a transaction block
+-- +operator no-transaction
+-- +operator skip-if SELECT exists(SELECT 1 FROM pg_catalog.pg_database WHERE datname = 'dex')
+CREATE DATABASE dex;
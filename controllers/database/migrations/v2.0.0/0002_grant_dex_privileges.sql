@@ -0,0 +1,2 @@
+-- Allow pachd to create the dex schema
+GRANT ALL PRIVILEGES ON DATABASE dex TO {{ identifier .User }};
@@ -0,0 +1,192 @@
+// Package database bootstraps and migrates the
+// postgresql database used by a pachyderm cluster
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/lib/pq"
+	"golang.org/x/mod/semver"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+const directivePrefix = "-- +operator "
+
+// Params are the values available to the
+// templates of the built-in scripts
+type Params struct {
+	// User pachd connects to the database as
+	User string
+	// Database holding the pachyderm schemas
+	Database string
+}
+
+// Script is a single SQL script applied to the database
+type Script struct {
+	// ID uniquely identifies the script in the tracking table
+	ID string
+	// SQL statements of the script
+	SQL string
+	// Checksum of the statements. A script whose checksum
+	// differs from the recorded one is applied again
+	Checksum string
+	// Transactional scripts run in a transaction
+	// together with their entry in the tracking table
+	Transactional bool
+	// SkipIf is a query returning a single boolean.
+	// The script is recorded without running when it returns true
+	SkipIf string
+}
+
+// NewScript parses the directives of the script content.
+// Directives are comments of the form:
+//
+//	-- +operator no-transaction
+//	-- +operator skip-if <query>
+func NewScript(id, content string) (*Script, error) {
+	sum := sha256.Sum256([]byte(content))
+	script := &Script{
+		ID:            id,
+		SQL:           content,
+		Checksum:      hex.EncodeToString(sum[:]),
+		Transactional: true,
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, directivePrefix) {
+			continue
+		}
+
+		directive := strings.TrimSpace(strings.TrimPrefix(line, directivePrefix))
+		name, argument, _ := strings.Cut(directive, " ")
+		switch name {
+		case "no-transaction":
+			script.Transactional = false
+		case "skip-if":
+			if argument = strings.TrimSpace(argument); argument == "" {
+				return nil, fmt.Errorf("script %s: skip-if requires a query", id)
+			}
+			script.SkipIf = argument
+		default:
+			return nil, fmt.Errorf("script %s: unknown directive %q", id, name)
+		}
+	}
+
+	return script, nil
+}
+
+type builtinScript struct {
+	version  string
+	sequence int
+	path     string
+}
+
+// Builtin returns the scripts shipped with the operator
+// for pachyderm versions up to and including version,
+// ordered by version and sequence number
+func Builtin(version string, params Params) ([]*Script, error) {
+	return loadScripts(migrations, version, params)
+}
+
+// loadScripts reads the scripts under the migrations
+// directory of the file system, see Builtin
+func loadScripts(fsys fs.FS, version string, params Params) ([]*Script, error) {
+	if !semver.IsValid(version) {
+		version = fmt.Sprintf("v%s", version)
+	}
+	if !semver.IsValid(version) {
+		return nil, fmt.Errorf("invalid pachyderm version %q", version)
+	}
+
+	builtins := []builtinScript{}
+	if err := fs.WalkDir(fsys, "migrations", func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(file) != ".sql" {
+			return err
+		}
+
+		scriptVersion := path.Base(path.Dir(file))
+		if !semver.IsValid(scriptVersion) {
+			return fmt.Errorf("script %s: invalid version directory", file)
+		}
+		if semver.Compare(scriptVersion, version) == 1 {
+			return nil
+		}
+
+		prefix, _, _ := strings.Cut(path.Base(file), "_")
+		sequence, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("script %s: missing sequence number", file)
+		}
+
+		builtins = append(builtins, builtinScript{
+			version:  scriptVersion,
+			sequence: sequence,
+			path:     file,
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(builtins, func(i, j int) bool {
+		if c := semver.Compare(builtins[i].version, builtins[j].version); c != 0 {
+			return c == -1
+		}
+		return builtins[i].sequence < builtins[j].sequence
+	})
+
+	scripts := []*Script{}
+	for _, builtin := range builtins {
+		content, err := renderScript(fsys, builtin.path, params)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(strings.TrimPrefix(builtin.path, "migrations/"), ".sql")
+		script, err := NewScript(id, content)
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, script)
+	}
+
+	return scripts, nil
+}
+
+func renderScript(fsys fs.FS, file string, params Params) (string, error) {
+	content, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New(path.Base(file)).
+		Funcs(template.FuncMap{
+			"identifier": pq.QuoteIdentifier,
+			"literal":    pq.QuoteLiteral,
+		}).
+		Option("missingkey=error").
+		Parse(string(content))
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,247 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewScript(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		transactional bool
+		skipIf        string
+		failed        bool
+	}{
+		{
+			name:          "no directives",
+			content:       "CREATE TABLE jobs (id text);",
+			transactional: true,
+		},
+		{
+			name:    "no transaction",
+			content: "-- +operator no-transaction\nCREATE DATABASE dex;",
+		},
+		{
+			name:          "skip if",
+			content:       "  -- +operator skip-if SELECT exists(SELECT 1 FROM pg_roles WHERE rolname = 'dex')\nCREATE ROLE dex;",
+			transactional: true,
+			skipIf:        "SELECT exists(SELECT 1 FROM pg_roles WHERE rolname = 'dex')",
+		},
+		{
+			name:    "both directives",
+			content: "-- +operator no-transaction\n-- +operator skip-if SELECT true\nCREATE DATABASE dex;",
+			skipIf:  "SELECT true",
+		},
+		{
+			name:          "comments without the directive prefix",
+			content:       "-- operator no-transaction\n--+operator no-transaction\nSELECT 1;",
+			transactional: true,
+		},
+		{
+			name:    "skip if without a query",
+			content: "-- +operator skip-if   \nCREATE ROLE dex;",
+			failed:  true,
+		},
+		{
+			name:    "unknown directive",
+			content: "-- +operator run-once\nCREATE ROLE dex;",
+			failed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := NewScript("v2.0.0/0001_test", test.content)
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if script.Transactional != test.transactional {
+				t.Errorf("expected transactional %t", test.transactional)
+			}
+			if script.SkipIf != test.skipIf {
+				t.Errorf("expected skip-if %q, got %q", test.skipIf, script.SkipIf)
+			}
+			if script.SQL != test.content {
+				t.Errorf("expected the script to hold its content")
+			}
+			sum := sha256.Sum256([]byte(test.content))
+			if script.Checksum != hex.EncodeToString(sum[:]) {
+				t.Errorf("expected checksum of the content, got %s", script.Checksum)
+			}
+		})
+	}
+}
+
+func TestLoadScripts(t *testing.T) {
+	migrations := fstest.MapFS{
+		"migrations/v2.0.0/0002_second.sql": {Data: []byte("SELECT 2;")},
+		"migrations/v2.0.0/0010_tenth.sql":  {Data: []byte("SELECT 10;")},
+		"migrations/v2.0.0/0001_first.sql":  {Data: []byte("SELECT 1;")},
+		"migrations/v2.1.0/0001_minor.sql":  {Data: []byte("SELECT 21;")},
+		"migrations/v2.10.0/0001_later.sql": {Data: []byte("SELECT 210;")},
+		"migrations/v2.1.0/README.md":       {Data: []byte("scripts of 2.1")},
+		"migrations/v2.0.0/notes/draft.txt": {Data: []byte("draft")},
+	}
+
+	tests := []struct {
+		name     string
+		fsys     fstest.MapFS
+		version  string
+		expected []string
+		failed   bool
+	}{
+		{
+			name:     "scripts up to the version",
+			fsys:     migrations,
+			version:  "v2.1.6",
+			expected: []string{"v2.0.0/0001_first", "v2.0.0/0002_second", "v2.0.0/0010_tenth", "v2.1.0/0001_minor"},
+		},
+		{
+			name:     "version without prefix",
+			fsys:     migrations,
+			version:  "2.0.0",
+			expected: []string{"v2.0.0/0001_first", "v2.0.0/0002_second", "v2.0.0/0010_tenth"},
+		},
+		{
+			name:    "versions compared semantically",
+			fsys:    migrations,
+			version: "2.10.1",
+			expected: []string{
+				"v2.0.0/0001_first", "v2.0.0/0002_second", "v2.0.0/0010_tenth",
+				"v2.1.0/0001_minor", "v2.10.0/0001_later",
+			},
+		},
+		{
+			name:     "version older than every script",
+			fsys:     migrations,
+			version:  "1.13.0",
+			expected: []string{},
+		},
+		{
+			name:    "invalid version",
+			fsys:    migrations,
+			version: "latest",
+			failed:  true,
+		},
+		{
+			name: "invalid version directory",
+			fsys: fstest.MapFS{
+				"migrations/next/0001_first.sql": {Data: []byte("SELECT 1;")},
+			},
+			version: "2.0.0",
+			failed:  true,
+		},
+		{
+			name: "missing sequence number",
+			fsys: fstest.MapFS{
+				"migrations/v2.0.0/first.sql": {Data: []byte("SELECT 1;")},
+			},
+			version: "2.0.0",
+			failed:  true,
+		},
+		{
+			name: "invalid directive",
+			fsys: fstest.MapFS{
+				"migrations/v2.0.0/0001_first.sql": {Data: []byte("-- +operator always\nSELECT 1;")},
+			},
+			version: "2.0.0",
+			failed:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scripts, err := loadScripts(test.fsys, test.version, Params{User: "pachyderm", Database: "pachyderm"})
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+			if err != nil {
+				return
+			}
+
+			ids := []string{}
+			for _, script := range scripts {
+				ids = append(ids, script.ID)
+			}
+			if !reflect.DeepEqual(ids, test.expected) {
+				t.Fatalf("expected scripts %v, got %v", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestLoadScriptsTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		params   Params
+		expected string
+		failed   bool
+	}{
+		{
+			name:     "quoted identifier",
+			template: "GRANT ALL PRIVILEGES ON DATABASE dex TO {{ identifier .User }};",
+			params:   Params{User: `pach"yderm`},
+			expected: `GRANT ALL PRIVILEGES ON DATABASE dex TO "pach""yderm";`,
+		},
+		{
+			name:     "quoted literal",
+			template: "COMMENT ON DATABASE dex IS {{ literal .Database }};",
+			params:   Params{Database: "it's pachyderm"},
+			expected: "COMMENT ON DATABASE dex IS 'it''s pachyderm';",
+		},
+		{
+			name:     "unknown parameter",
+			template: "GRANT ALL PRIVILEGES ON DATABASE dex TO {{ identifier .Role }};",
+			failed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"migrations/v2.0.0/0001_template.sql": {Data: []byte(test.template)},
+			}
+
+			scripts, err := loadScripts(fsys, "2.0.0", test.params)
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if scripts[0].SQL != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, scripts[0].SQL)
+			}
+		})
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	scripts, err := Builtin("2.1.6", Params{User: "pachyderm", Database: "pachyderm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scripts) == 0 {
+		t.Fatal("expected built-in scripts")
+	}
+	for _, script := range scripts {
+		if !strings.HasPrefix(script.ID, "v") || strings.HasSuffix(script.ID, ".sql") {
+			t.Errorf("unexpected script id %s", script.ID)
+		}
+		if strings.Contains(script.SQL, "{{") {
+			t.Errorf("script %s was not rendered", script.ID)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// DexDatabase is the database used by the
+// dex identity provider embedded in pachd
+const DexDatabase = "dex"
+
+const (
+	invalidCatalogName     = "3D000"
+	invalidPassword        = "28P01"
+	invalidAuthorization   = "28000"
+	insufficientPrivilege  = "42501"
+	databasePrivilegeQuery = "SELECT has_database_privilege($1, $2)"
+	databaseExistsQuery    = "SELECT exists(SELECT 1 FROM pg_catalog.pg_database WHERE datname = $1)"
+	schemaPrivilegeQuery   = "SELECT has_schema_privilege('public', 'CREATE')"
+	currentDatabaseQuery   = "SELECT current_database()"
+)
+
+// Verify checks the databases, role and grants required by pachd
+// exist, using a connection made with the credentials of the pachd
+// user. It returns a description of every missing object along with
+// the statement an administrator must run to create it
+func Verify(ctx context.Context, db *sql.DB, params Params) ([]string, error) {
+	user := pq.QuoteIdentifier(params.User)
+
+	if err := db.PingContext(ctx); err != nil {
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) {
+			return nil, err
+		}
+
+		switch pqErr.Code {
+		case invalidCatalogName:
+			return []string{
+				fmt.Sprintf("database %s does not exist, run: CREATE DATABASE %s OWNER %s",
+					params.Database, pq.QuoteIdentifier(params.Database), user),
+			}, nil
+		case invalidPassword, invalidAuthorization:
+			return []string{
+				fmt.Sprintf("role %s can not log in, run: CREATE ROLE %s LOGIN PASSWORD '<password>'",
+					params.User, user),
+			}, nil
+		case insufficientPrivilege:
+			return []string{
+				fmt.Sprintf("role %s can not connect to database %s, run: GRANT CONNECT ON DATABASE %s TO %s",
+					params.User, params.Database, pq.QuoteIdentifier(params.Database), user),
+			}, nil
+		}
+		return nil, err
+	}
+
+	missing := []string{}
+
+	var database string
+	if err := db.QueryRowContext(ctx, currentDatabaseQuery).Scan(&database); err != nil {
+		return nil, err
+	}
+
+	var allowed bool
+	if err := db.QueryRowContext(ctx, databasePrivilegeQuery, database, "CREATE").Scan(&allowed); err != nil {
+		return nil, err
+	}
+	if !allowed {
+		missing = append(missing, fmt.Sprintf("role %s can not create schemas in database %s, run: GRANT ALL PRIVILEGES ON DATABASE %s TO %s",
+			params.User, database, pq.QuoteIdentifier(database), user))
+	}
+
+	if err := db.QueryRowContext(ctx, schemaPrivilegeQuery).Scan(&allowed); err != nil {
+		return nil, err
+	}
+	if !allowed {
+		missing = append(missing, fmt.Sprintf("role %s can not create tables in schema public of database %s, run: GRANT CREATE ON SCHEMA public TO %s",
+			params.User, database, user))
+	}
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, databaseExistsQuery, DexDatabase).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return append(missing, fmt.Sprintf("database %s does not exist, run: CREATE DATABASE %s OWNER %s",
+			DexDatabase, pq.QuoteIdentifier(DexDatabase), user)), nil
+	}
+
+	for _, privilege := range []string{"CONNECT", "CREATE"} {
+		if err := db.QueryRowContext(ctx, databasePrivilegeQuery, DexDatabase, privilege).Scan(&allowed); err != nil {
+			return nil, err
+		}
+		if !allowed {
+			missing = append(missing, fmt.Sprintf("role %s lacks the %s privilege on database %s, run: GRANT ALL PRIVILEGES ON DATABASE %s TO %s",
+				params.User, privilege, DexDatabase, pq.QuoteIdentifier(DexDatabase), user))
+			break
+		}
+	}
+
+	return missing, nil
+}
@@ -0,0 +1,323 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// version of the blob service REST API used by requests
+	azureAPIVersion string = "2020-04-08"
+	// size of the blocks a backup is uploaded in
+	azureBlockSize int = 8 << 20
+)
+
+// AzureOptions configures an Azure Blob Storage destination
+type AzureOptions struct {
+	Container   string
+	AccountName string
+	// Base64 encoded storage account key
+	AccountKey string
+	// Endpoint of the blob service. Defaults to
+	// https://<account name>.blob.core.windows.net
+	Endpoint string
+	Prefix   string
+}
+
+type azureDestination struct {
+	container string
+	account   string
+	key       []byte
+	prefix    string
+	endpoint  string
+	client    *http.Client
+}
+
+type azureBlockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+// NewAzure returns a destination backed by an Azure blob container
+func NewAzure(opts AzureOptions) (Destination, error) {
+	key, err := base64.StdEncoding.DecodeString(opts.AccountKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid azure account key: %w", err)
+	}
+
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", opts.AccountName)
+	}
+
+	return &azureDestination{
+		container: opts.Container,
+		account:   opts.AccountName,
+		key:       key,
+		prefix:    opts.Prefix,
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		client:    http.DefaultClient,
+	}, nil
+}
+
+// Upload writes the content as a block blob. The content is
+// streamed in blocks since its size is not known in advance
+func (d *azureDestination) Upload(ctx context.Context, key string, content io.Reader) error {
+	blocks := &azureBlockList{}
+	buffer := make([]byte, azureBlockSize)
+	for {
+		n, err := io.ReadFull(content, buffer)
+		if n > 0 {
+			id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", len(blocks.Latest))))
+			query := url.Values{}
+			query.Set("comp", "block")
+			query.Set("blockid", id)
+			if err := d.do(ctx, http.MethodPut, key, query, buffer[:n], http.StatusCreated); err != nil {
+				return err
+			}
+			blocks.Latest = append(blocks.Latest, id)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	body, err := xml.Marshal(blocks)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("comp", "blocklist")
+	return d.do(ctx, http.MethodPut, key, query, append([]byte(xml.Header), body...), http.StatusCreated)
+}
+
+func (d *azureDestination) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	request, err := d.newRequest(ctx, http.MethodGet, key, url.Values{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return response.Body, nil
+	case http.StatusNotFound:
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	default:
+		defer response.Body.Close()
+		return nil, azureError(response)
+	}
+}
+
+func (d *azureDestination) Delete(ctx context.Context, key string) error {
+	return d.do(ctx, http.MethodDelete, key, url.Values{}, nil, http.StatusAccepted)
+}
+
+func (d *azureDestination) Location(key string) string {
+	return d.blobURL(key, url.Values{})
+}
+
+func (d *azureDestination) blobURL(key string, query url.Values) string {
+	uri := fmt.Sprintf("%s/%s/%s", d.endpoint, d.container, objectName(d.prefix, key))
+	if len(query) > 0 {
+		uri = fmt.Sprintf("%s?%s", uri, query.Encode())
+	}
+	return uri
+}
+
+func (d *azureDestination) do(ctx context.Context, method, key string, query url.Values, body []byte, expected int) error {
+	request, err := d.newRequest(ctx, method, key, query, body)
+	if err != nil {
+		return err
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != expected {
+		return azureError(response)
+	}
+
+	return nil
+}
+
+func (d *azureDestination) newRequest(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, d.blobURL(key, query), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.ContentLength = int64(len(body))
+
+	request.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	request.Header.Set("x-ms-version", azureAPIVersion)
+
+	signature, err := d.sign(request)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", d.account, signature))
+
+	return request, nil
+}
+
+// sign computes the shared key signature of the request
+// https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (d *azureDestination) sign(request *http.Request) (string, error) {
+	contentLength := ""
+	if request.ContentLength > 0 {
+		contentLength = strconv.FormatInt(request.ContentLength, 10)
+	}
+
+	headers := []string{}
+	for name := range request.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-ms-") {
+			headers = append(headers, name)
+		}
+	}
+	sort.Strings(headers)
+
+	canonicalHeaders := &strings.Builder{}
+	for _, name := range headers {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(request.Header.Get(name)))
+	}
+
+	canonicalResource := &strings.Builder{}
+	fmt.Fprintf(canonicalResource, "/%s%s", d.account, request.URL.EscapedPath())
+	query := request.URL.Query()
+	params := []string{}
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		fmt.Fprintf(canonicalResource, "\n%s:%s", strings.ToLower(name), strings.Join(values, ","))
+	}
+
+	stringToSign := strings.Join([]string{
+		request.Method,
+		request.Header.Get("Content-Encoding"),
+		request.Header.Get("Content-Language"),
+		contentLength,
+		request.Header.Get("Content-MD5"),
+		request.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		request.Header.Get("If-Modified-Since"),
+		request.Header.Get("If-Match"),
+		request.Header.Get("If-None-Match"),
+		request.Header.Get("If-Unmodified-Since"),
+		request.Header.Get("Range"),
+		canonicalHeaders.String() + canonicalResource.String(),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, d.key)
+	if _, err := mac.Write([]byte(stringToSign)); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// PresignUpload authorizes the upload with a service
+// shared access signature scoped to the blob. A single
+// Put Blob request uploads at most 5000 MiB
+func (d *azureDestination) PresignUpload(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error) {
+	query, err := d.sharedAccessSignature(key, "cw", expires)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PresignedRequest{
+		Method: http.MethodPut,
+		URL:    d.blobURL(key, query),
+		Headers: map[string]string{
+			"x-ms-blob-type": "BlockBlob",
+		},
+	}, nil
+}
+
+// PresignDownload authorizes the download with a
+// read only shared access signature scoped to the blob
+func (d *azureDestination) PresignDownload(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error) {
+	query, err := d.sharedAccessSignature(key, "r", expires)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PresignedRequest{
+		Method: http.MethodGet,
+		URL:    d.blobURL(key, query),
+	}, nil
+}
+
+// sharedAccessSignature returns the query parameters of a service
+// shared access signature granting the permissions on the blob
+// https://docs.microsoft.com/en-us/rest/api/storageservices/create-service-sas
+func (d *azureDestination) sharedAccessSignature(key, permissions string, expires time.Duration) (url.Values, error) {
+	name := objectName(d.prefix, key)
+	expiry := time.Now().UTC().Add(expires).Format(time.RFC3339)
+
+	stringToSign := strings.Join([]string{
+		permissions,
+		"", // signed start
+		expiry,
+		fmt.Sprintf("/blob/%s/%s/%s", d.account, d.container, name),
+		"", // signed identifier
+		"", // signed IP
+		"", // signed protocol
+		azureAPIVersion,
+		"b", // signed resource
+		"",  // signed snapshot time
+		"",  // cache control
+		"",  // content disposition
+		"",  // content encoding
+		"",  // content language
+		"",  // content type
+	}, "\n")
+
+	mac := hmac.New(sha256.New, d.key)
+	if _, err := mac.Write([]byte(stringToSign)); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("sv", azureAPIVersion)
+	query.Set("sr", "b")
+	query.Set("sp", permissions)
+	query.Set("se", expiry)
+	query.Set("sig", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	return query, nil
+}
+
+func azureError(response *http.Response) error {
+	body, _ := ioutil.ReadAll(response.Body)
+	return fmt.Errorf("azure request failed with status %s: %s", response.Status, strings.TrimSpace(string(body)))
+}
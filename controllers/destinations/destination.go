@@ -0,0 +1,37 @@
+// Package destinations implements the storage
+// backends pachyderm backups can be written to
+package destinations
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+)
+
+// ErrObjectNotFound is returned when the
+// requested key does not exist in the destination
+var ErrObjectNotFound = errors.New("object not found")
+
+// Destination stores and retrieves backups
+type Destination interface {
+	// Upload stores the content under the key
+	Upload(ctx context.Context, key string, content io.Reader) error
+	// Download returns a reader over the content stored under the key
+	Download(ctx context.Context, key string) (io.ReadCloser, error)
+	// Location returns the URL of the key in the destination
+	Location(key string) string
+}
+
+// Deleter is implemented by destinations that can remove objects
+type Deleter interface {
+	// Delete removes the object stored under the key
+	Delete(ctx context.Context, key string) error
+}
+
+func objectName(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return path.Join(prefix, key)
+}
@@ -0,0 +1,405 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// default endpoint of the GCS JSON API
+	gcsEndpoint string = "https://storage.googleapis.com"
+	// endpoint of the IAM credentials API signing blobs
+	// with the key of a service account managed by Google
+	iamCredentialsEndpoint string = "https://iamcredentials.googleapis.com"
+	// oauth scope required to read and write objects
+	gcsScope string = "https://www.googleapis.com/auth/devstorage.read_write"
+	// oauth scope required to sign blobs with the IAM credentials API
+	iamScope string = "https://www.googleapis.com/auth/iam"
+	// algorithm of V4 signatures made with an RSA key
+	gcsSigningAlgorithm string = "GOOG4-RSA-SHA256"
+	// longest expiry allowed for a V4 signed URL
+	gcsMaxSignedURLExpiry = 7 * 24 * time.Hour
+)
+
+// GCSOptions configures a Google Cloud Storage destination
+type GCSOptions struct {
+	Bucket string
+	// Service account key in JSON format. When empty, the
+	// application default credentials are used, for example
+	// the service account of GKE workload identity
+	CredentialsJSON []byte
+	// Endpoint of the GCS JSON API.
+	// Defaults to https://storage.googleapis.com
+	Endpoint string
+	Prefix   string
+}
+
+type gcsDestination struct {
+	bucket   string
+	prefix   string
+	endpoint string
+	client   *http.Client
+	// signs the upload URLs handed to backup jobs
+	signer *gcsSigner
+}
+
+// gcsSigner signs V4 URLs with the private key of
+// a service account key, or through the IAM credentials
+// API for service accounts without a downloadable key
+type gcsSigner struct {
+	email       string
+	key         *rsa.PrivateKey
+	iamEndpoint string
+	client      *http.Client
+}
+
+// NewGCS returns a destination backed by a GCS bucket
+func NewGCS(ctx context.Context, opts GCSOptions) (Destination, error) {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = gcsEndpoint
+	}
+
+	destination := &gcsDestination{
+		bucket:   opts.Bucket,
+		prefix:   opts.Prefix,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   http.DefaultClient,
+	}
+
+	var creds *google.Credentials
+	var err error
+	if len(opts.CredentialsJSON) > 0 {
+		creds, err = google.CredentialsFromJSON(ctx, opts.CredentialsJSON, gcsScope, iamScope)
+		if err != nil {
+			return nil, err
+		}
+	} else if creds, err = google.FindDefaultCredentials(ctx, gcsScope, iamScope); err != nil {
+		// anonymous access to public buckets and emulators
+		return destination, nil
+	}
+
+	destination.client = oauth2.NewClient(ctx, creds.TokenSource)
+	destination.signer = &gcsSigner{
+		iamEndpoint: iamCredentialsEndpoint,
+		client:      destination.client,
+	}
+	if len(creds.JSON) > 0 {
+		if err := destination.signer.loadKey(creds.JSON); err != nil {
+			return nil, err
+		}
+	}
+
+	return destination, nil
+}
+
+func (d *gcsDestination) uploadURL(key string) string {
+	query := url.Values{}
+	query.Set("uploadType", "media")
+	query.Set("name", objectName(d.prefix, key))
+	return fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s",
+		d.endpoint,
+		url.PathEscape(d.bucket),
+		query.Encode(),
+	)
+}
+
+func (d *gcsDestination) Upload(ctx context.Context, key string, content io.Reader) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, d.uploadURL(key), content)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/octet-stream")
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return gcsError(response)
+	}
+
+	return nil
+}
+
+func (d *gcsDestination) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	uri := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media",
+		d.endpoint,
+		url.PathEscape(d.bucket),
+		url.PathEscape(objectName(d.prefix, key)),
+	)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return response.Body, nil
+	case http.StatusNotFound:
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	default:
+		defer response.Body.Close()
+		return nil, gcsError(response)
+	}
+}
+
+func (d *gcsDestination) Delete(ctx context.Context, key string) error {
+	uri := fmt.Sprintf("%s/storage/v1/b/%s/o/%s",
+		d.endpoint,
+		url.PathEscape(d.bucket),
+		url.PathEscape(objectName(d.prefix, key)),
+	)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodDelete, uri, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		return gcsError(response)
+	}
+
+	return nil
+}
+
+func (d *gcsDestination) Location(key string) string {
+	return fmt.Sprintf("gs://%s/%s", d.bucket, objectName(d.prefix, key))
+}
+
+// PresignUpload authorizes the upload with a V4 signed URL
+// of the XML API, valid for the requested expiry
+func (d *gcsDestination) PresignUpload(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error) {
+	return d.presign(ctx, http.MethodPut, key, expires)
+}
+
+// PresignDownload authorizes the download with a V4 signed URL
+func (d *gcsDestination) PresignDownload(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error) {
+	return d.presign(ctx, http.MethodGet, key, expires)
+}
+
+// presign signs the request to the object with the method
+// https://cloud.google.com/storage/docs/access-control/signing-urls-manually
+func (d *gcsDestination) presign(ctx context.Context, method, key string, expires time.Duration) (*PresignedRequest, error) {
+	if d.signer == nil {
+		return nil, errors.New("gcs credentials are required to sign URLs")
+	}
+	if expires > gcsMaxSignedURLExpiry {
+		return nil, fmt.Errorf("gcs signed URLs expire after at most %s", gcsMaxSignedURLExpiry)
+	}
+
+	endpoint, err := url.Parse(d.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	email, err := d.signer.serviceAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	scope := fmt.Sprintf("%s/auto/storage/goog4_request", now.Format("20060102"))
+	query := map[string]string{
+		"X-Goog-Algorithm":     gcsSigningAlgorithm,
+		"X-Goog-Credential":    fmt.Sprintf("%s/%s", email, scope),
+		"X-Goog-Date":          now.Format("20060102T150405Z"),
+		"X-Goog-Expires":       strconv.Itoa(int(expires.Seconds())),
+		"X-Goog-SignedHeaders": "host",
+	}
+	resource := fmt.Sprintf("/%s/%s", gcsEscape(d.bucket, false), gcsEscape(objectName(d.prefix, key), true))
+	canonicalQuery := gcsCanonicalQuery(query)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		resource,
+		canonicalQuery,
+		fmt.Sprintf("host:%s\n", endpoint.Host),
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		gcsSigningAlgorithm,
+		query["X-Goog-Date"],
+		scope,
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+
+	signature, err := d.signer.sign(ctx, []byte(stringToSign))
+	if err != nil {
+		return nil, err
+	}
+
+	return &PresignedRequest{
+		Method: method,
+		URL: fmt.Sprintf("%s://%s%s?%s&X-Goog-Signature=%s",
+			endpoint.Scheme,
+			endpoint.Host,
+			resource,
+			canonicalQuery,
+			hex.EncodeToString(signature),
+		),
+	}, nil
+}
+
+// loadKey reads the email and private key of a service account key
+func (s *gcsSigner) loadKey(credentialsJSON []byte) error {
+	config, err := google.JWTConfigFromJSON(credentialsJSON)
+	if err != nil {
+		// user credentials do not sign URLs
+		return nil
+	}
+
+	block, _ := pem.Decode(config.PrivateKey)
+	if block == nil {
+		return errors.New("no private key found in gcs credentials")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return err
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("gcs credentials private key is not an RSA key")
+	}
+
+	s.email = config.Email
+	s.key = key
+	return nil
+}
+
+// serviceAccount returns the email of the service account signing
+// the URLs, read from the metadata server under workload identity
+func (s *gcsSigner) serviceAccount(ctx context.Context) (string, error) {
+	if s.email != "" {
+		return s.email, nil
+	}
+	if !metadata.OnGCE() {
+		return "", errors.New("gcs credentials without a service account can not sign URLs")
+	}
+
+	email, err := metadata.Email("default")
+	if err != nil {
+		return "", err
+	}
+	s.email = email
+	return email, nil
+}
+
+func (s *gcsSigner) sign(ctx context.Context, payload []byte) ([]byte, error) {
+	if s.key != nil {
+		digest := sha256.Sum256(payload)
+		return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"payload": base64.StdEncoding.EncodeToString(payload),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	uri := fmt.Sprintf("%s/v1/projects/-/serviceAccounts/%s:signBlob", s.iamEndpoint, url.PathEscape(s.email))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, gcsError(response)
+	}
+
+	signed := struct {
+		SignedBlob string `json:"signedBlob"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&signed); err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(signed.SignedBlob)
+}
+
+// gcsCanonicalQuery returns the query sorted by name
+// with the names and values percent encoded
+func gcsCanonicalQuery(query map[string]string) string {
+	names := []string{}
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := []string{}
+	for _, name := range names {
+		params = append(params, fmt.Sprintf("%s=%s", gcsEscape(name, false), gcsEscape(query[name], false)))
+	}
+	return strings.Join(params, "&")
+}
+
+// gcsEscape percent encodes every byte except the
+// unreserved characters of RFC 3986, and optionally slashes
+func gcsEscape(value string, keepSlash bool) string {
+	escaped := &strings.Builder{}
+	for _, c := range []byte(value) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', keepSlash && c == '/':
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+func gcsError(response *http.Response) error {
+	body, _ := ioutil.ReadAll(response.Body)
+	return fmt.Errorf("gcs request failed with status %s: %s", response.Status, strings.TrimSpace(string(body)))
+}
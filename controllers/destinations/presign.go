@@ -0,0 +1,46 @@
+package destinations
+
+import (
+	"context"
+	"time"
+)
+
+// PresignedRequest describes an HTTP request that transfers an
+// object without access to the destination credentials
+type PresignedRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+}
+
+// Presigner is implemented by destinations that can authorize
+// a single upload or download for a limited time
+type Presigner interface {
+	// PresignUpload returns a request that uploads
+	// the object under the key until it expires
+	PresignUpload(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error)
+	// PresignDownload returns a request that downloads
+	// the object under the key until it expires
+	PresignDownload(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error)
+}
+
+// MultipartUpload describes an upload started with
+// the destination, sent in parts with the part requests
+type MultipartUpload struct {
+	// ID of the upload in the destination
+	ID string
+	// Requests uploading each part, in order
+	Parts []*PresignedRequest
+}
+
+// MultipartPresigner is implemented by destinations which limit
+// the size of a single upload, larger objects are uploaded in parts
+type MultipartPresigner interface {
+	// PresignMultipartUpload starts an upload of the object under
+	// the key and authorizes the upload of up to parts parts
+	PresignMultipartUpload(ctx context.Context, key string, parts int, expires time.Duration) (*MultipartUpload, error)
+	// CompleteMultipartUpload assembles the uploaded parts into the object
+	CompleteMultipartUpload(ctx context.Context, key, id string) error
+	// AbortMultipartUpload discards the upload and its uploaded parts
+	AbortMultipartUpload(ctx context.Context, key, id string) error
+}
@@ -0,0 +1,541 @@
+package destinations
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// uploadServer records the uploads it receives, serves
+// the uploaded object back and rejects the requests
+// the check function returns an error for
+type uploadServer struct {
+	*httptest.Server
+	path string
+	body string
+}
+
+func newUploadServer(t *testing.T, check func(r *http.Request) error) *uploadServer {
+	t.Helper()
+
+	server := &uploadServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := check(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodGet {
+			if r.URL.Path != server.path {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(server.body))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		server.path = r.URL.Path
+		server.body = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// upload sends the content with the presigned request
+func upload(request *PresignedRequest, content string) error {
+	r, err := http.NewRequest(request.Method, request.URL, strings.NewReader(content))
+	if err != nil {
+		return err
+	}
+	for name, value := range request.Headers {
+		r.Header.Set(name, value)
+	}
+
+	response, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("upload failed with status %s: %s", response.Status, body)
+	}
+	return nil
+}
+
+// download returns the content fetched with the presigned request
+func download(request *PresignedRequest) (string, error) {
+	r, err := http.NewRequest(request.Method, request.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	for name, value := range request.Headers {
+		r.Header.Set(name, value)
+	}
+
+	response, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode >= 300 {
+		return "", fmt.Errorf("download failed with status %s: %s", response.Status, body)
+	}
+	return string(body), nil
+}
+
+func requireQuery(r *http.Request, names ...string) error {
+	for _, name := range names {
+		if r.URL.Query().Get(name) == "" {
+			return fmt.Errorf("missing query parameter %s", name)
+		}
+	}
+	return nil
+}
+
+// testServiceAccount returns a service account key in JSON
+// format and the public key its signatures are checked with
+func testServiceAccount(t *testing.T) ([]byte, *rsa.PublicKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "backup@project.iam.gserviceaccount.com",
+		"private_key_id": "test",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      "http://127.0.0.1:1/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return credentials, &key.PublicKey
+}
+
+// verifyGCSSignature checks the V4 signature
+// of the request against the public key
+func verifyGCSSignature(r *http.Request, key *rsa.PublicKey) error {
+	query := r.URL.Query()
+	signature, err := hex.DecodeString(query.Get("X-Goog-Signature"))
+	if err != nil {
+		return err
+	}
+	query.Del("X-Goog-Signature")
+
+	params := map[string]string{}
+	for name := range query {
+		params[name] = query.Get(name)
+	}
+	credential := strings.SplitN(params["X-Goog-Credential"], "/", 2)
+	if len(credential) != 2 {
+		return fmt.Errorf("invalid credential %s", params["X-Goog-Credential"])
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		gcsCanonicalQuery(params),
+		fmt.Sprintf("host:%s\n", r.Host),
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		gcsSigningAlgorithm,
+		params["X-Goog-Date"],
+		credential[1],
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+
+	hashed := sha256.Sum256([]byte(stringToSign))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature)
+}
+
+func TestPresign(t *testing.T) {
+	credentials, publicKey := testServiceAccount(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		check        func(r *http.Request) error
+		destination  func(t *testing.T, endpoint string) Destination
+		expectedPath string
+	}{
+		{
+			name: "s3",
+			check: func(r *http.Request) error {
+				return requireQuery(r, "X-Amz-Algorithm", "X-Amz-Credential", "X-Amz-Signature")
+			},
+			destination: func(t *testing.T, endpoint string) Destination {
+				destination, err := NewS3(S3Options{
+					Bucket:       "backups",
+					Region:       "us-east-1",
+					AccessID:     "access",
+					AccessSecret: "secret",
+					Endpoint:     endpoint,
+					Prefix:       "pachyderm",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return destination
+			},
+			expectedPath: "/backups/pachyderm/backups/dump.tar.gz",
+		},
+		{
+			name: "azure",
+			check: func(r *http.Request) error {
+				if r.Method == http.MethodPut && r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+					return fmt.Errorf("missing blob type")
+				}
+				if r.Method == http.MethodGet && r.URL.Query().Get("sp") != "r" {
+					return fmt.Errorf("download signed with permissions %s", r.URL.Query().Get("sp"))
+				}
+				return requireQuery(r, "sv", "sr", "sp", "se", "sig")
+			},
+			destination: func(t *testing.T, endpoint string) Destination {
+				destination, err := NewAzure(AzureOptions{
+					Container:   "backups",
+					AccountName: "account",
+					AccountKey:  base64.StdEncoding.EncodeToString([]byte("secret")),
+					Endpoint:    endpoint,
+					Prefix:      "pachyderm",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return destination
+			},
+			expectedPath: "/backups/pachyderm/backups/dump.tar.gz",
+		},
+		{
+			name: "gcs service account key",
+			check: func(r *http.Request) error {
+				if r.Header.Get("Authorization") != "" {
+					return fmt.Errorf("unexpected authorization header")
+				}
+				return verifyGCSSignature(r, publicKey)
+			},
+			destination: func(t *testing.T, endpoint string) Destination {
+				destination, err := NewGCS(ctx, GCSOptions{
+					Bucket:          "backups",
+					CredentialsJSON: credentials,
+					Endpoint:        endpoint,
+					Prefix:          "pachyderm",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return destination
+			},
+			expectedPath: "/backups/pachyderm/backups/dump.tar.gz",
+		},
+		{
+			name: "gcs sign blob",
+			check: func(r *http.Request) error {
+				return verifyGCSSignature(r, publicKey)
+			},
+			destination: func(t *testing.T, endpoint string) Destination {
+				// the IAM credentials API signs with the key
+				// of the service account on behalf of the caller
+				signer := &gcsSigner{}
+				if err := signer.loadKey(credentials); err != nil {
+					t.Fatal(err)
+				}
+				iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					expectedPath := fmt.Sprintf("/v1/projects/-/serviceAccounts/%s:signBlob", signer.email)
+					if r.Method != http.MethodPost || r.URL.Path != expectedPath {
+						http.NotFound(w, r)
+						return
+					}
+					request := struct {
+						Payload string `json:"payload"`
+					}{}
+					if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+					payload, _ := base64.StdEncoding.DecodeString(request.Payload)
+					signed, err := signer.sign(r.Context(), payload)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					}
+					json.NewEncoder(w).Encode(map[string]string{
+						"signedBlob": base64.StdEncoding.EncodeToString(signed),
+					})
+				}))
+				t.Cleanup(iam.Close)
+
+				return &gcsDestination{
+					bucket:   "backups",
+					prefix:   "pachyderm",
+					endpoint: endpoint,
+					client:   http.DefaultClient,
+					signer: &gcsSigner{
+						email:       signer.email,
+						iamEndpoint: iam.URL,
+						client:      http.DefaultClient,
+					},
+				}
+			},
+			expectedPath: "/backups/pachyderm/backups/dump.tar.gz",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newUploadServer(t, test.check)
+			presigner, ok := test.destination(t, server.URL).(Presigner)
+			if !ok {
+				t.Fatal("destination does not presign uploads")
+			}
+
+			request, err := presigner.PresignUpload(ctx, "backups/dump.tar.gz", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if request.Method != http.MethodPut {
+				t.Fatalf("expected method PUT, got %s", request.Method)
+			}
+			if _, err := url.Parse(request.URL); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := upload(request, "backup"); err != nil {
+				t.Fatal(err)
+			}
+			if server.path != test.expectedPath {
+				t.Fatalf("expected upload to %s, got %s", test.expectedPath, server.path)
+			}
+			if server.body != "backup" {
+				t.Fatalf("expected body backup, got %s", server.body)
+			}
+
+			request, err = presigner.PresignDownload(ctx, "backups/dump.tar.gz", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if request.Method != http.MethodGet {
+				t.Fatalf("expected method GET, got %s", request.Method)
+			}
+
+			content, err := download(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != "backup" {
+				t.Fatalf("expected to download backup, got %s", content)
+			}
+		})
+	}
+}
+
+func TestGCSPresignUploadErrors(t *testing.T) {
+	credentials, _ := testServiceAccount(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		signer  bool
+		expires time.Duration
+	}{
+		{
+			name:    "anonymous client",
+			expires: time.Hour,
+		},
+		{
+			name:    "expiry too long",
+			signer:  true,
+			expires: 8 * 24 * time.Hour,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			destination := &gcsDestination{
+				bucket:   "backups",
+				endpoint: gcsEndpoint,
+				client:   http.DefaultClient,
+			}
+			if test.signer {
+				destination.signer = &gcsSigner{}
+				if err := destination.signer.loadKey(credentials); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := destination.PresignUpload(ctx, "dump.tar.gz", test.expires); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestGCSEscape(t *testing.T) {
+	tests := []struct {
+		value     string
+		keepSlash bool
+		expected  string
+	}{
+		{value: "backups/dump.tar.gz", keepSlash: true, expected: "backups/dump.tar.gz"},
+		{value: "backups/dump.tar.gz", expected: "backups%2Fdump.tar.gz"},
+		{value: "a b+c~", expected: "a%20b%2Bc~"},
+	}
+
+	for _, test := range tests {
+		if escaped := gcsEscape(test.value, test.keepSlash); escaped != test.expected {
+			t.Errorf("gcsEscape(%q, %t) = %q, expected %q", test.value, test.keepSlash, escaped, test.expected)
+		}
+	}
+}
+
+// multipartServer implements the S3 multipart upload API for a single upload
+type multipartServer struct {
+	*httptest.Server
+	parts   map[string]string
+	object  string
+	aborted bool
+}
+
+func newMultipartServer(t *testing.T) *multipartServer {
+	t.Helper()
+
+	const uploadID = "upload-1"
+	server := &multipartServer{parts: map[string]string{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if _, ok := query["uploads"]; ok && r.Method == http.MethodPost {
+			fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadID)
+			return
+		}
+		if query.Get("uploadId") != uploadID || server.aborted {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchUpload</Code><Message>upload not found</Message></Error>")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			if err := requireQuery(r, "X-Amz-Signature", "partNumber"); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			server.parts[query.Get("partNumber")] = string(body)
+			w.Header().Set("ETag", fmt.Sprintf(`"etag-%s"`, query.Get("partNumber")))
+		case http.MethodGet:
+			fmt.Fprint(w, "<ListPartsResult><IsTruncated>false</IsTruncated>")
+			for number := 1; number <= len(server.parts); number++ {
+				fmt.Fprintf(w, `<Part><PartNumber>%d</PartNumber><ETag>"etag-%d"</ETag></Part>`, number, number)
+			}
+			fmt.Fprint(w, "</ListPartsResult>")
+		case http.MethodPost:
+			request := struct {
+				Parts []struct {
+					PartNumber int
+					ETag       string
+				} `xml:"Part"`
+			}{}
+			if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, part := range request.Parts {
+				server.object += server.parts[strconv.Itoa(part.PartNumber)]
+			}
+			fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+		case http.MethodDelete:
+			server.aborted = true
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestS3MultipartUpload(t *testing.T) {
+	server := newMultipartServer(t)
+	destination, err := NewS3(S3Options{
+		Bucket:       "backups",
+		Region:       "us-east-1",
+		AccessID:     "access",
+		AccessSecret: "secret",
+		Endpoint:     server.URL,
+		Prefix:       "pachyderm",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	presigner, ok := destination.(MultipartPresigner)
+	if !ok {
+		t.Fatal("destination does not presign multipart uploads")
+	}
+
+	ctx := context.Background()
+	multipart, err := presigner.PresignMultipartUpload(ctx, "backups/dump.tar.gz", 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(multipart.Parts) != 3 {
+		t.Fatalf("expected 3 part requests, got %d", len(multipart.Parts))
+	}
+
+	// the last part requests are left unused by smaller objects
+	for i, content := range []string{"first ", "second"} {
+		if err := upload(multipart.Parts[i], content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := presigner.CompleteMultipartUpload(ctx, "backups/dump.tar.gz", multipart.ID); err != nil {
+		t.Fatal(err)
+	}
+	if server.object != "first second" {
+		t.Fatalf("expected object assembled from the parts, got %q", server.object)
+	}
+
+	if err := presigner.AbortMultipartUpload(ctx, "backups/dump.tar.gz", multipart.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := presigner.AbortMultipartUpload(ctx, "backups/dump.tar.gz", multipart.ID); err != nil {
+		t.Fatalf("expected aborting a missing upload to succeed, got %v", err)
+	}
+	if err := presigner.CompleteMultipartUpload(ctx, "backups/dump.tar.gz", multipart.ID); err == nil {
+		t.Fatal("expected completing an aborted upload to fail")
+	}
+}
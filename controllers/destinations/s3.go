@@ -0,0 +1,259 @@
+package destinations
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3Options configures an S3-compatible destination
+type S3Options struct {
+	Bucket       string
+	Region       string
+	AccessID     string
+	AccessSecret string
+	// Optional session token for temporary credentials
+	SessionToken string
+	// Endpoint of an S3-compatible object store.
+	// Requests use path style addressing when set
+	Endpoint string
+	Prefix   string
+	// Connect to the endpoint without TLS
+	DisableSSL bool
+	// Skip verification of the endpoint certificate
+	InsecureSkipVerify bool
+	// PEM encoded certificate authorities trusted
+	// in addition to the system certificate pool
+	CABundle []byte
+}
+
+type s3Destination struct {
+	bucket  string
+	prefix  string
+	session *session.Session
+}
+
+// NewS3 returns a destination backed by an S3-compatible bucket
+func NewS3(opts S3Options) (Destination, error) {
+	config := &aws.Config{
+		Region: aws.String(opts.Region),
+		Credentials: credentials.NewStaticCredentials(
+			opts.AccessID,
+			opts.AccessSecret,
+			opts.SessionToken,
+		),
+		DisableSSL: aws.Bool(opts.DisableSSL),
+	}
+	if opts.Endpoint != "" {
+		config.Endpoint = aws.String(opts.Endpoint)
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	if opts.InsecureSkipVerify || len(opts.CABundle) > 0 {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: opts.InsecureSkipVerify,
+		}
+		if len(opts.CABundle) > 0 {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(opts.CABundle) {
+				return nil, errors.New("no certificates found in CA bundle")
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		config.HTTPClient = &http.Client{Transport: transport}
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Destination{
+		bucket:  opts.Bucket,
+		prefix:  opts.Prefix,
+		session: sess,
+	}, nil
+}
+
+func (d *s3Destination) Upload(ctx context.Context, key string, content io.Reader) error {
+	uploader := s3manager.NewUploader(d.session)
+	_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(objectName(d.prefix, key)),
+		Body:   content,
+	})
+	return err
+}
+
+func (d *s3Destination) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s3.New(d.session).GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(objectName(d.prefix, key)),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
+		return nil, err
+	}
+
+	return object.Body, nil
+}
+
+func (d *s3Destination) Delete(ctx context.Context, key string) error {
+	_, err := s3.New(d.session).DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(objectName(d.prefix, key)),
+	})
+	return err
+}
+
+func (d *s3Destination) Location(key string) string {
+	return fmt.Sprintf("s3://%s/%s", d.bucket, objectName(d.prefix, key))
+}
+
+// PresignUpload authorizes the upload with a presigned PutObject
+// request. A single PUT uploads at most 5 GB, larger objects
+// are uploaded with PresignMultipartUpload
+func (d *s3Destination) PresignUpload(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error) {
+	request, _ := s3.New(d.session).PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(objectName(d.prefix, key)),
+	})
+	request.SetContext(ctx)
+
+	url, err := request.Presign(expires)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PresignedRequest{
+		Method: http.MethodPut,
+		URL:    url,
+	}, nil
+}
+
+// PresignMultipartUpload starts a multipart upload and presigns the
+// UploadPart request of each part. Parts other than the last
+// one must hold at least 5 MiB and at most 5 GiB
+func (d *s3Destination) PresignMultipartUpload(ctx context.Context, key string, parts int, expires time.Duration) (*MultipartUpload, error) {
+	client := s3.New(d.session)
+	created, err := client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(objectName(d.prefix, key)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &MultipartUpload{
+		ID: aws.StringValue(created.UploadId),
+	}
+	for part := int64(1); part <= int64(parts); part++ {
+		request, _ := client.UploadPartRequest(&s3.UploadPartInput{
+			Bucket:     aws.String(d.bucket),
+			Key:        aws.String(objectName(d.prefix, key)),
+			UploadId:   created.UploadId,
+			PartNumber: aws.Int64(part),
+		})
+		request.SetContext(ctx)
+
+		url, err := request.Presign(expires)
+		if err != nil {
+			return nil, err
+		}
+		upload.Parts = append(upload.Parts, &PresignedRequest{
+			Method: http.MethodPut,
+			URL:    url,
+		})
+	}
+
+	return upload, nil
+}
+
+// CompleteMultipartUpload assembles the parts listed in the upload
+func (d *s3Destination) CompleteMultipartUpload(ctx context.Context, key, id string) error {
+	client := s3.New(d.session)
+
+	completed := []*s3.CompletedPart{}
+	err := client.ListPartsPagesWithContext(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(d.bucket),
+		Key:      aws.String(objectName(d.prefix, key)),
+		UploadId: aws.String(id),
+	}, func(page *s3.ListPartsOutput, last bool) bool {
+		for _, part := range page.Parts {
+			completed = append(completed, &s3.CompletedPart{
+				ETag:       part.ETag,
+				PartNumber: part.PartNumber,
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if len(completed) == 0 {
+		return fmt.Errorf("no parts uploaded for %s", key)
+	}
+
+	_, err = client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(d.bucket),
+		Key:      aws.String(objectName(d.prefix, key)),
+		UploadId: aws.String(id),
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: completed,
+		},
+	})
+	return err
+}
+
+// AbortMultipartUpload discards the upload. Uploads
+// which no longer exist are considered aborted
+func (d *s3Destination) AbortMultipartUpload(ctx context.Context, key, id string) error {
+	_, err := s3.New(d.session).AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(d.bucket),
+		Key:      aws.String(objectName(d.prefix, key)),
+		UploadId: aws.String(id),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchUpload {
+		return nil
+	}
+	return err
+}
+
+// PresignDownload authorizes the download with a presigned GetObject request
+func (d *s3Destination) PresignDownload(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error) {
+	request, _ := s3.New(d.session).GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(objectName(d.prefix, key)),
+	})
+	request.SetContext(ctx)
+
+	url, err := request.Presign(expires)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PresignedRequest{
+		Method: http.MethodGet,
+		URL:    url,
+	}, nil
+}
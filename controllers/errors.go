@@ -15,4 +15,36 @@ var (
 	ErrDatabaseNotFound = errors.New("database restore not found")
 	// ErrPachdPodsRunning is returned when pachd pods are running while in maintenance mode
 	ErrPachdPodsRunning = errors.New("pachd pods still running")
+	// ErrChecksumMismatch is returned when the backup contents do not match the digests recorded during export
+	ErrChecksumMismatch = errors.New("backup checksum mismatch")
+	// ErrBackupFailed is returned when a pachyderm export can not be completed
+	ErrBackupFailed = errors.New("backup failed")
+	// ErrPauseTimeout is returned when a pachyderm export keeps the cluster paused past its timeout
+	ErrPauseTimeout = errors.New("pachyderm cluster paused longer than the pause timeout")
+	// ErrVerificationTimeout is returned when the backup verification runs past its timeout
+	ErrVerificationTimeout = errors.New("backup verification did not complete before the verification timeout")
+	// ErrEmptyDatabase is returned when a restored database dump contains no tables
+	ErrEmptyDatabase = errors.New("restored database contains no tables")
+	// ErrStoragePodNotReady is returned while the pod mounting a backup volume is starting
+	ErrStoragePodNotReady = errors.New("waiting for backup storage pod")
+	// ErrMissingStorageConfig is returned when the object storage settings can not be resolved
+	ErrMissingStorageConfig = errors.New("object storage configuration incomplete")
+	// ErrStorageUnreachable is returned when the object storage pre-flight check fails
+	ErrStorageUnreachable = errors.New("object storage unreachable")
+	// ErrDatabaseNotReady is returned when an external database lacks the objects or grants required by pachd
+	ErrDatabaseNotReady = errors.New("database not ready")
+	// ErrPostgresOperatorMissing is returned when a highly available database is requested without the postgres operator installed
+	ErrPostgresOperatorMissing = errors.New("PostgresCluster resource not installed; install the Crunchy Data postgres operator")
+	// ErrVolumesResizing is returned while persistent volume claims are being expanded
+	ErrVolumesResizing = errors.New("waiting for volumes to be resized")
+	// ErrVolumeExpansionNotSupported is returned when the storage class of a volume does not allow expansion
+	ErrVolumeExpansionNotSupported = errors.New("volume expansion not supported by storage class")
+	// ErrEtcdScaling is returned while etcd members are being added or removed
+	ErrEtcdScaling = errors.New("waiting for etcd members to be added or removed")
+	// ErrEtcdQuorum is returned when removing an etcd member would leave the cluster without a quorum
+	ErrEtcdQuorum = errors.New("etcd cluster would lose quorum")
+	// ErrCertificatesPending is returned while certificates issued by the OpenShift service CA are not available
+	ErrCertificatesPending = errors.New("waiting for certificates")
+	// ErrMigrationFailed is returned when a pachyderm storage migration can not be completed
+	ErrMigrationFailed = errors.New("storage migration failed")
 )
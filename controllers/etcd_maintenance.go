@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"sort"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	defaultEtcdMaintenanceInterval       = 24 * time.Hour
+	defaultEtcdDefragmentThreshold int32 = 20
+	// time between checks of the database size and alarms
+	etcdCheckInterval      = 5 * time.Minute
+	etcdDefragmentTimeout  = 2 * time.Minute
+	etcdNoSpaceAlarm       = "NOSPACE"
+	etcdMaintenanceSuccess = "success"
+	etcdMaintenanceFailure = "failure"
+)
+
+// etcdMember is a started etcd member
+// and the status of its database
+type etcdMember struct {
+	endpoint string
+	leader   bool
+	revision int64
+	status   aimlv1beta1.EtcdMemberStatus
+}
+
+// maintainEtcd checks the database size of the etcd members and,
+// when the maintenance interval elapsed or a member raised a NOSPACE
+// alarm, compacts the key space, defragments the members one at a
+// time and clears the NOSPACE alarms. The database sizes are reported
+// in the status and as metrics
+func (r *PachydermReconciler) maintainEtcd(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	if pd.IsDeleted() {
+		forgetEtcdMetrics(pd)
+		return nil
+	}
+
+	if pd.Spec.Etcd.Maintenance.Disable || pd.Status.Phase != aimlv1beta1.PhaseRunning {
+		return nil
+	}
+
+	if checkAfter, ok := etcdCheckAfter(pd); !ok || checkAfter > 0 {
+		return nil
+	}
+
+	// members are not maintained while the cluster is scaled
+	etcd := &appsv1.StatefulSet{}
+	etcdKey := types.NamespacedName{
+		Name:      etcdStatefulSetName,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, etcdKey, etcd); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if etcd.Spec.Replicas == nil || *etcd.Spec.Replicas != pd.EtcdNodes() ||
+		etcd.Status.ReadyReplicas != *etcd.Spec.Replicas {
+		return nil
+	}
+
+	etcdClient, err := newEtcdClient(pd, *etcd.Spec.Replicas)
+	if err != nil {
+		return err
+	}
+	defer etcdClient.Close()
+
+	members, err := etcdMembers(ctx, etcdClient, pd)
+	if err != nil {
+		return err
+	}
+
+	previous := pd.Status.Etcd.DeepCopy()
+	status := &aimlv1beta1.EtcdStatus{}
+	if previous != nil {
+		status = previous.DeepCopy()
+		status.Members = nil
+	}
+
+	if etcdMaintenanceDue(pd) || hasEtcdAlarm(members, etcdNoSpaceAlarm) {
+		if err := runEtcdMaintenance(ctx, etcdClient, members, etcdDefragmentThreshold(pd)); err != nil {
+			etcdMaintenanceTotal.WithLabelValues(pd.Namespace, pd.Name, etcdMaintenanceFailure).Inc()
+			return err
+		}
+		etcdMaintenanceTotal.WithLabelValues(pd.Namespace, pd.Name, etcdMaintenanceSuccess).Inc()
+		log.FromContext(ctx).Info("compacted and defragmented etcd", "pachyderm", pd.Name)
+
+		maintainedAt := metav1.Now()
+		status.LastMaintenanceTime = &maintainedAt
+		if members, err = etcdMembers(ctx, etcdClient, pd); err != nil {
+			return err
+		}
+	}
+
+	checkedAt := metav1.Now()
+	status.LastCheckTime = &checkedAt
+	for _, member := range members {
+		status.Members = append(status.Members, member.status)
+	}
+
+	current := pd.DeepCopy()
+	pd.Status.Etcd = status
+	if err := r.Status().Patch(ctx, pd, client.MergeFrom(current)); err != nil {
+		return err
+	}
+
+	recordEtcdMetrics(pd, previous)
+	return nil
+}
+
+// runEtcdMaintenance compacts the key space up to the current
+// revision, then defragments the members whose unused space exceeds
+// the threshold or that raised an alarm. Followers are defragmented
+// before the leader, one member at a time
+func runEtcdMaintenance(ctx context.Context, etcdClient *clientv3.Client, members []etcdMember, threshold int32) error {
+	revision := int64(0)
+	for _, member := range members {
+		if member.revision > revision {
+			revision = member.revision
+		}
+	}
+	compactCtx, cancel := context.WithTimeout(ctx, etcdRequestTimeout)
+	_, err := etcdClient.Compact(compactCtx, revision, clientv3.WithCompactPhysical())
+	cancel()
+	// compacted by the auto compaction of etcd
+	if err != nil && !goerrors.Is(err, rpctypes.ErrCompacted) {
+		return err
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return !members[i].leader && members[j].leader
+	})
+	for _, member := range members {
+		size := member.status.DBSize
+		unused := size - member.status.DBSizeInUse
+		if len(member.status.Alarms) == 0 && (size == 0 || unused*100/size < int64(threshold)) {
+			continue
+		}
+
+		defragmentCtx, cancel := context.WithTimeout(ctx, etcdDefragmentTimeout)
+		_, err := etcdClient.Defragment(defragmentCtx, member.endpoint)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, etcdRequestTimeout)
+	defer cancel()
+
+	alarms, err := etcdClient.AlarmList(requestCtx)
+	if err != nil {
+		return err
+	}
+	for _, alarm := range alarms.Alarms {
+		if alarm.Alarm != etcdserverpb.AlarmType_NOSPACE {
+			continue
+		}
+		if _, err := etcdClient.AlarmDisarm(requestCtx, (*clientv3.AlarmMember)(alarm)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// etcdMembers returns the started etcd members
+// with the database size and alarms they report
+func etcdMembers(ctx context.Context, etcdClient *clientv3.Client, pd *aimlv1beta1.Pachyderm) ([]etcdMember, error) {
+	requestCtx, cancel := context.WithTimeout(ctx, etcdRequestTimeout)
+	defer cancel()
+
+	list, err := etcdClient.MemberList(requestCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	alarms, err := etcdClient.AlarmList(requestCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []etcdMember{}
+	for _, m := range list.Members {
+		ordinal, err := etcdOrdinal(m.Name)
+		if err != nil {
+			continue
+		}
+
+		member := etcdMember{
+			endpoint: generators.EtcdClientURL(pd, ordinal),
+			status: aimlv1beta1.EtcdMemberStatus{
+				Name: m.Name,
+			},
+		}
+		status, err := etcdClient.Status(requestCtx, member.endpoint)
+		if err != nil {
+			return nil, err
+		}
+		member.leader = status.Leader == m.ID
+		member.revision = status.Header.Revision
+		member.status.DBSize = status.DbSize
+		member.status.DBSizeInUse = status.DbSizeInUse
+
+		for _, alarm := range alarms.Alarms {
+			if alarm.MemberID == m.ID && alarm.Alarm != etcdserverpb.AlarmType_NONE {
+				member.status.Alarms = append(member.status.Alarms, alarm.Alarm.String())
+			}
+		}
+
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+func hasEtcdAlarm(members []etcdMember, alarm string) bool {
+	for _, member := range members {
+		for _, raised := range member.status.Alarms {
+			if raised == alarm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// etcdMaintenanceDue returns true if the maintenance
+// interval elapsed since etcd was last maintained
+func etcdMaintenanceDue(pd *aimlv1beta1.Pachyderm) bool {
+	interval := defaultEtcdMaintenanceInterval
+	if maintenance := pd.Spec.Etcd.Maintenance; maintenance.Interval != nil && maintenance.Interval.Duration > 0 {
+		interval = maintenance.Interval.Duration
+	}
+
+	maintainedAt := pd.CreationTimestamp.Time
+	if status := pd.Status.Etcd; status != nil && status.LastMaintenanceTime != nil {
+		maintainedAt = status.LastMaintenanceTime.Time
+	}
+
+	return time.Since(maintainedAt) >= interval
+}
+
+// etcdCheckAfter returns the time until the database
+// size of the etcd members is checked again
+func etcdCheckAfter(pd *aimlv1beta1.Pachyderm) (time.Duration, bool) {
+	if pd.IsDeleted() || pd.Spec.Etcd.Maintenance.Disable {
+		return 0, false
+	}
+
+	status := pd.Status.Etcd
+	if status == nil || status.LastCheckTime == nil {
+		return 0, true
+	}
+
+	return time.Until(status.LastCheckTime.Add(etcdCheckInterval)), true
+}
+
+func etcdDefragmentThreshold(pd *aimlv1beta1.Pachyderm) int32 {
+	if threshold := pd.Spec.Etcd.Maintenance.DefragmentThreshold; threshold != nil {
+		return *threshold
+	}
+	return defaultEtcdDefragmentThreshold
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	etcdStatefulSetName string = "etcd"
+	etcdClaimTemplate   string = "etcd-storage"
+	etcdDialTimeout            = 5 * time.Second
+	etcdRequestTimeout         = 10 * time.Second
+)
+
+// scaleEtcd changes the number of etcd members to the requested
+// number of nodes, one member at a time. A member is added through
+// the etcd cluster API before its pod is created and removed before
+// its pod is deleted, so the running members always form a quorum
+func (r *PachydermReconciler) scaleEtcd(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	if pd.IsDeleted() {
+		return nil
+	}
+
+	etcd := &appsv1.StatefulSet{}
+	etcdKey := types.NamespacedName{
+		Name:      etcdStatefulSetName,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, etcdKey, etcd); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	replicas := int32(1)
+	if etcd.Spec.Replicas != nil {
+		replicas = *etcd.Spec.Replicas
+	}
+
+	// a removed member can not rejoin with its previous data
+	if err := r.removeStaleEtcdClaims(ctx, pd.Namespace, replicas); err != nil {
+		return err
+	}
+
+	desired := pd.EtcdNodes()
+	if replicas == desired {
+		return nil
+	}
+
+	// wait for the previous member change to roll out
+	if etcd.Status.ObservedGeneration < etcd.Generation ||
+		etcd.Status.ReadyReplicas != replicas ||
+		etcd.Status.CurrentRevision != etcd.Status.UpdateRevision {
+		return ErrEtcdScaling
+	}
+
+	members := replicas - 1
+	if replicas < desired {
+		members = replicas + 1
+	}
+
+	changeMembers := changeEtcdMembers
+	if r.changeEtcdMembers != nil {
+		changeMembers = r.changeEtcdMembers
+	}
+	if err := changeMembers(ctx, pd, replicas, members); err != nil {
+		return err
+	}
+	replicas = members
+
+	current := etcd.DeepCopy()
+	generators.SetEtcdMembers(pd, etcd, replicas)
+	if err := r.Patch(ctx, etcd, client.MergeFrom(current)); err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Info("scaling etcd", "pachyderm", pd.Name, "members", replicas, "requested", desired)
+	return ErrEtcdScaling
+}
+
+// changeEtcdMembers adds or removes the member with the highest
+// ordinal through the etcd cluster API of the current members
+func changeEtcdMembers(ctx context.Context, pd *aimlv1beta1.Pachyderm, current, members int32) error {
+	etcdClient, err := newEtcdClient(pd, current)
+	if err != nil {
+		return err
+	}
+	defer etcdClient.Close()
+
+	requestCtx, cancel := context.WithTimeout(ctx, etcdRequestTimeout)
+	defer cancel()
+
+	if members > current {
+		return addEtcdMember(requestCtx, etcdClient, pd, current)
+	}
+	return removeEtcdMember(requestCtx, etcdClient, pd, members)
+}
+
+// newEtcdClient returns a client connected to the given
+// number of etcd members, over TLS when it is enabled
+func newEtcdClient(pd *aimlv1beta1.Pachyderm, members int32) (*clientv3.Client, error) {
+	endpoints := []string{}
+	for i := int32(0); i < members; i++ {
+		endpoints = append(endpoints, generators.EtcdClientURL(pd, i))
+	}
+
+	config := clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdDialTimeout,
+		Logger:      zap.NewNop(),
+	}
+	if options := pd.Spec.Etcd.TLS; options != nil {
+		tlsConfig, err := etcdClientTLSConfig(options)
+		if err != nil {
+			return nil, err
+		}
+		config.TLS = tlsConfig
+	}
+
+	return clientv3.New(config)
+}
+
+// addEtcdMember adds the member of the pod with the given ordinal
+// to the etcd cluster once all current members are healthy
+func addEtcdMember(ctx context.Context, etcdClient *clientv3.Client, pd *aimlv1beta1.Pachyderm, ordinal int32) error {
+	members, err := etcdClient.MemberList(ctx)
+	if err != nil {
+		return err
+	}
+
+	peerURL := generators.EtcdPeerURL(pd, ordinal)
+	healthy := 0
+	for _, member := range members.Members {
+		if hasPeerURL(member.PeerURLs, peerURL) {
+			// added by a previous reconcile
+			return nil
+		}
+		if isEtcdMemberHealthy(ctx, etcdClient, pd, member.Name) {
+			healthy++
+		}
+	}
+
+	if healthy < len(members.Members) {
+		return fmt.Errorf("%w: %d of %d members healthy", ErrEtcdScaling, healthy, len(members.Members))
+	}
+
+	_, err = etcdClient.MemberAdd(ctx, []string{peerURL})
+	return err
+}
+
+// removeEtcdMember removes the member of the pod with the given
+// ordinal from the etcd cluster if the remaining healthy members
+// form a quorum
+func removeEtcdMember(ctx context.Context, etcdClient *clientv3.Client, pd *aimlv1beta1.Pachyderm, ordinal int32) error {
+	members, err := etcdClient.MemberList(ctx)
+	if err != nil {
+		return err
+	}
+
+	peerURL := generators.EtcdPeerURL(pd, ordinal)
+	var memberID uint64
+	found := false
+	healthy := 0
+	for _, member := range members.Members {
+		if hasPeerURL(member.PeerURLs, peerURL) {
+			memberID = member.ID
+			found = true
+			continue
+		}
+		if isEtcdMemberHealthy(ctx, etcdClient, pd, member.Name) {
+			healthy++
+		}
+	}
+
+	if !found {
+		// removed by a previous reconcile
+		return nil
+	}
+
+	remaining := len(members.Members) - 1
+	if quorum := remaining/2 + 1; healthy < quorum {
+		return fmt.Errorf("%w: removing %s leaves %d healthy members, %d are required",
+			ErrEtcdQuorum, generators.EtcdMemberName(ordinal), healthy, quorum)
+	}
+
+	_, err = etcdClient.MemberRemove(ctx, memberID)
+	return err
+}
+
+// isEtcdMemberHealthy returns true if the member
+// is started and reports its status to clients
+func isEtcdMemberHealthy(ctx context.Context, etcdClient *clientv3.Client, pd *aimlv1beta1.Pachyderm, name string) bool {
+	// members that have not started have no name
+	ordinal, err := etcdOrdinal(name)
+	if err != nil {
+		return false
+	}
+
+	_, err = etcdClient.Status(ctx, generators.EtcdClientURL(pd, ordinal))
+	return err == nil
+}
+
+// removeStaleEtcdClaims deletes the volumes of etcd members
+// removed from the cluster, so a pod created with the same
+// ordinal joins the cluster as a new member
+func (r *PachydermReconciler) removeStaleEtcdClaims(ctx context.Context, namespace string, replicas int32) error {
+	claims := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, claims,
+		client.InNamespace(namespace),
+		client.MatchingLabels{"app": "etcd", "suite": "pachyderm"},
+	); err != nil {
+		return err
+	}
+
+	prefix := etcdClaimTemplate + "-"
+	for i := range claims.Items {
+		claim := &claims.Items[i]
+		if !strings.HasPrefix(claim.Name, prefix) {
+			continue
+		}
+		ordinal, err := etcdOrdinal(strings.TrimPrefix(claim.Name, prefix))
+		if err != nil || ordinal < replicas {
+			continue
+		}
+
+		if err := r.Delete(ctx, claim); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// etcdOrdinal returns the ordinal of an etcd pod name
+func etcdOrdinal(name string) (int32, error) {
+	ordinal, err := strconv.ParseInt(strings.TrimPrefix(name, etcdStatefulSetName+"-"), 10, 32)
+	if err != nil || !strings.HasPrefix(name, etcdStatefulSetName+"-") {
+		return 0, fmt.Errorf("%s is not an etcd member", name)
+	}
+	return int32(ordinal), nil
+}
+
+func hasPeerURL(peerURLs []string, peerURL string) bool {
+	for _, url := range peerURLs {
+		if url == peerURL {
+			return true
+		}
+	}
+	return false
+}
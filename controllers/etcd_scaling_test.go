@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+// etcdClaim returns the volume claim of the etcd pod with the ordinal
+func etcdClaim(ordinal int32, data string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s-%d", etcdClaimTemplate, etcdStatefulSetName, ordinal),
+			Namespace: "default",
+			Labels: map[string]string{
+				"app":   "etcd",
+				"suite": "pachyderm",
+			},
+			Annotations: map[string]string{
+				"data": data,
+			},
+		},
+	}
+}
+
+// rollOutEtcd acts as the statefulset controller, marking
+// every replica ready and creating missing volume claims
+func rollOutEtcd(t *testing.T, c client.Client, data string) {
+	t.Helper()
+
+	ctx := context.Background()
+	etcd := &appsv1.StatefulSet{}
+	if err := c.Get(ctx, client.ObjectKey{Name: etcdStatefulSetName, Namespace: "default"}, etcd); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := int32(0); i < *etcd.Spec.Replicas; i++ {
+		claim := etcdClaim(i, data)
+		if err := c.Get(ctx, client.ObjectKeyFromObject(claim), &corev1.PersistentVolumeClaim{}); err == nil {
+			continue
+		}
+		if err := c.Create(ctx, claim); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	etcd.Status.Replicas = *etcd.Spec.Replicas
+	etcd.Status.ReadyReplicas = *etcd.Spec.Replicas
+	etcd.Status.ObservedGeneration = etcd.Generation
+	if err := c.Status().Update(ctx, etcd); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScaleEtcd(t *testing.T) {
+	pd := &aimlv1beta1.Pachyderm{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pachyderm",
+			Namespace: "default",
+		},
+		Spec: aimlv1beta1.PachydermSpec{
+			Etcd: aimlv1beta1.EtcdOptions{
+				DynamicNodes: 3,
+			},
+		},
+	}
+	replicas := int32(3)
+	etcd := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      etcdStatefulSetName,
+			Namespace: pd.Namespace,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "etcd"},
+					},
+				},
+			},
+		},
+	}
+
+	c, scheme := newFakeClient(t, etcd)
+	rollOutEtcd(t, c, "initial")
+
+	members := int32(3)
+	r := &PachydermReconciler{
+		Client: c,
+		Scheme: scheme,
+		changeEtcdMembers: func(ctx context.Context, pd *aimlv1beta1.Pachyderm, current, requested int32) error {
+			if current != members {
+				return fmt.Errorf("expected %d members, got %d", members, current)
+			}
+			if requested > current {
+				// the claim of an added member must not hold previous data
+				claim := etcdClaim(current, "")
+				if err := c.Get(ctx, client.ObjectKeyFromObject(claim), claim); err == nil {
+					return fmt.Errorf("member %d added with the claim %s", current, claim.Name)
+				}
+			}
+			members = requested
+			return nil
+		},
+	}
+
+	ctx := context.Background()
+	for _, nodes := range []int32{1, 3} {
+		pd.Spec.Etcd.DynamicNodes = nodes
+
+		for i := 0; ; i++ {
+			if i > 5 {
+				t.Fatalf("etcd did not scale to %d members", nodes)
+			}
+
+			err := r.scaleEtcd(ctx, pd)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, ErrEtcdScaling) {
+				t.Fatal(err)
+			}
+			rollOutEtcd(t, c, fmt.Sprintf("scaled to %d", nodes))
+		}
+
+		if members != nodes {
+			t.Fatalf("expected %d members, got %d", nodes, members)
+		}
+	}
+
+	claims := &corev1.PersistentVolumeClaimList{}
+	if err := c.List(ctx, claims, client.InNamespace(pd.Namespace)); err != nil {
+		t.Fatal(err)
+	}
+	if len(claims.Items) != 3 {
+		t.Fatalf("expected 3 claims, got %d", len(claims.Items))
+	}
+	for _, claim := range claims.Items {
+		expected := "scaled to 3"
+		if claim.Name == etcdClaim(0, "").Name {
+			expected = "initial"
+		}
+		if claim.Annotations["data"] != expected {
+			t.Errorf("expected claim %s to hold %q data, got %q", claim.Name, expected, claim.Annotations["data"])
+		}
+	}
+}
+
+// TestScaleEtcdPermissions checks the operator role
+// grants the verbs scaleEtcd uses on volume claims
+func TestScaleEtcdPermissions(t *testing.T) {
+	data, err := ioutil.ReadFile("../config/rbac/role.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role := &rbacv1.ClusterRole{}
+	if err := yaml.Unmarshal(data, role); err != nil {
+		t.Fatal(err)
+	}
+
+	granted := map[string]bool{}
+	for _, rule := range role.Rules {
+		for _, resource := range rule.Resources {
+			if resource != "persistentvolumeclaims" {
+				continue
+			}
+			for _, verb := range rule.Verbs {
+				granted[verb] = true
+			}
+		}
+	}
+
+	for _, verb := range []string{"list", "delete"} {
+		if !granted[verb] {
+			t.Errorf("operator role does not grant %s on persistentvolumeclaims", verb)
+		}
+	}
+}
@@ -0,0 +1,573 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	// secret holding the certificate authority issuing the etcd certificates
+	etcdCASecretName string = "etcd-ca"
+	etcdCAKey        string = "ca.crt"
+	// annotation requesting the OpenShift service CA to inject its bundle
+	serviceCAInjectAnnotation string = "service.beta.openshift.io/inject-cabundle"
+	serviceCABundleKey        string = "service-ca.crt"
+
+	etcdCAValidity                 = 10 * 365 * 24 * time.Hour
+	defaultEtcdCertificateValidity = 365 * 24 * time.Hour
+	defaultEtcdRenewBefore         = 30 * 24 * time.Hour
+)
+
+// reconcileEtcdTLS provisions the certificates of the etcd TLS
+// source and loads the certificate authorities and the client
+// certificate used by the operator to connect to etcd.
+// Certificates issued by the operator are replaced before they
+// expire, and the etcd pods are restarted when the trusted
+// certificate authorities change
+func (r *PachydermReconciler) reconcileEtcdTLS(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	if pd.Spec.Etcd.TLS == nil || pd.IsDeleted() {
+		return nil
+	}
+
+	var err error
+	switch pd.Spec.Etcd.TLS.Source {
+	case aimlv1beta1.EtcdTLSSecretSource:
+		err = r.loadEtcdTLSSecrets(ctx, pd)
+	case aimlv1beta1.EtcdTLSServiceCASource:
+		err = r.loadEtcdServiceCA(ctx, pd)
+	default:
+		err = r.issueEtcdCertificates(ctx, pd)
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.restartEtcdOnCAChange(ctx, pd)
+}
+
+// loadEtcdTLSSecrets reads the certificate authorities and the
+// client certificate from the secrets referenced by the resource
+func (r *PachydermReconciler) loadEtcdTLSSecrets(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	options := pd.Spec.Etcd.TLS
+	server, peer, clientSecret := generators.EtcdTLSSecrets(pd)
+
+	bundle := []byte{}
+	for _, name := range []string{server, peer, clientSecret} {
+		secret := &corev1.Secret{}
+		secretKey := types.NamespacedName{
+			Name:      name,
+			Namespace: pd.Namespace,
+		}
+		if err := r.Get(ctx, secretKey, secret); err != nil {
+			return err
+		}
+
+		for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey, etcdCAKey} {
+			if _, ok := secret.Data[key]; !ok {
+				return NewKeyError(
+					fmt.Sprintf("the key %s missing in secret %s",
+						key,
+						secretKey.Name),
+				)
+			}
+		}
+
+		if !bytes.Contains(bundle, secret.Data[etcdCAKey]) {
+			bundle = append(bundle, secret.Data[etcdCAKey]...)
+		}
+		if name == clientSecret {
+			options.ClientCertificate = secret.Data[corev1.TLSCertKey]
+			options.ClientKey = secret.Data[corev1.TLSPrivateKeyKey]
+		}
+	}
+	options.CABundleData = bundle
+
+	return nil
+}
+
+// loadEtcdServiceCA reads the certificate authority of the
+// OpenShift service CA. The serving certificate is requested
+// on the etcd headless service
+func (r *PachydermReconciler) loadEtcdServiceCA(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	bundle, err := r.serviceCABundle(ctx, pd, aimlv1beta1.EtcdServiceCABundleName, "etcd")
+	if err != nil {
+		return err
+	}
+	pd.Spec.Etcd.TLS.CABundleData = bundle
+
+	return nil
+}
+
+// serviceCABundle creates the config map the OpenShift service
+// CA injects its certificate authority into and reads the bundle
+func (r *PachydermReconciler) serviceCABundle(ctx context.Context, pd *aimlv1beta1.Pachyderm, name, app string) ([]byte, error) {
+	cm := &corev1.ConfigMap{}
+	cmKey := types.NamespacedName{
+		Name:      name,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, cmKey, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cmKey.Name,
+				Namespace: cmKey.Namespace,
+				Labels: map[string]string{
+					"app":   app,
+					"suite": "pachyderm",
+				},
+				Annotations: map[string]string{
+					serviceCAInjectAnnotation: "true",
+				},
+			},
+		}
+		if err := controllerutil.SetControllerReference(pd, cm, r.Scheme); err != nil {
+			return nil, err
+		}
+		if err := r.Create(ctx, cm); err != nil {
+			return nil, err
+		}
+	}
+
+	bundle, ok := cm.Data[serviceCABundleKey]
+	if !ok {
+		return nil, fmt.Errorf("%w: config map %s", ErrCertificatesPending, cmKey.Name)
+	}
+
+	return []byte(bundle), nil
+}
+
+// issueEtcdCertificates creates the certificate authority and the
+// server, peer and client certificates of etcd, and replaces them
+// when they are about to expire. A replaced certificate authority
+// stays trusted until it expires, and certificates it signed are
+// only replaced once etcd and pachd trust the new authority
+func (r *PachydermReconciler) issueEtcdCertificates(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	options := pd.Spec.Etcd.TLS
+	renewBefore := defaultEtcdRenewBefore
+	if options.RenewBefore != nil && options.RenewBefore.Duration > 0 {
+		renewBefore = options.RenewBefore.Duration
+	}
+	validity := defaultEtcdCertificateValidity
+	if options.Validity != nil && options.Validity.Duration > 0 {
+		validity = options.Validity.Duration
+	}
+
+	caSecret, err := r.etcdTLSSecret(ctx, pd, etcdCASecretName)
+	if err != nil {
+		return err
+	}
+	ca, caKey, err := parseKeyPair(caSecret.Data[corev1.TLSCertKey], caSecret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil || time.Until(ca.NotAfter) < renewBefore {
+		template := &x509.Certificate{
+			Subject:               pkix.Name{CommonName: "pachyderm-etcd-ca"},
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		certificate, key, err := issueCertificate(template, etcdCAValidity, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		// clients keep trusting the previous certificate
+		// authority until every certificate is replaced
+		bundle := certificate
+		if ca != nil && time.Now().Before(ca.NotAfter) {
+			bundle = append(append([]byte{}, certificate...), caSecret.Data[corev1.TLSCertKey]...)
+		}
+		caSecret.Data = map[string][]byte{
+			corev1.TLSCertKey:       certificate,
+			corev1.TLSPrivateKeyKey: key,
+			etcdCAKey:               bundle,
+		}
+		if err := r.saveEtcdTLSSecret(ctx, pd, caSecret); err != nil {
+			return err
+		}
+		if ca, caKey, err = parseKeyPair(certificate, key); err != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("issued etcd certificate authority", "pachyderm", pd.Name)
+	}
+	bundle := caSecret.Data[etcdCAKey]
+	options.CABundleData = bundle
+	var trusted *bool
+
+	names := etcdDNSNames(pd.Namespace)
+	leaves := []struct {
+		secret   string
+		template *x509.Certificate
+	}{
+		{
+			secret: generators.EtcdServerSecretName,
+			template: &x509.Certificate{
+				Subject: pkix.Name{CommonName: "etcd"},
+				// the etcd gateway presents the server
+				// certificate when it connects to etcd
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+				DNSNames:    names,
+				IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			},
+		},
+		{
+			secret: generators.EtcdPeerSecretName,
+			template: &x509.Certificate{
+				Subject:     pkix.Name{CommonName: "etcd-peer"},
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+				DNSNames:    names,
+			},
+		},
+		{
+			secret: generators.EtcdClientSecretName,
+			template: &x509.Certificate{
+				Subject:     pkix.Name{CommonName: "pachyderm-etcd-client"},
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			},
+		},
+	}
+
+	var expireTime time.Time
+	var clientCertificate, clientKey []byte
+	for _, leaf := range leaves {
+		secret, err := r.etcdTLSSecret(ctx, pd, leaf.secret)
+		if err != nil {
+			return err
+		}
+
+		certificate, _, err := parseKeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		reissue := err != nil || time.Until(certificate.NotAfter) < renewBefore
+		if err == nil && certificate.CheckSignatureFrom(ca) != nil {
+			// peers and clients reject certificates signed by the new
+			// authority until they restart with the new bundle. Expired
+			// certificates are replaced right away as they are rejected
+			reissue = true
+			if time.Now().Before(certificate.NotAfter) {
+				if trusted == nil {
+					rolledOut, err := r.etcdBundleRolledOut(ctx, pd)
+					if err != nil {
+						return err
+					}
+					trusted = &rolledOut
+				}
+				reissue = *trusted
+			}
+		}
+
+		if reissue {
+			leaf.template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+			certificatePEM, keyPEM, err := issueCertificate(leaf.template, validity, ca, caKey)
+			if err != nil {
+				return err
+			}
+			secret.Data = map[string][]byte{
+				corev1.TLSCertKey:       certificatePEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+			}
+			if certificate, _, err = parseKeyPair(certificatePEM, keyPEM); err != nil {
+				return err
+			}
+			log.FromContext(ctx).Info("issued etcd certificate", "pachyderm", pd.Name, "secret", leaf.secret)
+		}
+
+		if !bytes.Equal(secret.Data[etcdCAKey], bundle) || secret.ResourceVersion == "" {
+			secret.Data[etcdCAKey] = bundle
+			if err := r.saveEtcdTLSSecret(ctx, pd, secret); err != nil {
+				return err
+			}
+		}
+
+		if expireTime.IsZero() || certificate.NotAfter.Before(expireTime) {
+			expireTime = certificate.NotAfter
+		}
+		if leaf.secret == generators.EtcdClientSecretName {
+			clientCertificate = secret.Data[corev1.TLSCertKey]
+			clientKey = secret.Data[corev1.TLSPrivateKeyKey]
+		}
+	}
+
+	status := pd.Status.Etcd
+	if status == nil || status.CertificatesExpireTime == nil || !status.CertificatesExpireTime.Time.Equal(expireTime) {
+		current := pd.DeepCopy()
+		if pd.Status.Etcd == nil {
+			pd.Status.Etcd = &aimlv1beta1.EtcdStatus{}
+		}
+		expiresAt := metav1.NewTime(expireTime)
+		pd.Status.Etcd.CertificatesExpireTime = &expiresAt
+		if err := r.Status().Patch(ctx, pd, client.MergeFrom(current)); err != nil {
+			return err
+		}
+	}
+
+	// set once the status is patched, as the response replaces the resource
+	options = pd.Spec.Etcd.TLS
+	options.CABundleData = bundle
+	options.ClientCertificate = clientCertificate
+	options.ClientKey = clientKey
+
+	return nil
+}
+
+// etcdBundleRolledOut returns true once every etcd member and
+// pachd pod runs with the certificate authorities of the resource
+func (r *PachydermReconciler) etcdBundleRolledOut(ctx context.Context, pd *aimlv1beta1.Pachyderm) (bool, error) {
+	hash := generators.EtcdCAHash(pd)
+
+	etcd := &appsv1.StatefulSet{}
+	etcdKey := types.NamespacedName{
+		Name:      etcdStatefulSetName,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, etcdKey, etcd); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+	} else {
+		replicas := int32(1)
+		if etcd.Spec.Replicas != nil {
+			replicas = *etcd.Spec.Replicas
+		}
+		status := etcd.Status
+		if etcd.Spec.Template.Annotations[generators.EtcdCAHashAnnotation] != hash ||
+			status.ObservedGeneration < etcd.Generation ||
+			status.UpdatedReplicas != replicas ||
+			status.ReadyReplicas != replicas ||
+			status.CurrentRevision != status.UpdateRevision {
+			return false, nil
+		}
+	}
+
+	pachd := &appsv1.Deployment{}
+	pachdKey := types.NamespacedName{
+		Name:      "pachd",
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, pachdKey, pachd); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	replicas := int32(1)
+	if pachd.Spec.Replicas != nil {
+		replicas = *pachd.Spec.Replicas
+	}
+	status := pachd.Status
+	if pachd.Spec.Template.Annotations[generators.EtcdCAHashAnnotation] != hash ||
+		status.ObservedGeneration < pachd.Generation ||
+		status.UpdatedReplicas < replicas ||
+		status.Replicas > status.UpdatedReplicas {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// restartEtcdOnCAChange rolls the etcd pods when the certificate
+// authorities they trust change, as etcd only reads them on startup
+func (r *PachydermReconciler) restartEtcdOnCAChange(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	etcd := &appsv1.StatefulSet{}
+	etcdKey := types.NamespacedName{
+		Name:      etcdStatefulSetName,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, etcdKey, etcd); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	hash := generators.EtcdCAHash(pd)
+	if etcd.Spec.Template.Annotations[generators.EtcdCAHashAnnotation] == hash {
+		return nil
+	}
+
+	current := etcd.DeepCopy()
+	if etcd.Spec.Template.Annotations == nil {
+		etcd.Spec.Template.Annotations = map[string]string{}
+	}
+	etcd.Spec.Template.Annotations[generators.EtcdCAHashAnnotation] = hash
+	return r.Patch(ctx, etcd, client.MergeFrom(current))
+}
+
+// etcdTLSSecret returns the secret with the given name,
+// or a new secret owned by the pachyderm resource
+func (r *PachydermReconciler) etcdTLSSecret(ctx context.Context, pd *aimlv1beta1.Pachyderm, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      name,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretKey.Name,
+				Namespace: secretKey.Namespace,
+				Labels: map[string]string{
+					"app":   "etcd",
+					"suite": "pachyderm",
+				},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{},
+		}
+		if err := controllerutil.SetControllerReference(pd, secret, r.Scheme); err != nil {
+			return nil, err
+		}
+	}
+
+	return secret, nil
+}
+
+func (r *PachydermReconciler) saveEtcdTLSSecret(ctx context.Context, pd *aimlv1beta1.Pachyderm, secret *corev1.Secret) error {
+	if secret.ResourceVersion == "" {
+		return r.Create(ctx, secret)
+	}
+	return r.Update(ctx, secret)
+}
+
+// etcdCertificatesRenewAfter returns the time until the
+// certificates issued by the operator are replaced
+func etcdCertificatesRenewAfter(pd *aimlv1beta1.Pachyderm) (time.Duration, bool) {
+	options := pd.Spec.Etcd.TLS
+	if options == nil || options.Source != aimlv1beta1.EtcdTLSOperatorSource || pd.IsDeleted() ||
+		pd.Status.Etcd == nil || pd.Status.Etcd.CertificatesExpireTime == nil {
+		return 0, false
+	}
+
+	renewBefore := defaultEtcdRenewBefore
+	if options.RenewBefore != nil && options.RenewBefore.Duration > 0 {
+		renewBefore = options.RenewBefore.Duration
+	}
+
+	return time.Until(pd.Status.Etcd.CertificatesExpireTime.Add(-renewBefore)), true
+}
+
+// etcdClientTLSConfig returns the TLS configuration
+// used by the operator to connect to etcd
+func etcdClientTLSConfig(options *aimlv1beta1.EtcdTLSOptions) (*tls.Config, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(options.CABundleData) {
+		return nil, fmt.Errorf("%w: etcd certificate authorities", ErrCertificatesPending)
+	}
+
+	config := &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	if len(options.ClientCertificate) > 0 {
+		certificate, err := tls.X509KeyPair(options.ClientCertificate, options.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// etcdDNSNames returns the names of the etcd
+// service and of the members of the statefulset
+func etcdDNSNames(namespace string) []string {
+	return []string{
+		"localhost",
+		"etcd",
+		fmt.Sprintf("etcd.%s", namespace),
+		fmt.Sprintf("etcd.%s.svc", namespace),
+		fmt.Sprintf("etcd.%s.svc.cluster.local", namespace),
+		fmt.Sprintf("*.etcd-headless.%s.svc", namespace),
+		fmt.Sprintf("*.etcd-headless.%s.svc.cluster.local", namespace),
+	}
+}
+
+// issueCertificate signs the template with the certificate authority,
+// or self-signs it when no authority is given. It returns the
+// certificate and its private key PEM encoded
+func issueCertificate(template *x509.Certificate, validity time.Duration, ca *x509.Certificate, caKey crypto.Signer) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-5 * time.Minute)
+	template.NotAfter = time.Now().Add(validity)
+
+	parent, signer := template, crypto.Signer(key)
+	if ca != nil {
+		parent, signer = ca, caKey
+		if template.NotAfter.After(ca.NotAfter) {
+			template.NotAfter = ca.NotAfter
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		nil
+}
+
+// parseKeyPair returns the certificate and private key of a PEM
+// encoded key pair. The first certificate of a bundle is returned
+func parseKeyPair(certificatePEM, keyPEM []byte) (*x509.Certificate, crypto.Signer, error) {
+	pair, err := tls.X509KeyPair(certificatePEM, keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key type %T", pair.PrivateKey)
+	}
+
+	return certificate, signer, nil
+}
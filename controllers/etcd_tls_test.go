@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+// parseCertificate returns the first certificate of the PEM bundle
+func parseCertificate(t *testing.T, data []byte) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("no certificate found")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate
+}
+
+func TestIssueEtcdCertificatesRollsOutCA(t *testing.T) {
+	pd := &aimlv1beta1.Pachyderm{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pachyderm",
+			Namespace: "default",
+		},
+		Spec: aimlv1beta1.PachydermSpec{
+			Etcd: aimlv1beta1.EtcdOptions{
+				TLS: &aimlv1beta1.EtcdTLSOptions{
+					Source: aimlv1beta1.EtcdTLSOperatorSource,
+				},
+			},
+		},
+	}
+	replicas := int32(1)
+	etcd := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      etcdStatefulSetName,
+			Namespace: pd.Namespace,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+		},
+	}
+
+	c, scheme := newFakeClient(t, pd, etcd)
+	r := &PachydermReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+
+	// rollOut sets the revision the etcd pods run
+	rollOut := func(revision string) {
+		t.Helper()
+		current := &appsv1.StatefulSet{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(etcd), current); err != nil {
+			t.Fatal(err)
+		}
+		current.Status = appsv1.StatefulSetStatus{
+			Replicas:        replicas,
+			ReadyReplicas:   replicas,
+			UpdatedReplicas: replicas,
+			CurrentRevision: revision,
+			UpdateRevision:  "updated",
+		}
+		if err := c.Status().Update(ctx, current); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret := func(name string) *corev1.Secret {
+		t.Helper()
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: pd.Namespace}, secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	if err := r.reconcileEtcdTLS(ctx, pd); err != nil {
+		t.Fatal(err)
+	}
+	rollOut("updated")
+	previousCA := parseCertificate(t, secret(etcdCASecretName).Data[corev1.TLSCertKey])
+
+	// the certificate authority is replaced before it expires
+	pd.Spec.Etcd.TLS.RenewBefore = &metav1.Duration{Duration: 20 * 365 * 24 * time.Hour}
+	if err := r.reconcileEtcdTLS(ctx, pd); err != nil {
+		t.Fatal(err)
+	}
+	pd.Spec.Etcd.TLS.RenewBefore = nil
+	ca := parseCertificate(t, secret(etcdCASecretName).Data[corev1.TLSCertKey])
+	if ca.Equal(previousCA) {
+		t.Fatal("expected a new certificate authority")
+	}
+
+	for _, revision := range []string{"previous", "updated"} {
+		rollOut(revision)
+		if err := r.reconcileEtcdTLS(ctx, pd); err != nil {
+			t.Fatal(err)
+		}
+
+		rolledOut := revision == "updated"
+		for _, name := range []string{generators.EtcdServerSecretName, generators.EtcdPeerSecretName, generators.EtcdClientSecretName} {
+			leaf := secret(name)
+			if !bytes.Contains(leaf.Data[etcdCAKey], pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})) ||
+				!bytes.Contains(leaf.Data[etcdCAKey], pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: previousCA.Raw})) {
+				t.Fatalf("expected %s to trust both certificate authorities", name)
+			}
+
+			certificate := parseCertificate(t, leaf.Data[corev1.TLSCertKey])
+			if signed := certificate.CheckSignatureFrom(ca) == nil; signed != rolledOut {
+				t.Fatalf("expected %s signed by the new certificate authority %t with etcd at the %s revision",
+					name, rolledOut, revision)
+			}
+		}
+	}
+}
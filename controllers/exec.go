@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// execResult holds the output of a command run in a container
+type execResult struct {
+	stdout string
+	stderr string
+}
+
+// execInPod runs a command in the container of a pod.
+// If stdin is not nil, it is streamed to the command.
+func execInPod(config *rest.Config, pod types.NamespacedName, container string, command []string, stdin io.Reader) (*execResult, error) {
+	var stdout bytes.Buffer
+	stderr, err := streamInPod(config, pod, container, command, stdin, &stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &execResult{
+		stdout: stdout.String(),
+		stderr: stderr,
+	}, nil
+}
+
+// streamInPod runs a command in the container of a pod and
+// writes its output to stdout as it is produced
+func streamInPod(config *rest.Config, pod types.NamespacedName, container string, command []string, stdin io.Reader, stdout io.Writer) (string, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	request := clientset.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(config, "POST", request.URL())
+	if err != nil {
+		return "", err
+	}
+
+	var stderr bytes.Buffer
+	if err := executor.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: &stderr,
+	}); err != nil {
+		return "", fmt.Errorf("%s: %s", err.Error(), stderr.String())
+	}
+
+	return stderr.String(), nil
+}
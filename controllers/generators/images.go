@@ -17,6 +17,7 @@ type ImageCatalog struct {
 	Worker    *aimlv1beta1.ImageOverride `json:"worker,omitempty"`
 	PgBouncer *aimlv1beta1.ImageOverride `json:"pgbouncer,omitempty"`
 	Utilities *aimlv1beta1.ImageOverride `json:"utilities,omitempty"`
+	Rclone    *aimlv1beta1.ImageOverride `json:"rclone,omitempty"`
 }
 
 func getDefaultCertifiedImages(images string) (*ImageCatalog, error) {
@@ -92,3 +93,40 @@ func (c *ImageCatalog) utilsImage() *aimlv1beta1.ImageOverride {
 func (c *ImageCatalog) workerImage() *aimlv1beta1.ImageOverride {
 	return c.Worker
 }
+
+func (c *ImageCatalog) rcloneImage() *aimlv1beta1.ImageOverride {
+	return c.Rclone
+}
+
+// PostgresImage returns the certified postgresql image
+// shipped with the version of pachyderm requested
+func PostgresImage(pd *aimlv1beta1.Pachyderm) (*aimlv1beta1.ImageOverride, error) {
+	catalog, err := pachydermImagesCatalog(pd)
+	if err != nil {
+		return nil, err
+	}
+
+	return catalog.postgresqlImage(), nil
+}
+
+// EtcdImage returns the certified etcd image
+// shipped with the version of pachyderm requested
+func EtcdImage(pd *aimlv1beta1.Pachyderm) (*aimlv1beta1.ImageOverride, error) {
+	catalog, err := pachydermImagesCatalog(pd)
+	if err != nil {
+		return nil, err
+	}
+
+	return catalog.etcdImage(), nil
+}
+
+// RcloneImage returns the rclone image used to migrate
+// the objects of the version of pachyderm requested
+func RcloneImage(pd *aimlv1beta1.Pachyderm) (*aimlv1beta1.ImageOverride, error) {
+	catalog, err := pachydermImagesCatalog(pd)
+	if err != nil {
+		return nil, err
+	}
+
+	return catalog.rcloneImage(), nil
+}
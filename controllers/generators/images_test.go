@@ -0,0 +1,37 @@
+package generators
+
+import (
+	"path/filepath"
+	"testing"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+// TestImageCatalogs checks every chart shipped with the
+// operator lists the images deployed by the controllers
+func TestImageCatalogs(t *testing.T) {
+	catalogs, err := filepath.Glob("../../hack/charts/*/images.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalogs) == 0 {
+		t.Fatal("expected image catalogs")
+	}
+
+	for _, images := range catalogs {
+		catalog, err := getDefaultCertifiedImages(images)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, image := range map[string]*aimlv1beta1.ImageOverride{
+			"postgres": catalog.postgresqlImage(),
+			"etcd":     catalog.etcdImage(),
+			"rclone":   catalog.rcloneImage(),
+		} {
+			if image == nil || image.Repository == "" || image.Tag == "" {
+				t.Errorf("%s does not list the %s image", images, name)
+			}
+		}
+	}
+}
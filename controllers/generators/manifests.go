@@ -1,19 +1,76 @@
 package generators
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"path"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
 )
 
+const (
+	// annotations and labels used by the Azure workload identity webhook
+	azureClientIDAnnotation    string = "azure.workload.identity/client-id"
+	azureTenantIDAnnotation    string = "azure.workload.identity/tenant-id"
+	azureWorkloadIdentityLabel string = "azure.workload.identity/use"
+	// annotations used by the AWS pod identity webhook
+	awsRoleARNAnnotation           string = "eks.amazonaws.com/role-arn"
+	awsRegionalEndpointsAnnotation string = "eks.amazonaws.com/sts-regional-endpoints"
+	// projected service account token exchanged for AWS credentials
+	awsTokenVolumeName string = "aws-iam-token"
+	awsTokenMountPath  string = "/var/run/secrets/eks.amazonaws.com/serviceaccount"
+	awsTokenAudience   string = "sts.amazonaws.com"
+	// annotation used by GKE workload identity
+	gcpServiceAccountAnnotation string = "iam.gke.io/gcp-service-account"
+	// secret holding the certificate authorities trusted
+	// by pachd and workers when accessing object storage
+	storageCASecretName string = "pachyderm-storage-ca"
+	// path the storage certificate authorities are mounted at in pachd
+	storageCAMountPath string = "/pachyderm-storage-ca"
+	// directory of the system certificate authorities in pachd images
+	systemCertsDir string = "/etc/ssl/certs"
+	// role granting the pachd and worker service
+	// accounts use of the hostmount-anyuid SCC
+	localStorageRoleName string = "pachyderm-local-storage"
+	// secret holding the certificates used to
+	// connect to an external database over TLS
+	postgresTLSSecretName string = "pachyderm-postgres-tls"
+	// path the database certificates are mounted at
+	postgresTLSMountPath string = "/pachyderm-postgres-tls"
+	// PostgresClusterName is the name of the PostgresCluster of a highly available database.
+	// Matches the embedded database so clients use the same host
+	PostgresClusterName string = "postgres"
+	// PostgresClusterLabel is set by the postgres operator on the pods of a PostgresCluster
+	PostgresClusterLabel string = "postgres-operator.crunchydata.com/cluster"
+	postgresRoleLabel    string = "postgres-operator.crunchydata.com/role"
+	// PGBouncerName is the name of the pg-bouncer deployment and service
+	PGBouncerName string = "pg-bouncer"
+)
+
+// PostgresClusterGVK identifies the PostgresCluster
+// resource of the Crunchy Data postgres operator
+var PostgresClusterGVK = schema.GroupVersionKind{
+	Group:   "postgres-operator.crunchydata.com",
+	Version: "v1beta1",
+	Kind:    "PostgresCluster",
+}
+
 // PachydermCluster is a structure that contains
 // all the Kubernetes resources that make up a Pachyderm cluster
 type PachydermCluster struct {
@@ -190,6 +247,307 @@ func (c *PachydermCluster) EtcdStatefulSet() *appsv1.StatefulSet {
 	return etcd
 }
 
+// matches the initial cluster argument of the etcd container
+var etcdInitialClusterArg = regexp.MustCompile(`--initial-cluster=[^"]*`)
+
+// EtcdMemberName returns the name of the etcd
+// member running in the pod with the given ordinal
+func EtcdMemberName(ordinal int32) string {
+	return fmt.Sprintf("etcd-%d", ordinal)
+}
+
+// EtcdPeerURL returns the address other etcd members use
+// to reach the member running in the pod with the given ordinal
+func EtcdPeerURL(pd *aimlv1beta1.Pachyderm, ordinal int32) string {
+	return fmt.Sprintf("%s://%s.etcd-headless.%s.svc.cluster.local:2380", etcdScheme(pd), EtcdMemberName(ordinal), pd.Namespace)
+}
+
+// EtcdClientURL returns the address clients use to reach
+// the etcd member running in the pod with the given ordinal
+func EtcdClientURL(pd *aimlv1beta1.Pachyderm, ordinal int32) string {
+	return fmt.Sprintf("%s://%s.etcd-headless.%s.svc.cluster.local:2379", etcdScheme(pd), EtcdMemberName(ordinal), pd.Namespace)
+}
+
+func etcdScheme(pd *aimlv1beta1.Pachyderm) string {
+	if pd.Spec.Etcd.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+const (
+	// secrets holding the etcd certificates issued by the operator
+	EtcdServerSecretName string = "etcd-server-tls"
+	EtcdPeerSecretName   string = "etcd-peer-tls"
+	EtcdClientSecretName string = "etcd-client-tls"
+	// secret the OpenShift service CA writes the etcd serving certificate to
+	EtcdServingSecretName string = "etcd-serving-tls"
+	// EtcdCAHashAnnotation restarts the etcd and pachd pods
+	// when the trusted certificate authorities change
+	EtcdCAHashAnnotation             string = "operator.pachyderm.com/etcd-ca-hash"
+	serviceCAServingSecretAnnotation string = "service.beta.openshift.io/serving-cert-secret-name"
+	etcdTLSMountPath                 string = "/etc/etcd/tls"
+	// pachd does not support TLS connections to etcd and
+	// reaches it through a gRPC proxy in the same pod
+	etcdProxyName string = "etcd-proxy"
+	etcdProxyHost string = "127.0.0.1"
+	etcdProxyPort string = "2379"
+)
+
+// EtcdTLSSecrets returns the names of the secrets holding
+// the server, peer and client certificates of etcd. The
+// OpenShift service CA does not issue client certificates
+func EtcdTLSSecrets(pd *aimlv1beta1.Pachyderm) (server, peer, client string) {
+	tls := pd.Spec.Etcd.TLS
+	switch {
+	case tls == nil:
+		return "", "", ""
+	case tls.Source == aimlv1beta1.EtcdTLSSecretSource:
+		return tls.ServerSecretName, tls.PeerSecretName, tls.ClientSecretName
+	case tls.Source == aimlv1beta1.EtcdTLSServiceCASource:
+		return EtcdServingSecretName, EtcdServingSecretName, ""
+	}
+	return EtcdServerSecretName, EtcdPeerSecretName, EtcdClientSecretName
+}
+
+// EtcdCAHash returns a digest of the certificate
+// authorities trusted by etcd and its clients
+func EtcdCAHash(pd *aimlv1beta1.Pachyderm) string {
+	if pd.Spec.Etcd.TLS == nil {
+		return ""
+	}
+	digest := sha256.Sum256(pd.Spec.Etcd.TLS.CABundleData)
+	return hex.EncodeToString(digest[:])
+}
+
+// setupEtcdTLS serves the etcd client and peer traffic over TLS
+// and connects pachd to etcd through a proxy holding the client
+// certificate. With the OpenShift service CA, the serving
+// certificate is requested on the headless service of etcd
+func setupEtcdTLS(pd *aimlv1beta1.Pachyderm, cluster *PachydermCluster) {
+	server, peer, _ := EtcdTLSSecrets(pd)
+	serviceCA := pd.Spec.Etcd.TLS.Source == aimlv1beta1.EtcdTLSServiceCASource
+
+	if serviceCA {
+		for _, svc := range cluster.Services {
+			if svc.Name == "etcd-headless" {
+				if svc.Annotations == nil {
+					svc.Annotations = map[string]string{}
+				}
+				svc.Annotations[serviceCAServingSecretAnnotation] = EtcdServingSecretName
+			}
+		}
+	}
+
+	etcd := cluster.etcdStatefulSet
+	if etcd == nil {
+		return
+	}
+
+	volumes, mounts := etcdTLSVolumes(pd, server, peer)
+	clientAuth := strconv.FormatBool(!serviceCA)
+	env := []corev1.EnvVar{
+		{Name: "ETCD_CERT_FILE", Value: path.Join(etcdTLSMountPath, server, corev1.TLSCertKey)},
+		{Name: "ETCD_KEY_FILE", Value: path.Join(etcdTLSMountPath, server, corev1.TLSPrivateKeyKey)},
+		{Name: "ETCD_TRUSTED_CA_FILE", Value: etcdCAFile(pd, server)},
+		{Name: "ETCD_CLIENT_CERT_AUTH", Value: clientAuth},
+		{Name: "ETCD_PEER_CERT_FILE", Value: path.Join(etcdTLSMountPath, peer, corev1.TLSCertKey)},
+		{Name: "ETCD_PEER_KEY_FILE", Value: path.Join(etcdTLSMountPath, peer, corev1.TLSPrivateKeyKey)},
+		{Name: "ETCD_PEER_TRUSTED_CA_FILE", Value: etcdCAFile(pd, peer)},
+		{Name: "ETCD_PEER_CLIENT_CERT_AUTH", Value: clientAuth},
+	}
+
+	for i, container := range etcd.Spec.Template.Spec.Containers {
+		if container.Name != "etcd" {
+			continue
+		}
+		for j, arg := range container.Args {
+			container.Args[j] = strings.ReplaceAll(arg, "http://", "https://")
+		}
+		for _, variable := range env {
+			container.Env = setEnv(container.Env, variable.Name, variable.Value)
+		}
+		container.VolumeMounts = append(container.VolumeMounts, mounts...)
+		etcd.Spec.Template.Spec.Containers[i] = container
+	}
+	etcd.Spec.Template.Spec.Volumes = append(etcd.Spec.Template.Spec.Volumes, volumes...)
+
+	if etcd.Spec.Template.Annotations == nil {
+		etcd.Spec.Template.Annotations = map[string]string{}
+	}
+	etcd.Spec.Template.Annotations[EtcdCAHashAnnotation] = EtcdCAHash(pd)
+}
+
+// setupEtcdProxy adds a gRPC proxy connecting to etcd over
+// TLS to the pachd pod and points pachd to the proxy
+func setupEtcdProxy(pd *aimlv1beta1.Pachyderm, pachd *appsv1.Deployment) {
+	_, _, client := EtcdTLSSecrets(pd)
+	catalog, _ := pachydermImagesCatalog(pd)
+	etcdImage := catalog.etcdImage()
+
+	endpoints := []string{}
+	for i := int32(0); i < pd.EtcdNodes(); i++ {
+		endpoints = append(endpoints, EtcdClientURL(pd, i))
+	}
+	args := append([]string{
+		"grpc-proxy",
+		"start",
+		"--endpoints=" + strings.Join(endpoints, ","),
+		"--listen-addr=" + etcdProxyHost + ":" + etcdProxyPort,
+	}, etcdClientTLSFlags(pd)...)
+
+	volumes, mounts := etcdTLSVolumes(pd, client)
+	pachd.Spec.Template.Spec.Containers = append(pachd.Spec.Template.Spec.Containers, corev1.Container{
+		Name:            etcdProxyName,
+		Image:           etcdImage.Name(),
+		ImagePullPolicy: etcdImage.ImagePullPolicy(),
+		Command:         []string{"/usr/local/bin/etcd"},
+		Args:            args,
+		VolumeMounts:    mounts,
+	})
+	pachd.Spec.Template.Spec.Volumes = append(pachd.Spec.Template.Spec.Volumes, volumes...)
+
+	// the proxy reads the certificate authorities on startup
+	if pachd.Spec.Template.Annotations == nil {
+		pachd.Spec.Template.Annotations = map[string]string{}
+	}
+	pachd.Spec.Template.Annotations[EtcdCAHashAnnotation] = EtcdCAHash(pd)
+
+	for i, container := range pachd.Spec.Template.Spec.Containers {
+		if container.Name == "pachd" {
+			env := setEnv(container.Env, "ETCD_SERVICE_HOST", etcdProxyHost)
+			pachd.Spec.Template.Spec.Containers[i].Env = setEnv(env, "ETCD_SERVICE_PORT", etcdProxyPort)
+		}
+	}
+
+	// waits for etcd over plain HTTP
+	initContainers := []corev1.Container{}
+	for _, container := range pachd.Spec.Template.Spec.InitContainers {
+		if container.Name != "init-etcd" {
+			initContainers = append(initContainers, container)
+		}
+	}
+	pachd.Spec.Template.Spec.InitContainers = initContainers
+}
+
+// EtcdClientOptions returns the etcdctl flags connecting to a
+// single etcd member and the volumes holding the client certificate
+func EtcdClientOptions(pd *aimlv1beta1.Pachyderm) ([]string, []corev1.Volume, []corev1.VolumeMount) {
+	if pd.Spec.Etcd.TLS == nil {
+		return []string{"--endpoints=http://etcd:2379"}, nil, nil
+	}
+
+	_, _, client := EtcdTLSSecrets(pd)
+	volumes, mounts := etcdTLSVolumes(pd, client)
+	flags := append([]string{"--endpoints=" + EtcdClientURL(pd, 0)}, etcdClientTLSFlags(pd)...)
+	return flags, volumes, mounts
+}
+
+// etcdClientTLSFlags returns the flags of the etcd proxy and etcdctl
+// trusting the etcd certificate authorities and presenting the client
+// certificate
+func etcdClientTLSFlags(pd *aimlv1beta1.Pachyderm) []string {
+	_, _, client := EtcdTLSSecrets(pd)
+	flags := []string{"--cacert=" + etcdCAFile(pd, client)}
+	if client != "" {
+		flags = append(flags,
+			"--cert="+path.Join(etcdTLSMountPath, client, corev1.TLSCertKey),
+			"--key="+path.Join(etcdTLSMountPath, client, corev1.TLSPrivateKeyKey),
+		)
+	}
+	return flags
+}
+
+// etcdTLSVolumes returns the volumes and mounts of the given etcd
+// certificate secrets and of the service CA bundle when it is used
+func etcdTLSVolumes(pd *aimlv1beta1.Pachyderm, secrets ...string) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{}
+	mounts := []corev1.VolumeMount{}
+
+	names := []string{}
+	for _, name := range secrets {
+		if name == "" || containsString(names, name) {
+			continue
+		}
+		names = append(names, name)
+		volumes = append(volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: name,
+				},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: path.Join(etcdTLSMountPath, name),
+			ReadOnly:  true,
+		})
+	}
+
+	if pd.Spec.Etcd.TLS.Source == aimlv1beta1.EtcdTLSServiceCASource {
+		volumes = append(volumes, corev1.Volume{
+			Name: aimlv1beta1.EtcdServiceCABundleName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: aimlv1beta1.EtcdServiceCABundleName,
+					},
+				},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      aimlv1beta1.EtcdServiceCABundleName,
+			MountPath: path.Join(etcdTLSMountPath, aimlv1beta1.EtcdServiceCABundleName),
+			ReadOnly:  true,
+		})
+	}
+
+	return volumes, mounts
+}
+
+// etcdCAFile returns the path of the certificate
+// authorities trusted by the holder of a certificate
+func etcdCAFile(pd *aimlv1beta1.Pachyderm, secret string) string {
+	if pd.Spec.Etcd.TLS.Source == aimlv1beta1.EtcdTLSServiceCASource {
+		return path.Join(etcdTLSMountPath, aimlv1beta1.EtcdServiceCABundleName, "service-ca.crt")
+	}
+	return path.Join(etcdTLSMountPath, secret, "ca.crt")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetEtcdMembers sets the number of replicas of the etcd statefulset
+// and the initial cluster its pods are started with. Pods created
+// without data join the running cluster instead of bootstrapping one
+func SetEtcdMembers(pd *aimlv1beta1.Pachyderm, etcd *appsv1.StatefulSet, members int32) {
+	etcd.Spec.Replicas = &members
+
+	peers := make([]string, 0, members)
+	for i := int32(0); i < members; i++ {
+		peers = append(peers, fmt.Sprintf("%s=%s", EtcdMemberName(i), EtcdPeerURL(pd, i)))
+	}
+	initialCluster := "--initial-cluster=" + strings.Join(peers, ",")
+
+	for i, container := range etcd.Spec.Template.Spec.Containers {
+		if container.Name != "etcd" {
+			continue
+		}
+		for j, arg := range container.Args {
+			container.Args[j] = etcdInitialClusterArg.ReplaceAllLiteralString(arg, initialCluster)
+		}
+		// read by etcd when the flag is not set
+		etcd.Spec.Template.Spec.Containers[i].Env = setEnv(container.Env, "ETCD_INITIAL_CLUSTER_STATE", "existing")
+	}
+}
+
 // PostgreStatefulset returns the postgresql statefulset resource
 func (c *PachydermCluster) PostgreStatefulset() *appsv1.StatefulSet {
 	pg := c.postgreStatefulSet
@@ -266,6 +624,113 @@ func (c *PachydermCluster) PostgreStatefulset() *appsv1.StatefulSet {
 	return pg
 }
 
+// PostgresCluster returns the PostgresCluster managed by the
+// Crunchy Data postgres operator when the embedded database is
+// highly available. The operator creates the postgres-primary and
+// postgres-replicas services and promotes a replica on failure
+func (c *PachydermCluster) PostgresCluster() *unstructured.Unstructured {
+	pd := c.Pachyderm()
+	ha := pd.Spec.Postgres.HighAvailability
+
+	dataVolume := map[string]interface{}{
+		"accessModes": []interface{}{"ReadWriteOnce"},
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{
+				"storage": pd.PostgresStorageSize(),
+			},
+		},
+	}
+	if pd.Spec.Postgres.StorageClass != "" {
+		dataVolume["storageClassName"] = pd.Spec.Postgres.StorageClass
+	}
+
+	instance := map[string]interface{}{
+		"name":                "instance1",
+		"replicas":            int64(ha.Replicas),
+		"dataVolumeClaimSpec": dataVolume,
+		// spread the instances across nodes
+		"affinity": map[string]interface{}{
+			"podAntiAffinity": map[string]interface{}{
+				"preferredDuringSchedulingIgnoredDuringExecution": []interface{}{
+					map[string]interface{}{
+						"weight": int64(1),
+						"podAffinityTerm": map[string]interface{}{
+							"topologyKey": "kubernetes.io/hostname",
+							"labelSelector": map[string]interface{}{
+								"matchLabels": map[string]interface{}{
+									PostgresClusterLabel: PostgresClusterName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if pd.Spec.Postgres.Resources != nil {
+		resources, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pd.Spec.Postgres.Resources)
+		if err == nil {
+			instance["resources"] = resources
+		}
+	}
+
+	cluster := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"postgresVersion": int64(ha.PostgresVersion),
+				"instances":       []interface{}{instance},
+				"users": []interface{}{
+					map[string]interface{}{
+						"name": pd.Spec.Pachd.Postgres.User,
+						"databases": []interface{}{
+							pd.Spec.Pachd.Postgres.Database,
+						},
+					},
+					map[string]interface{}{
+						"name": "postgres",
+					},
+				},
+				"backups": map[string]interface{}{
+					"pgbackrest": map[string]interface{}{
+						"repos": []interface{}{
+							map[string]interface{}{
+								"name": "repo1",
+								"volume": map[string]interface{}{
+									"volumeClaimSpec": dataVolume,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	cluster.SetGroupVersionKind(PostgresClusterGVK)
+	cluster.SetName(PostgresClusterName)
+	cluster.SetNamespace(pd.Namespace)
+	cluster.SetLabels(map[string]string{
+		"app":   "postgres",
+		"suite": "pachyderm",
+	})
+
+	return cluster
+}
+
+// PostgresUserSecretName returns the name of the secret the
+// postgres operator reads the password of a database user from
+func PostgresUserSecretName(user string) string {
+	return fmt.Sprintf("%s-pguser-%s", PostgresClusterName, user)
+}
+
+// PostgresPrimarySelector returns the labels of
+// the primary instance of the PostgresCluster
+func PostgresPrimarySelector() map[string]string {
+	return map[string]string{
+		PostgresClusterLabel: PostgresClusterName,
+		postgresRoleLabel:    "master",
+	}
+}
+
 // PrepareCluster takes a pachyderm custom resource and returns
 // child resources based on the pachyderm custom resource
 // TODO: decode any input here
@@ -275,8 +740,12 @@ func PrepareCluster(pd *aimlv1beta1.Pachyderm) (*PachydermCluster, error) {
 		return nil, err
 	}
 
+	if pd.Spec.PGBouncer.Disable {
+		removePGBouncer(cluster)
+	}
+
 	for _, deployment := range cluster.deployments {
-		if deployment.Name == "pg-bouncer" {
+		if deployment.Name == PGBouncerName {
 			setupPGBouncer(pd, deployment)
 		}
 		if deployment.Name == "pachd" {
@@ -284,9 +753,248 @@ func PrepareCluster(pd *aimlv1beta1.Pachyderm) (*PachydermCluster, error) {
 		}
 	}
 
+	for _, sa := range cluster.ServiceAccounts {
+		if sa.Name == "pachyderm" || sa.Name == "pachyderm-worker" {
+			setupStorageIdentity(pd, sa)
+		}
+	}
+
+	if pd.Spec.Pachd.Storage.Backend == aimlv1beta1.LocalStorageBackend {
+		setupLocalStorage(pd, cluster)
+	}
+
+	if pd.Spec.Etcd.TLS != nil {
+		setupEtcdTLS(pd, cluster)
+	}
+
+	// the OpenShift service CA issues the certificate served by pachd
+	if tls := pd.Spec.Pachd.TLS; tls != nil && tls.Source != aimlv1beta1.PachdTLSSecretSource {
+		for _, svc := range cluster.Services {
+			if svc.Name == "pachd" {
+				if svc.Annotations == nil {
+					svc.Annotations = map[string]string{}
+				}
+				svc.Annotations[serviceCAServingSecretAnnotation] = aimlv1beta1.PachdServingSecretName
+			}
+		}
+	}
+
+	// route the postgres service to the primary instance
+	if pd.IsPostgresHA() {
+		for _, svc := range cluster.Services {
+			if svc.Name == "postgres" {
+				svc.Spec.Selector = PostgresPrimarySelector()
+			}
+		}
+	}
+
+	if certificates := postgresTLSData(pd); certificates != nil {
+		cluster.secrets = append(cluster.secrets, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      postgresTLSSecretName,
+				Namespace: pd.Namespace,
+				Labels: map[string]string{
+					"app":   "pachd",
+					"suite": "pachyderm",
+				},
+			},
+			Data: certificates,
+		})
+	}
+
+	if bundle := storageCABundle(pd); bundle != nil {
+		cluster.secrets = append(cluster.secrets, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      storageCASecretName,
+				Namespace: pd.Namespace,
+				Labels: map[string]string{
+					"app":   "pachd",
+					"suite": "pachyderm",
+				},
+			},
+			Data: map[string][]byte{
+				"ca.crt": bundle,
+			},
+		})
+	}
+
 	return cluster, nil
 }
 
+// removePGBouncer drops the pg-bouncer deployment
+// and service when pachd connects to the database directly
+func removePGBouncer(cluster *PachydermCluster) {
+	deployments := []*appsv1.Deployment{}
+	for _, deployment := range cluster.deployments {
+		if deployment.Name != PGBouncerName {
+			deployments = append(deployments, deployment)
+		}
+	}
+	cluster.deployments = deployments
+
+	services := []*corev1.Service{}
+	for _, svc := range cluster.Services {
+		if svc.Name != PGBouncerName {
+			services = append(services, svc)
+		}
+	}
+	cluster.Services = services
+}
+
+// storageCABundle returns the certificate authorities
+// trusted when connecting to the object store
+func storageCABundle(pd *aimlv1beta1.Pachyderm) []byte {
+	if pd.Spec.Pachd.Storage.Backend == aimlv1beta1.MinioStorageBackend &&
+		pd.Spec.Pachd.Storage.Minio != nil &&
+		len(pd.Spec.Pachd.Storage.Minio.CABundleData) > 0 {
+		return pd.Spec.Pachd.Storage.Minio.CABundleData
+	}
+	if pd.Spec.Pachd.Storage.Backend == aimlv1beta1.CustomStorageBackend &&
+		pd.Spec.Pachd.Storage.Custom != nil &&
+		len(pd.Spec.Pachd.Storage.Custom.CABundleData) > 0 {
+		return pd.Spec.Pachd.Storage.Custom.CABundleData
+	}
+	return nil
+}
+
+// postgresTLSData returns the certificates used
+// to connect to an external database over TLS
+func postgresTLSData(pd *aimlv1beta1.Pachyderm) map[string][]byte {
+	postgres := pd.Spec.Pachd.Postgres
+	if len(postgres.CABundleData) == 0 && len(postgres.ClientCertificate) == 0 {
+		return nil
+	}
+
+	data := map[string][]byte{}
+	if len(postgres.CABundleData) > 0 {
+		data["ca.crt"] = postgres.CABundleData
+	}
+	if len(postgres.ClientCertificate) > 0 {
+		data[corev1.TLSCertKey] = postgres.ClientCertificate
+		data[corev1.TLSPrivateKeyKey] = postgres.ClientKey
+	}
+
+	return data
+}
+
+// postgresTLSEnv sets the environment variables named root, cert
+// and key to the paths of the mounted database certificates
+func postgresTLSEnv(pd *aimlv1beta1.Pachyderm, root, cert, key string) []corev1.EnvVar {
+	certificates := postgresTLSData(pd)
+	env := []corev1.EnvVar{}
+	if _, ok := certificates["ca.crt"]; ok {
+		env = append(env, corev1.EnvVar{
+			Name:  root,
+			Value: path.Join(postgresTLSMountPath, "ca.crt"),
+		})
+	}
+	if _, ok := certificates[corev1.TLSCertKey]; ok {
+		env = append(env,
+			corev1.EnvVar{
+				Name:  cert,
+				Value: path.Join(postgresTLSMountPath, corev1.TLSCertKey),
+			},
+			corev1.EnvVar{
+				Name:  key,
+				Value: path.Join(postgresTLSMountPath, corev1.TLSPrivateKeyKey),
+			},
+		)
+	}
+	return env
+}
+
+// postgresTLSVolume returns the volume holding the database certificates
+func postgresTLSVolume() corev1.Volume {
+	return corev1.Volume{
+		Name: postgresTLSSecretName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: postgresTLSSecretName,
+			},
+		},
+	}
+}
+
+// setupLocalStorage allows the pachd and worker service accounts
+// to mount the host path used to store objects on the node
+func setupLocalStorage(pd *aimlv1beta1.Pachyderm, cluster *PachydermCluster) {
+	labels := map[string]string{
+		"app":   "pachd",
+		"suite": "pachyderm",
+	}
+
+	cluster.Roles = append(cluster.Roles, &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      localStorageRoleName,
+			Namespace: pd.Namespace,
+			Labels:    labels,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{"security.openshift.io"},
+				Resources:     []string{"securitycontextconstraints"},
+				ResourceNames: []string{"hostmount-anyuid"},
+				Verbs:         []string{"use"},
+			},
+		},
+	})
+
+	cluster.RoleBindings = append(cluster.RoleBindings, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      localStorageRoleName,
+			Namespace: pd.Namespace,
+			Labels:    labels,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     localStorageRoleName,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      "pachyderm",
+				Namespace: pd.Namespace,
+			},
+			{
+				Kind:      "ServiceAccount",
+				Name:      "pachyderm-worker",
+				Namespace: pd.Namespace,
+			},
+		},
+	})
+}
+
+// setupStorageIdentity annotates the pachd and worker service
+// accounts with the cloud identity used to access object storage
+func setupStorageIdentity(pd *aimlv1beta1.Pachyderm, sa *corev1.ServiceAccount) {
+	if sa.Annotations == nil {
+		sa.Annotations = map[string]string{}
+	}
+
+	if pd.IsUsingAmazonIAMRole() {
+		sa.Annotations[awsRoleARNAnnotation] = pd.Spec.Pachd.Storage.Amazon.IAMRole
+		sa.Annotations[awsRegionalEndpointsAnnotation] = "true"
+	}
+
+	if pd.IsUsingGCSWorkloadIdentity() {
+		sa.Annotations[gcpServiceAccountAnnotation] = pd.Spec.Pachd.Storage.Google.ServiceAccountName
+	}
+
+	if pd.IsUsingAzureWorkloadIdentity() {
+		identity := pd.Spec.Pachd.Storage.Microsoft.WorkloadIdentity
+		sa.Annotations[azureClientIDAnnotation] = identity.ClientID
+		if identity.TenantID != "" {
+			sa.Annotations[azureTenantIDAnnotation] = identity.TenantID
+		}
+
+		if sa.Labels == nil {
+			sa.Labels = map[string]string{}
+		}
+		sa.Labels[azureWorkloadIdentityLabel] = "true"
+	}
+}
+
 // Deployments returns slice of deployments generated by the helm template command
 func (c *PachydermCluster) Deployments() []*appsv1.Deployment {
 	return c.deployments
@@ -307,6 +1015,32 @@ func setupPGBouncer(pd *aimlv1beta1.Pachyderm, bouncer *appsv1.Deployment) {
 					MountPath: "/pgconf",
 				},
 			}
+			// pgbouncer connects to an external or highly available database over TLS
+			sslMode := pd.Spec.PGBouncer.ServerSSLMode
+			if sslMode == "" && pd.RequiresPostgresTLS() {
+				sslMode = pd.Spec.Pachd.Postgres.SSL
+			}
+			if sslMode != "" {
+				container.Env = setEnv(container.Env, "PGBOUNCER_SERVER_TLS_SSLMODE", sslMode)
+			}
+			for _, setting := range pgBouncerPoolSettings(pd) {
+				container.Env = setEnv(container.Env, setting.Name, setting.Value)
+			}
+			if pd.Spec.PGBouncer.Resources != nil {
+				container.Resources = *pd.Spec.PGBouncer.Resources
+			}
+			if postgresTLSData(pd) != nil {
+				container.Env = append(container.Env, postgresTLSEnv(pd,
+					"PGBOUNCER_SERVER_TLS_CA_FILE",
+					"PGBOUNCER_SERVER_TLS_CERT_FILE",
+					"PGBOUNCER_SERVER_TLS_KEY_FILE",
+				)...)
+				container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+					Name:      postgresTLSSecretName,
+					MountPath: postgresTLSMountPath,
+					ReadOnly:  true,
+				})
+			}
 			bouncer.Spec.Template.Spec.Containers[i] = container
 		}
 	}
@@ -322,6 +1056,75 @@ func setupPGBouncer(pd *aimlv1beta1.Pachyderm, bouncer *appsv1.Deployment) {
 			},
 		},
 	)
+	if postgresTLSData(pd) != nil {
+		bouncer.Spec.Template.Spec.Volumes = append(bouncer.Spec.Template.Spec.Volumes, postgresTLSVolume())
+	}
+
+	if replicas := pd.Spec.PGBouncer.Replicas; replicas > 0 {
+		bouncer.Spec.Replicas = &replicas
+	}
+
+	// restart pgbouncer when the database credentials or pool settings change
+	if bouncer.Spec.Template.Annotations == nil {
+		bouncer.Spec.Template.Annotations = map[string]string{}
+	}
+	bouncer.Spec.Template.Annotations[aimlv1beta1.PachdConfigHashAnnotation] = pgBouncerConfigHash(pd)
+}
+
+// pgBouncerPoolSettings returns the environment variables
+// read by the pg-bouncer image for the pool settings that are set
+func pgBouncerPoolSettings(pd *aimlv1beta1.Pachyderm) []corev1.EnvVar {
+	options := pd.Spec.PGBouncer
+	settings := []corev1.EnvVar{}
+
+	if options.PoolMode != "" {
+		settings = append(settings, corev1.EnvVar{Name: "PGBOUNCER_POOL_MODE", Value: options.PoolMode})
+	}
+	sizes := []struct {
+		name string
+		size int32
+	}{
+		{"PGBOUNCER_MAX_CLIENT_CONN", options.MaxClientConnections},
+		{"PGBOUNCER_DEFAULT_POOL_SIZE", options.DefaultPoolSize},
+		{"PGBOUNCER_MIN_POOL_SIZE", options.MinPoolSize},
+		{"PGBOUNCER_RESERVE_POOL_SIZE", options.ReservePoolSize},
+		{"PGBOUNCER_MAX_DB_CONNECTIONS", options.MaxDBConnections},
+	}
+	for _, setting := range sizes {
+		if setting.size > 0 {
+			settings = append(settings, corev1.EnvVar{
+				Name:  setting.name,
+				Value: strconv.Itoa(int(setting.size)),
+			})
+		}
+	}
+
+	return settings
+}
+
+// pgBouncerConfigHash adds the pool settings to the config
+// hash so changing them only restarts pg-bouncer
+func pgBouncerConfigHash(pd *aimlv1beta1.Pachyderm) string {
+	options, _ := json.Marshal(pd.Spec.PGBouncer)
+
+	hash := sha256.New()
+	hash.Write([]byte(ConfigHash(pd)))
+	hash.Write([]byte{0})
+	hash.Write(options)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// setEnv sets the value of the environment
+// variable name, appending it when missing
+func setEnv(env []corev1.EnvVar, name, value string) []corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == name {
+			env[i] = corev1.EnvVar{Name: name, Value: value}
+			return env
+		}
+	}
+	return append(env, corev1.EnvVar{Name: name, Value: value})
 }
 
 func setupPachd(pd *aimlv1beta1.Pachyderm, pachd *appsv1.Deployment) {
@@ -352,8 +1155,17 @@ func setupPachd(pd *aimlv1beta1.Pachyderm, pachd *appsv1.Deployment) {
 				if environment.Name == "POSTGRES_HOST" {
 					environment.Value = pd.Spec.Pachd.Postgres.Host
 				}
+				// connect to the database directly without pg-bouncer
+				if pd.Spec.PGBouncer.Disable {
+					if environment.Name == "PG_BOUNCER_HOST" {
+						environment.Value = pd.Spec.Pachd.Postgres.Host
+					}
+					if environment.Name == "PG_BOUNCER_PORT" {
+						environment.Value = strconv.Itoa(int(pd.Spec.Pachd.Postgres.Port))
+					}
+				}
 				if environment.Name == "POSTGRES_USER" {
-					environment.Value = pd.Spec.Pachd.Postgres.User
+					environment.Value = pd.PostgresLoginUser()
 				}
 				if environment.Name == "POSTGRES_DATABASE" {
 					environment.Value = pd.Spec.Pachd.Postgres.Database
@@ -366,9 +1178,204 @@ func setupPachd(pd *aimlv1beta1.Pachyderm, pachd *appsv1.Deployment) {
 				}
 				env = append(env, environment)
 			}
+			if pd.IsUsingAmazonIAMRole() {
+				env = append(env,
+					corev1.EnvVar{
+						Name:  "AWS_ROLE_ARN",
+						Value: pd.Spec.Pachd.Storage.Amazon.IAMRole,
+					},
+					corev1.EnvVar{
+						Name:  "AWS_WEB_IDENTITY_TOKEN_FILE",
+						Value: path.Join(awsTokenMountPath, "token"),
+					},
+				)
+				pachd.Spec.Template.Spec.Containers[i].VolumeMounts = append(
+					pachd.Spec.Template.Spec.Containers[i].VolumeMounts,
+					corev1.VolumeMount{
+						Name:      awsTokenVolumeName,
+						MountPath: awsTokenMountPath,
+						ReadOnly:  true,
+					},
+				)
+			}
+			// read by the postgres driver of pachd
+			if postgresTLSData(pd) != nil {
+				env = append(env, postgresTLSEnv(pd, "PGSSLROOTCERT", "PGSSLCERT", "PGSSLKEY")...)
+				pachd.Spec.Template.Spec.Containers[i].VolumeMounts = append(
+					pachd.Spec.Template.Spec.Containers[i].VolumeMounts,
+					corev1.VolumeMount{
+						Name:      postgresTLSSecretName,
+						MountPath: postgresTLSMountPath,
+						ReadOnly:  true,
+					},
+				)
+			}
+			if storageCABundle(pd) != nil {
+				// go reads every directory in SSL_CERT_DIR,
+				// so the system roots remain trusted
+				env = setEnv(env, "SSL_CERT_DIR", strings.Join([]string{systemCertsDir, storageCAMountPath}, ":"))
+				// pachd mounts the secret at /pachd-tls-cert in worker
+				// pods and points their SSL_CERT_DIR at it
+				env = setEnv(env, "TLS_CERT_SECRET_NAME", storageCASecretName)
+				pachd.Spec.Template.Spec.Containers[i].VolumeMounts = append(
+					pachd.Spec.Template.Spec.Containers[i].VolumeMounts,
+					corev1.VolumeMount{
+						Name:      storageCASecretName,
+						MountPath: storageCAMountPath,
+						ReadOnly:  true,
+					},
+				)
+			}
 			pachd.Spec.Template.Spec.Containers[i].Env = env
 			pachd.Spec.Template.Spec.Containers[i].Image = pachdImage.Name()
 			pachd.Spec.Template.Spec.Containers[i].ImagePullPolicy = pachdImage.ImagePullPolicy()
 		}
 	}
+
+	if pd.IsUsingAmazonIAMRole() {
+		expiration := int64(86400)
+		pachd.Spec.Template.Spec.Volumes = append(pachd.Spec.Template.Spec.Volumes,
+			corev1.Volume{
+				Name: awsTokenVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{
+							{
+								ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+									Audience:          awsTokenAudience,
+									ExpirationSeconds: &expiration,
+									Path:              "token",
+								},
+							},
+						},
+					},
+				},
+			},
+		)
+	}
+
+	if postgresTLSData(pd) != nil {
+		pachd.Spec.Template.Spec.Volumes = append(pachd.Spec.Template.Spec.Volumes, postgresTLSVolume())
+	}
+
+	if storageCABundle(pd) != nil {
+		pachd.Spec.Template.Spec.Volumes = append(pachd.Spec.Template.Spec.Volumes,
+			corev1.Volume{
+				Name: storageCASecretName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: storageCASecretName,
+					},
+				},
+			},
+		)
+	}
+
+	// store objects on a persistent volume claim instead of the node
+	if pd.Spec.Pachd.Storage.Backend == aimlv1beta1.LocalStorageBackend &&
+		pd.Spec.Pachd.Storage.Local != nil &&
+		pd.Spec.Pachd.Storage.Local.ClaimName != "" {
+		for i, volume := range pachd.Spec.Template.Spec.Volumes {
+			if volume.Name == "pach-disk" {
+				pachd.Spec.Template.Spec.Volumes[i].VolumeSource = corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: pd.Spec.Pachd.Storage.Local.ClaimName,
+					},
+				}
+			}
+		}
+	}
+
+	if pd.Spec.Etcd.TLS != nil {
+		setupEtcdProxy(pd, pachd)
+	}
+
+	// restart pachd when the credentials it consumes change
+	if pachd.Spec.Template.Annotations == nil {
+		pachd.Spec.Template.Annotations = map[string]string{}
+	}
+	pachd.Spec.Template.Annotations[aimlv1beta1.PachdConfigHashAnnotation] = ConfigHash(pd)
+
+	// the workload identity webhook injects the
+	// federated token into pods carrying this label
+	if pd.IsUsingAzureWorkloadIdentity() {
+		if pachd.Spec.Template.Labels == nil {
+			pachd.Spec.Template.Labels = map[string]string{}
+		}
+		pachd.Spec.Template.Labels[azureWorkloadIdentityLabel] = "true"
+	}
+}
+
+// ConfigHash returns a digest of the storage backend and the
+// credentials resolved from the secrets referenced by the resource,
+// including the time the database password was last rotated
+func ConfigHash(pd *aimlv1beta1.Pachyderm) string {
+	storage := pd.Spec.Pachd.Storage
+	values := []string{
+		pd.Spec.EnterpriseLicense,
+		pd.Spec.Pachd.Postgres.Password,
+		string(pd.Spec.Pachd.Postgres.CABundleData),
+		string(pd.Spec.Pachd.Postgres.ClientCertificate),
+		string(pd.Spec.Pachd.Postgres.ClientKey),
+		storage.Backend,
+		strconv.FormatBool(pd.Spec.PGBouncer.Disable),
+	}
+	if rotatedAt := pd.Status.PostgresPasswordRotatedAt; rotatedAt != nil {
+		values = append(values, rotatedAt.UTC().Format(time.RFC3339))
+	}
+	// the etcd proxy connects to every member
+	if pd.Spec.Etcd.TLS != nil {
+		values = append(values, EtcdCAHash(pd), strconv.Itoa(int(pd.EtcdNodes())))
+	}
+
+	if storage.Amazon != nil {
+		values = append(values,
+			storage.Amazon.ID,
+			storage.Amazon.Secret,
+			storage.Amazon.Token,
+			storage.Amazon.Bucket,
+			storage.Amazon.Region,
+			storage.Amazon.CustomEndpoint,
+		)
+	}
+	if storage.Google != nil {
+		values = append(values, storage.Google.Bucket, string(storage.Google.CredentialsData))
+	}
+	if storage.Microsoft != nil {
+		values = append(values,
+			storage.Microsoft.Container,
+			storage.Microsoft.ID,
+			storage.Microsoft.Secret,
+		)
+	}
+	if storage.Minio != nil {
+		values = append(values,
+			storage.Minio.Bucket,
+			storage.Minio.Endpoint,
+			storage.Minio.ID,
+			storage.Minio.Secret,
+			string(storage.Minio.CABundleData),
+		)
+	}
+
+	if storage.Local != nil {
+		values = append(values, storage.Local.HostPath, storage.Local.ClaimName)
+	}
+	if storage.Custom != nil {
+		values = append(values,
+			storage.Custom.Bucket,
+			storage.Custom.Endpoint,
+			storage.Custom.ID,
+			storage.Custom.Secret,
+			string(storage.Custom.CABundleData),
+		)
+	}
+
+	hash := sha256.New()
+	for _, value := range values {
+		hash.Write([]byte(value))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
 }
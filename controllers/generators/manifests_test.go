@@ -0,0 +1,231 @@
+package generators
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+// newPachyderm returns a pachyderm resource with the defaults
+// of the CRD schema and the webhook after applying the mutate function
+func newPachyderm(mutate func(pd *aimlv1beta1.Pachyderm)) *aimlv1beta1.Pachyderm {
+	pd := newUndefaultedPachyderm()
+	if mutate != nil {
+		mutate(pd)
+	}
+	pd.Default()
+	return pd
+}
+
+// newUndefaultedPachyderm returns a pachyderm resource with only the
+// defaults the CRD schema applies when spec.etcd and spec.postgresql
+// are omitted, as stored when the webhook is disabled
+func newUndefaultedPachyderm() *aimlv1beta1.Pachyderm {
+	return &aimlv1beta1.Pachyderm{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pachyderm",
+			Namespace: "default",
+		},
+		Spec: aimlv1beta1.PachydermSpec{
+			Version: "v2.1.6",
+			Pachd: aimlv1beta1.PachdOptions{
+				Postgres: aimlv1beta1.PachdPostgresConfig{
+					Host:     "postgres",
+					Port:     5432,
+					SSL:      "disable",
+					User:     "pachyderm",
+					Database: "pachyderm",
+				},
+				Storage: aimlv1beta1.ObjectStorageOptions{
+					Backend: aimlv1beta1.LocalStorageBackend,
+				},
+			},
+		},
+	}
+}
+
+func pachdDeployment(t *testing.T, cluster *PachydermCluster) *appsv1.Deployment {
+	t.Helper()
+
+	for _, deployment := range cluster.Deployments() {
+		if deployment.Name == "pachd" {
+			return deployment
+		}
+	}
+	t.Fatal("pachd deployment not found")
+	return nil
+}
+
+func TestStorageCAMount(t *testing.T) {
+	tests := []struct {
+		name     string
+		pachdTLS bool
+	}{
+		{
+			name: "plaintext pachd",
+		},
+		{
+			name:     "pachd serving TLS",
+			pachdTLS: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := newPachyderm(func(pd *aimlv1beta1.Pachyderm) {
+				pd.Spec.Pachd.Storage.Backend = aimlv1beta1.MinioStorageBackend
+				pd.Spec.Pachd.Storage.Minio = &aimlv1beta1.MinioStorageOptions{
+					Bucket:       "pachyderm",
+					Endpoint:     "minio:9000",
+					CABundleData: []byte("certificate authority"),
+				}
+				if test.pachdTLS {
+					pd.Spec.Pachd.TLS = &aimlv1beta1.PachdTLSOptions{}
+				}
+			})
+
+			cluster, err := PrepareCluster(pd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pachd := pachdDeployment(t, cluster)
+
+			for _, volume := range pachd.Spec.Template.Spec.Volumes {
+				if volume.Projected != nil && volume.Name != awsTokenVolumeName {
+					t.Fatalf("unexpected projected volume %s", volume.Name)
+				}
+			}
+
+			container := pachd.Spec.Template.Spec.Containers[0]
+			mounted := false
+			for _, mount := range container.VolumeMounts {
+				if mount.Name == storageCASecretName {
+					mounted = mount.MountPath == storageCAMountPath
+				}
+				if mount.MountPath == storageCAMountPath && mount.Name != storageCASecretName {
+					t.Fatalf("volume %s mounted at the storage CA path", mount.Name)
+				}
+			}
+			if !mounted {
+				t.Fatalf("storage CA not mounted at %s", storageCAMountPath)
+			}
+
+			count := map[string]int{}
+			for _, env := range container.Env {
+				count[env.Name]++
+			}
+			for _, name := range []string{"SSL_CERT_DIR", "TLS_CERT_SECRET_NAME"} {
+				if count[name] != 1 {
+					t.Fatalf("expected %s set once, got %d", name, count[name])
+				}
+			}
+		})
+	}
+}
+
+func TestStorageCAVolume(t *testing.T) {
+	pd := newPachyderm(func(pd *aimlv1beta1.Pachyderm) {
+		pd.Spec.Pachd.Storage.Backend = aimlv1beta1.MinioStorageBackend
+		pd.Spec.Pachd.Storage.Minio = &aimlv1beta1.MinioStorageOptions{
+			Bucket:       "pachyderm",
+			Endpoint:     "minio:9000",
+			CABundleData: []byte("certificate authority"),
+		}
+	})
+
+	cluster, err := PrepareCluster(pd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, volume := range pachdDeployment(t, cluster).Spec.Template.Spec.Volumes {
+		if volume.Name == storageCASecretName {
+			if volume.Secret == nil || volume.Secret.SecretName != storageCASecretName {
+				t.Fatalf("unexpected storage CA volume %v", volume.VolumeSource)
+			}
+			return
+		}
+	}
+	t.Fatal("storage CA volume not found")
+}
+
+func TestDefaultStorageSize(t *testing.T) {
+	highAvailability := func(pd *aimlv1beta1.Pachyderm) {
+		pd.Spec.Postgres.HighAvailability = &aimlv1beta1.PostgresHAOptions{Replicas: 2}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(pd *aimlv1beta1.Pachyderm)
+		// apply the defaults of the webhook
+		defaulted bool
+	}{
+		{
+			name: "without webhook defaults",
+		},
+		{
+			name:      "with webhook defaults",
+			defaulted: true,
+		},
+		{
+			name:   "highly available postgres",
+			mutate: highAvailability,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := newUndefaultedPachyderm()
+			if test.mutate != nil {
+				test.mutate(pd)
+			}
+			if test.defaulted {
+				pd.Default()
+			}
+
+			cluster, err := PrepareCluster(pd)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sizes := map[string]string{
+				"etcd": claimSize(t, cluster.EtcdStatefulSet()),
+			}
+			if pd.IsPostgresHA() {
+				instances, _, _ := unstructured.NestedSlice(cluster.PostgresCluster().Object, "spec", "instances")
+				if len(instances) == 0 {
+					t.Fatal("postgres cluster has no instances")
+				}
+				size, _, _ := unstructured.NestedString(instances[0].(map[string]interface{}),
+					"dataVolumeClaimSpec", "resources", "requests", "storage",
+				)
+				sizes["postgres"] = size
+			} else {
+				sizes["postgres"] = claimSize(t, cluster.PostgreStatefulset())
+			}
+
+			for name, size := range sizes {
+				if size != "10Gi" {
+					t.Errorf("expected %s volume of 10Gi, got %q", name, size)
+				}
+			}
+		})
+	}
+}
+
+// claimSize returns the storage requested by the
+// volume claim template of the statefulset
+func claimSize(t *testing.T, sts *appsv1.StatefulSet) string {
+	t.Helper()
+
+	if sts == nil || len(sts.Spec.VolumeClaimTemplates) == 0 {
+		t.Fatal("statefulset has no volume claim template")
+	}
+	requested := sts.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests[corev1.ResourceStorage]
+	return requested.String()
+}
@@ -5,81 +5,232 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"regexp"
+	"sort"
 	"strings"
 
 	// postgres database drivers
 	_ "github.com/lib/pq"
 	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/database"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 func (r *PachydermReconciler) initializePostgres(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
-	initScript, err := r.loadPostgresInitQueries(ctx, pd)
+	if pd.VerifyDatabaseOnly() {
+		return r.verifyPostgres(ctx, pd)
+	}
+
+	condition := metav1.Condition{
+		Type:               aimlv1beta1.ConditionDatabaseReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ScriptsApplied",
+		Message:            "database initialization scripts are applied",
+		ObservedGeneration: pd.Generation,
+	}
+
+	if err := r.applyPostgresScripts(ctx, pd); err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "InitializationFailed"
+		condition.Message = err.Error()
+		if statusErr := r.setDatabaseCondition(ctx, pd, condition); statusErr != nil {
+			return statusErr
+		}
+		return err
+	}
+
+	return r.setDatabaseCondition(ctx, pd, condition)
+}
+
+// applyPostgresScripts connects to the database as the postgres
+// superuser and applies the built-in and user supplied scripts
+func (r *PachydermReconciler) applyPostgresScripts(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	scripts, err := database.Builtin(pd.Spec.Version, database.Params{
+		User:     pd.Spec.Pachd.Postgres.User,
+		Database: pd.Spec.Pachd.Postgres.Database,
+	})
+	if err != nil {
+		return err
+	}
+
+	userScripts, err := r.loadPostgresInitScripts(ctx, pd)
 	if err != nil {
 		return err
 	}
+	scripts = append(scripts, userScripts...)
 
 	adminPassword, err := r.getPostgresAdminPassword(ctx, pd)
 	if err != nil {
 		return err
 	}
 
-	dataSource := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		fmt.Sprintf("%s.%s", pd.Spec.Pachd.Postgres.Host, pd.Namespace),
-		pd.Spec.Pachd.Postgres.Port,
-		"postgres",
-		adminPassword,
-		pd.Spec.Pachd.Postgres.Database,
-	)
-	db, err := sql.Open("postgres", dataSource)
+	db, err := sql.Open("postgres", postgresDataSource(pd, "postgres", adminPassword))
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
 		return err
 	}
 
-	var dexTableExists bool
-	existQuery := "SELECT exists(SELECT datname FROM pg_catalog.pg_database WHERE datname = 'dex');"
-	row := db.QueryRow(existQuery)
-	if err := row.Scan(&dexTableExists); err != nil {
+	applied, err := database.Apply(ctx, db, scripts)
+	if len(applied) > 0 {
+		log.FromContext(ctx).Info("applied database scripts", "pachyderm", pd.Name, "scripts", applied)
+	}
+	return err
+}
+
+// verifyPostgres checks an external database prepared by an
+// administrator is usable by pachd, connecting as the pachd user.
+// Missing objects and grants are reported in the DatabaseReady condition
+func (r *PachydermReconciler) verifyPostgres(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	postgres := pd.Spec.Pachd.Postgres
+	condition := metav1.Condition{
+		Type:               aimlv1beta1.ConditionDatabaseReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Verified",
+		Message:            "databases, role and grants required by pachd exist",
+		ObservedGeneration: pd.Generation,
+	}
+
+	db, err := sql.Open("postgres", postgresDataSource(pd, postgres.User, postgres.Password))
+	if err != nil {
 		return err
 	}
-	if !dexTableExists {
-		for _, query := range initScript {
-			_, err := db.ExecContext(ctx, query)
-			if err != nil {
-				return err
-			}
-		}
+	defer db.Close()
+
+	missing, err := database.Verify(ctx, db, database.Params{
+		User:     postgres.User,
+		Database: postgres.Database,
+	})
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ConnectionFailed"
+		condition.Message = err.Error()
+	case len(missing) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "MissingObjects"
+		condition.Message = strings.Join(missing, "; ")
+	}
+
+	if err := r.setDatabaseCondition(ctx, pd, condition); err != nil {
+		return err
+	}
+
+	if condition.Status == metav1.ConditionFalse {
+		return fmt.Errorf("%w: %s", ErrDatabaseNotReady, condition.Message)
 	}
 
 	return nil
 }
 
-func (r *PachydermReconciler) loadPostgresInitQueries(ctx context.Context, pd *aimlv1beta1.Pachyderm) ([]string, error) {
+// setDatabaseCondition updates the DatabaseReady
+// condition when its status or message changed
+func (r *PachydermReconciler) setDatabaseCondition(ctx context.Context, pd *aimlv1beta1.Pachyderm, condition metav1.Condition) error {
+	current := meta.FindStatusCondition(pd.Status.Conditions, condition.Type)
+	if current != nil &&
+		current.Status == condition.Status &&
+		current.Reason == condition.Reason &&
+		current.Message == condition.Message &&
+		current.ObservedGeneration == condition.ObservedGeneration {
+		return nil
+	}
+
+	original := pd.DeepCopy()
+	meta.SetStatusCondition(&pd.Status.Conditions, condition)
+	return r.Status().Patch(ctx, pd, client.MergeFrom(original))
+}
+
+// postgresDataSource returns the connection string used by the
+// operator to connect to the pachyderm database as user
+func postgresDataSource(pd *aimlv1beta1.Pachyderm, user, password string) string {
+	postgres := pd.Spec.Pachd.Postgres
+
+	// the embedded database is addressed by its service name
+	host := postgres.Host
+	if pd.DeployPostgres() {
+		host = fmt.Sprintf("%s.%s", postgres.Host, pd.Namespace)
+	}
+
+	sslMode := postgres.SSL
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	options := []string{
+		fmt.Sprintf("host=%s", dataSourceValue(host)),
+		fmt.Sprintf("port=%d", postgres.Port),
+		fmt.Sprintf("user=%s", dataSourceValue(user)),
+		fmt.Sprintf("password=%s", dataSourceValue(password)),
+		fmt.Sprintf("dbname=%s", dataSourceValue(postgres.Database)),
+		fmt.Sprintf("sslmode=%s", sslMode),
+	}
+
+	// certificates are passed inline instead of file paths
+	if len(postgres.CABundleData) > 0 || len(postgres.ClientCertificate) > 0 {
+		options = append(options, "sslinline=true")
+	}
+	if len(postgres.CABundleData) > 0 {
+		options = append(options, fmt.Sprintf("sslrootcert=%s", dataSourceValue(string(postgres.CABundleData))))
+	}
+	if len(postgres.ClientCertificate) > 0 {
+		options = append(options,
+			fmt.Sprintf("sslcert=%s", dataSourceValue(string(postgres.ClientCertificate))),
+			fmt.Sprintf("sslkey=%s", dataSourceValue(string(postgres.ClientKey))),
+		)
+	}
+
+	return strings.Join(options, " ")
+}
+
+// dataSourceValue quotes a value of a key/value connection string
+func dataSourceValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return fmt.Sprintf("'%s'", value)
+}
+
+// loadPostgresInitScripts returns the user supplied scripts
+// from the init scripts config map, ordered by key
+func (r *PachydermReconciler) loadPostgresInitScripts(ctx context.Context, pd *aimlv1beta1.Pachyderm) ([]*database.Script, error) {
+	name := pd.Spec.Pachd.Postgres.InitScriptsConfigMap
+	if name == "" {
+		return nil, nil
+	}
+
 	initScripts := &corev1.ConfigMap{}
 	initScriptsKey := types.NamespacedName{
-		Name:      "postgres-init-scripts",
+		Name:      name,
 		Namespace: pd.Namespace,
 	}
 	if err := r.Get(ctx, initScriptsKey, initScripts); err != nil {
 		return nil, err
 	}
 
-	script, ok := initScripts.Data["dex.sh"]
-	if !ok {
-		return nil, errors.New("init script dex.sh not found")
+	keys := []string{}
+	for key := range initScripts.Data {
+		if strings.HasSuffix(key, ".sql") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	scripts := []*database.Script{}
+	for _, key := range keys {
+		script, err := database.NewScript(fmt.Sprintf("user/%s", key), initScripts.Data[key])
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, script)
 	}
 
-	script = strings.ReplaceAll(script, "$POSTGRES_USER", pd.Spec.Pachd.Postgres.User)
-	re := regexp.MustCompile(`(?m)^\s*([A-Z].*)\;$`)
-	return re.FindAllString(script, -1), nil
+	return scripts, nil
 }
 
 func (r *PachydermReconciler) getPostgresAdminPassword(ctx context.Context, pd *aimlv1beta1.Pachyderm) (string, error) {
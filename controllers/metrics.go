@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+var (
+	etcdDBSizeBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pachyderm_operator_etcd_db_size_bytes",
+			Help: "Size of the etcd database file of a member",
+		},
+		[]string{"namespace", "pachyderm", "member"},
+	)
+	etcdDBSizeInUseBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pachyderm_operator_etcd_db_size_in_use_bytes",
+			Help: "Bytes of the etcd database file of a member in use",
+		},
+		[]string{"namespace", "pachyderm", "member"},
+	)
+	etcdMaintenanceTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pachyderm_operator_etcd_maintenance_total",
+			Help: "Number of etcd maintenance runs by result",
+		},
+		[]string{"namespace", "pachyderm", "result"},
+	)
+	etcdLastMaintenanceTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pachyderm_operator_etcd_last_maintenance_timestamp_seconds",
+			Help: "Time the etcd database was last compacted and defragmented",
+		},
+		[]string{"namespace", "pachyderm"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		etcdDBSizeBytes,
+		etcdDBSizeInUseBytes,
+		etcdMaintenanceTotal,
+		etcdLastMaintenanceTimestamp,
+	)
+}
+
+// recordEtcdMetrics publishes the etcd database size reported
+// in the status, removing the series of removed members
+func recordEtcdMetrics(pd *aimlv1beta1.Pachyderm, previous *aimlv1beta1.EtcdStatus) {
+	if previous != nil {
+		for _, member := range previous.Members {
+			etcdDBSizeBytes.DeleteLabelValues(pd.Namespace, pd.Name, member.Name)
+			etcdDBSizeInUseBytes.DeleteLabelValues(pd.Namespace, pd.Name, member.Name)
+		}
+	}
+
+	status := pd.Status.Etcd
+	if status == nil {
+		return
+	}
+
+	for _, member := range status.Members {
+		etcdDBSizeBytes.WithLabelValues(pd.Namespace, pd.Name, member.Name).Set(float64(member.DBSize))
+		etcdDBSizeInUseBytes.WithLabelValues(pd.Namespace, pd.Name, member.Name).Set(float64(member.DBSizeInUse))
+	}
+	if status.LastMaintenanceTime != nil {
+		etcdLastMaintenanceTimestamp.WithLabelValues(pd.Namespace, pd.Name).Set(float64(status.LastMaintenanceTime.Unix()))
+	}
+}
+
+// forgetEtcdMetrics removes the series of a deleted pachyderm resource
+func forgetEtcdMetrics(pd *aimlv1beta1.Pachyderm) {
+	if status := pd.Status.Etcd; status != nil {
+		for _, member := range status.Members {
+			etcdDBSizeBytes.DeleteLabelValues(pd.Namespace, pd.Name, member.Name)
+			etcdDBSizeInUseBytes.DeleteLabelValues(pd.Namespace, pd.Name, member.Name)
+		}
+	}
+	etcdLastMaintenanceTimestamp.DeleteLabelValues(pd.Namespace, pd.Name)
+	for _, result := range []string{"success", "failure"} {
+		etcdMaintenanceTotal.DeleteLabelValues(pd.Namespace, pd.Name, result)
+	}
+}
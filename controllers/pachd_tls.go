@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+const (
+	// port of the pachd API, served over TLS when enabled
+	pachdAPIPort     int32 = 30650
+	pachdDialTimeout       = 5 * time.Second
+)
+
+// loadPachdCABundle reads the certificate authorities
+// trusted by the operator when connecting to pachd
+func (r *PachydermReconciler) loadPachdCABundle(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	options := pd.Spec.Pachd.TLS
+	if options.Source != aimlv1beta1.PachdTLSSecretSource {
+		bundle, err := r.serviceCABundle(ctx, pd, aimlv1beta1.PachdServiceCABundleName, "pachd")
+		if err != nil {
+			return err
+		}
+		options.CABundleData = bundle
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      options.SecretName,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		return err
+	}
+
+	// a self-signed certificate is its own authority
+	for _, key := range []string{"ca.crt", corev1.TLSCertKey} {
+		if bundle, ok := secret.Data[key]; ok {
+			options.CABundleData = bundle
+			return nil
+		}
+	}
+
+	return NewKeyError(
+		fmt.Sprintf("the key %s missing in secret %s",
+			corev1.TLSCertKey,
+			secretKey.Name),
+	)
+}
+
+// isPachdServingTLS returns true if pachd completes a TLS
+// handshake with a certificate issued by the trusted authorities
+func (r *PachydermReconciler) isPachdServingTLS(ctx context.Context, pd *aimlv1beta1.Pachyderm) bool {
+	if err := r.loadPachdCABundle(ctx, pd); err != nil {
+		log.FromContext(ctx).Info("pachd certificate authority unavailable", "pachyderm", pd.Name, "error", err.Error())
+		return false
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pd.Spec.Pachd.TLS.CABundleData) {
+		return false
+	}
+
+	dialer := &net.Dialer{Timeout: pachdDialTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp",
+		fmt.Sprintf("pachd.%s.svc.cluster.local:%d", pd.Namespace, pachdAPIPort),
+		&tls.Config{
+			RootCAs:    pool,
+			ServerName: fmt.Sprintf("pachd.%s.svc", pd.Namespace),
+			MinVersion: tls.VersionTLS12,
+		},
+	)
+	if err != nil {
+		log.FromContext(ctx).Info("pachd TLS handshake failed", "pachyderm", pd.Name, "error", err.Error())
+		return false
+	}
+	defer conn.Close()
+
+	return true
+}
@@ -19,22 +19,30 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	goerrors "errors"
 	"fmt"
 	"net"
 	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"golang.org/x/mod/semver"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
 	"github.com/pachyderm/openshift-operator/controllers/generators"
@@ -46,6 +54,9 @@ import (
 
 const (
 	pachydermFinalizer string = "finalizer.pachyderm.com"
+	// field indexes of the secrets and config maps referenced by pachyderm resources
+	secretIndexKey    string = ".metadata.referencedSecrets"
+	configMapIndexKey string = ".metadata.referencedConfigMaps"
 )
 
 // PachydermReconciler reconciles a Pachyderm object
@@ -53,6 +64,16 @@ type PachydermReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	Config *rest.Config
+	// config hash of the last successful
+	// storage pre-flight check of each resource
+	storageProbes sync.Map
+	// sets the password of a database role when rotating the
+	// pachd password. Defaults to alterPostgresPassword
+	setRolePassword func(ctx context.Context, pd *aimlv1beta1.Pachyderm, user, password string) error
+	// adds or removes an etcd member when scaling
+	// etcd. Defaults to changeEtcdMembers
+	changeEtcdMembers func(ctx context.Context, pd *aimlv1beta1.Pachyderm, current, members int32) error
 }
 
 //+kubebuilder:rbac:groups=aiml.pachyderm.com,resources=pachyderms,verbs=get;list;watch;create;update;patch;delete
@@ -62,8 +83,11 @@ type PachydermReconciler struct {
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete;deletecollection
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts/token,verbs=create
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;update;patch;delete
 //+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
@@ -75,7 +99,8 @@ type PachydermReconciler struct {
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,resourceNames=anyuid,verbs=use
+//+kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,resourceNames=anyuid;hostmount-anyuid,verbs=use
+//+kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile function attempts to bring the state of the world to resemble the desired state
 func (r *PachydermReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -112,14 +137,70 @@ func (r *PachydermReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		if err == ErrServiceNotReady {
 			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 		}
+		if goerrors.Is(err, ErrStorageUnreachable) || goerrors.Is(err, ErrDatabaseNotReady) ||
+			goerrors.Is(err, ErrEtcdQuorum) {
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+		if goerrors.Is(err, ErrVolumesResizing) || goerrors.Is(err, ErrEtcdScaling) ||
+			goerrors.Is(err, ErrCertificatesPending) {
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	result := ctrl.Result{}
+	// refresh the vault credentials before they expire
+	if renewAfter, ok := vaultRenewAfter(pd); ok {
+		result.RequeueAfter = renewAfter
+	}
+	// rotate the database password when the interval elapses
+	if rotateAfter, ok := postgresRotateAfter(pd); ok {
+		if rotateAfter < time.Second {
+			rotateAfter = time.Second
+		}
+		if result.RequeueAfter == 0 || rotateAfter < result.RequeueAfter {
+			result.RequeueAfter = rotateAfter
+		}
+	}
+
+	// replace the etcd certificates before they expire, checking
+	// every few seconds while a new certificate authority rolls out
+	if renewAfter, ok := etcdCertificatesRenewAfter(pd); ok {
+		if renewAfter < 10*time.Second {
+			renewAfter = 10 * time.Second
+		}
+		if result.RequeueAfter == 0 || renewAfter < result.RequeueAfter {
+			result.RequeueAfter = renewAfter
+		}
+	}
+	// check the etcd database size and run the scheduled maintenance
+	if checkAfter, ok := etcdCheckAfter(pd); ok {
+		if checkAfter < time.Second {
+			checkAfter = time.Second
+		}
+		if result.RequeueAfter == 0 || checkAfter < result.RequeueAfter {
+			result.RequeueAfter = checkAfter
+		}
+	}
+
+	return result, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PachydermReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ctx := context.Background()
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &aimlv1beta1.Pachyderm{}, secretIndexKey, func(obj client.Object) []string {
+		return obj.(*aimlv1beta1.Pachyderm).ReferencedSecrets()
+	}); err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &aimlv1beta1.Pachyderm{}, configMapIndexKey, func(obj client.Object) []string {
+		return obj.(*aimlv1beta1.Pachyderm).ReferencedConfigMaps()
+	}); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&aimlv1beta1.Pachyderm{}).
 		Owns(&networkingv1.Ingress{}).
@@ -128,10 +209,44 @@ func (r *PachydermReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&corev1.Secret{}).
 		Owns(&rbacv1.Role{}).
 		Owns(&rbacv1.RoleBinding{}).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.referencingPachyderms(secretIndexKey)),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.referencingPachyderms(configMapIndexKey)),
+		).
 		WithEventFilter(filterEvents()).
 		Complete(r)
 }
 
+// referencingPachyderms returns a function mapping a secret or
+// config map to the pachyderm resources referencing it by name
+func (r *PachydermReconciler) referencingPachyderms(indexKey string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		pachyderms := &aimlv1beta1.PachydermList{}
+		if err := r.List(context.Background(), pachyderms,
+			client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{indexKey: obj.GetName()},
+		); err != nil {
+			r.Log.Error(err, "unable to list pachyderm resources", "name", obj.GetName())
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, pd := range pachyderms.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      pd.Name,
+					Namespace: pd.Namespace,
+				},
+			})
+		}
+		return requests
+	}
+}
+
 func filterEvents() predicate.Funcs {
 	return predicate.Funcs{
 		DeleteFunc: func(event.DeleteEvent) bool {
@@ -167,34 +282,234 @@ func (r *PachydermReconciler) validatePachyderm(ctx context.Context, pd *aimlv1b
 		return err
 	}
 
-	if pd.Spec.Pachd.Storage.Backend == aimlv1beta1.GoogleStorageBackend &&
-		pd.Spec.Pachd.Storage.Google != nil {
-		credentials, err := r.googleCredentialsJSON(ctx, pd)
-		if err != nil {
-			return err
+	if err := postgresLoginUser(ctx, r.Client, pd); err != nil {
+		return err
+	}
+
+	if err := r.postgresTLS(ctx, pd); err != nil {
+		return err
+	}
+
+	return r.storageCredentials(ctx, pd)
+}
+
+// storageCredentials resolves the object storage credentials
+// from the secrets referenced by the storage options of pd
+func (r *PachydermReconciler) storageCredentials(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	storage := pd.Spec.Pachd.Storage
+
+	var err error
+	switch storage.Backend {
+	case aimlv1beta1.GoogleStorageBackend:
+		// no credentials are injected when using workload identity
+		if storage.Google != nil && storage.Google.CredentialSecret != "" {
+			credentials, err := r.googleCredentialsJSON(ctx, pd)
+			if err != nil {
+				return err
+			}
+
+			pd.SetGoogleCredentials(credentials)
+		}
+	case aimlv1beta1.AmazonStorageBackend:
+		err = r.amazonCredentials(ctx, pd)
+	case aimlv1beta1.MicrosoftStorageBackend:
+		if storage.Microsoft != nil && storage.Microsoft.CredentialSecretName != "" {
+			err = r.microsoftCredentials(ctx, pd)
+		}
+	case aimlv1beta1.MinioStorageBackend:
+		if storage.Minio != nil {
+			err = r.minioCredentials(ctx, pd)
 		}
+	case aimlv1beta1.CustomStorageBackend:
+		if storage.Custom != nil {
+			err = r.customCredentials(ctx, pd)
+		}
+	case aimlv1beta1.LocalStorageBackend:
+		if storage.Local == nil {
+			pd.Spec.Pachd.Storage.Local = &aimlv1beta1.LocalStorageOptions{}
+		}
+	}
+
+	// if pachyderm is marked for deletion but
+	// secret is missing, return nil
+	if errors.IsNotFound(err) && pd.DeletionTimestamp != nil {
+		return nil
+	}
+
+	return err
+}
 
-		pd.SetGoogleCredentials(credentials)
+// amazonCredentials reads the S3 bucket details and access keys
+// from the secret referenced by the pachyderm resource. When an
+// IAM role or vault is used only the bucket and region are read
+func (r *PachydermReconciler) amazonCredentials(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	amazon := pd.Spec.Pachd.Storage.Amazon
+	if pd.IsUsingAmazonIAMRole() || pd.IsUsingVault() {
+		return r.amazonBucket(ctx, pd)
 	}
 
-	if pd.Spec.Pachd.Storage.Backend == aimlv1beta1.AmazonStorageBackend {
+	credentialSecretKey := types.NamespacedName{
+		Name:      amazon.CredentialSecretName,
+		Namespace: pd.Namespace,
+	}
+	credentialSecret := &corev1.Secret{}
+	if err := r.Get(ctx, credentialSecretKey, credentialSecret); err != nil {
+		return err
+	}
+	accessID, ok := credentialSecret.Data["access-id"]
+	if !ok {
+		return NewKeyError(
+			fmt.Sprintf("the key %s missing in secret %s.\n",
+				"access-id",
+				credentialSecretKey.Name),
+		)
+	}
+	accessSecret, ok := credentialSecret.Data["access-secret"]
+	if !ok {
+		return NewKeyError(
+			fmt.Sprintf("the key %s missing in secret %s.\n",
+				"access-secret",
+				credentialSecretKey.Name),
+		)
+	}
+	bucket, ok := credentialSecret.Data["bucket"]
+	if !ok {
+		return NewKeyError(
+			fmt.Sprintf("the key %s missing in secret %s.\n",
+				"bucket",
+				credentialSecretKey.Name),
+		)
+	}
+	region, ok := credentialSecret.Data["region"]
+	if !ok {
+		return NewKeyError(
+			fmt.Sprintf("the key %s missing in secret %s.\n",
+				"region",
+				credentialSecretKey.Name),
+		)
+	}
+	if token, ok := credentialSecret.Data["token"]; ok {
+		amazon.Token = string(token)
+	}
+	if endpoint, ok := credentialSecret.Data["custom-endpoint"]; ok {
+		amazon.CustomEndpoint = string(endpoint)
+	}
+	amazon.ID = string(accessID)
+	amazon.Secret = string(accessSecret)
+	amazon.Bucket = string(bucket)
+	amazon.Region = string(region)
+
+	return nil
+}
+
+// amazonBucket resolves the bucket and region from the spec or
+// the optional credential secret when pachd does not use static
+// keys. With an IAM role the AWS SDK falls back to the web identity token
+func (r *PachydermReconciler) amazonBucket(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	amazon := pd.Spec.Pachd.Storage.Amazon
+	amazon.ID = ""
+	amazon.Secret = ""
+	amazon.Token = ""
+
+	if amazon.CredentialSecretName != "" {
 		credentialSecretKey := types.NamespacedName{
-			Name:      pd.Spec.Pachd.Storage.Amazon.CredentialSecretName,
+			Name:      amazon.CredentialSecretName,
 			Namespace: pd.Namespace,
 		}
 		credentialSecret := &corev1.Secret{}
 		if err := r.Get(ctx, credentialSecretKey, credentialSecret); err != nil {
-			// if pachyderm is marked for deletion but
-			// secret is missing, return nil
-			if errors.IsNotFound(err) && pd.DeletionTimestamp != nil {
-				return nil
+			if !errors.IsNotFound(err) {
+				return err
 			}
+		}
+		if bucket, ok := credentialSecret.Data["bucket"]; ok {
+			amazon.Bucket = string(bucket)
+		}
+		if region, ok := credentialSecret.Data["region"]; ok {
+			amazon.Region = string(region)
+		}
+		if endpoint, ok := credentialSecret.Data["custom-endpoint"]; ok {
+			amazon.CustomEndpoint = string(endpoint)
+		}
+	}
+
+	if amazon.Bucket == "" || amazon.Region == "" {
+		return fmt.Errorf("%w: bucket and region must be set in the spec or secret %s",
+			ErrMissingStorageConfig,
+			amazon.CredentialSecretName,
+		)
+	}
+
+	return nil
+}
+
+// customCredentials reads the access keys and CA bundle of
+// the S3-compatible object store referenced by the resource
+func (r *PachydermReconciler) customCredentials(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	custom := pd.Spec.Pachd.Storage.Custom
+
+	credentialSecretKey := types.NamespacedName{
+		Name:      custom.CredentialSecretName,
+		Namespace: pd.Namespace,
+	}
+	credentialSecret := &corev1.Secret{}
+	if err := r.Get(ctx, credentialSecretKey, credentialSecret); err != nil {
+		return err
+	}
+
+	accessID, ok := credentialSecret.Data["access-id"]
+	if !ok {
+		return NewKeyError(
+			fmt.Sprintf("the key %s missing in secret %s",
+				"access-id",
+				credentialSecretKey.Name),
+		)
+	}
+	accessSecret, ok := credentialSecret.Data["access-secret"]
+	if !ok {
+		return NewKeyError(
+			fmt.Sprintf("the key %s missing in secret %s",
+				"access-secret",
+				credentialSecretKey.Name),
+		)
+	}
+	custom.ID = string(accessID)
+	custom.Secret = string(accessSecret)
+
+	if custom.Region == "" {
+		custom.Region = "us-east-1"
+	}
+
+	if custom.CABundle != nil {
+		bundle, err := r.caBundle(ctx, pd.Namespace, custom.CABundle)
+		if err != nil {
+			return err
+		}
+		custom.CABundleData = bundle
+	}
+
+	return nil
+}
+
+// minioCredentials reads the minio access keys and
+// CA bundle from the objects referenced by the pachyderm resource
+func (r *PachydermReconciler) minioCredentials(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	minio := pd.Spec.Pachd.Storage.Minio
+
+	if minio.CredentialSecretName != "" {
+		credentialSecretKey := types.NamespacedName{
+			Name:      minio.CredentialSecretName,
+			Namespace: pd.Namespace,
+		}
+		credentialSecret := &corev1.Secret{}
+		if err := r.Get(ctx, credentialSecretKey, credentialSecret); err != nil {
 			return err
 		}
+
 		accessID, ok := credentialSecret.Data["access-id"]
 		if !ok {
 			return NewKeyError(
-				fmt.Sprintf("the key %s missing in secret %s.\n",
+				fmt.Sprintf("the key %s missing in secret %s",
 					"access-id",
 					credentialSecretKey.Name),
 			)
@@ -202,38 +517,113 @@ func (r *PachydermReconciler) validatePachyderm(ctx context.Context, pd *aimlv1b
 		accessSecret, ok := credentialSecret.Data["access-secret"]
 		if !ok {
 			return NewKeyError(
-				fmt.Sprintf("the key %s missing in secret %s.\n",
+				fmt.Sprintf("the key %s missing in secret %s",
 					"access-secret",
 					credentialSecretKey.Name),
 			)
 		}
-		bucket, ok := credentialSecret.Data["bucket"]
-		if !ok {
-			return NewKeyError(
-				fmt.Sprintf("the key %s missing in secret %s.\n",
-					"bucket",
-					credentialSecretKey.Name),
-			)
+		minio.ID = string(accessID)
+		minio.Secret = string(accessSecret)
+	}
+
+	if minio.CABundle != nil {
+		bundle, err := r.caBundle(ctx, pd.Namespace, minio.CABundle)
+		if err != nil {
+			return err
 		}
-		region, ok := credentialSecret.Data["region"]
-		if !ok {
-			return NewKeyError(
-				fmt.Sprintf("the key %s missing in secret %s.\n",
-					"region",
-					credentialSecretKey.Name),
-			)
+		minio.CABundleData = bundle
+	}
+
+	return nil
+}
+
+// caBundle returns the PEM encoded certificate authorities
+// stored in the referenced config map or secret
+func (r *PachydermReconciler) caBundle(ctx context.Context, namespace string, source *aimlv1beta1.CABundleSource) ([]byte, error) {
+	key := source.Key
+	if key == "" {
+		key = "ca.crt"
+	}
+
+	if source.ConfigMapName != "" {
+		cm := &corev1.ConfigMap{}
+		cmKey := types.NamespacedName{
+			Name:      source.ConfigMapName,
+			Namespace: namespace,
 		}
-		if token, ok := credentialSecret.Data["token"]; ok {
-			pd.Spec.Pachd.Storage.Amazon.Token = string(token)
+		if err := r.Get(ctx, cmKey, cm); err != nil {
+			return nil, err
 		}
-		if endpoint, ok := credentialSecret.Data["custom-endpoint"]; ok {
-			pd.Spec.Pachd.Storage.Amazon.CustomEndpoint = string(endpoint)
+
+		bundle, ok := cm.Data[key]
+		if !ok {
+			return nil, NewKeyError(
+				fmt.Sprintf("the key %s missing in config map %s",
+					key,
+					cmKey.Name),
+			)
 		}
-		pd.Spec.Pachd.Storage.Amazon.ID = string(accessID)
-		pd.Spec.Pachd.Storage.Amazon.Secret = string(accessSecret)
-		pd.Spec.Pachd.Storage.Amazon.Bucket = string(bucket)
-		pd.Spec.Pachd.Storage.Amazon.Region = string(region)
+		return []byte(bundle), nil
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      source.SecretName,
+		Namespace: namespace,
+	}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		return nil, err
+	}
+
+	bundle, ok := secret.Data[key]
+	if !ok {
+		return nil, NewKeyError(
+			fmt.Sprintf("the key %s missing in secret %s",
+				key,
+				secretKey.Name),
+		)
+	}
+	return bundle, nil
+}
+
+// microsoftCredentials reads the Azure storage account
+// credentials from the secret referenced by the pachyderm resource
+func (r *PachydermReconciler) microsoftCredentials(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	microsoft := pd.Spec.Pachd.Storage.Microsoft
+	credentialSecretKey := types.NamespacedName{
+		Name:      microsoft.CredentialSecretName,
+		Namespace: pd.Namespace,
+	}
+	credentialSecret := &corev1.Secret{}
+	if err := r.Get(ctx, credentialSecretKey, credentialSecret); err != nil {
+		return err
+	}
+
+	accountName, ok := credentialSecret.Data["account-name"]
+	if !ok {
+		return NewKeyError(
+			fmt.Sprintf("the key %s missing in secret %s",
+				"account-name",
+				credentialSecretKey.Name),
+		)
 	}
+	microsoft.ID = string(accountName)
+
+	// workload identity replaces the storage account key
+	if pd.IsUsingAzureWorkloadIdentity() {
+		microsoft.Secret = ""
+		return nil
+	}
+
+	accountKey, ok := credentialSecret.Data["account-key"]
+	if !ok {
+		return NewKeyError(
+			fmt.Sprintf("the key %s missing in secret %s",
+				"account-key",
+				credentialSecretKey.Name),
+		)
+	}
+	microsoft.Secret = string(accountKey)
 
 	return nil
 }
@@ -262,6 +652,45 @@ func (r *PachydermReconciler) postgresPassword(ctx context.Context, pd *aimlv1be
 	return nil
 }
 
+// postgresTLS reads the CA bundle and client certificate
+// used to connect to an external database over TLS
+func (r *PachydermReconciler) postgresTLS(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	postgres := &pd.Spec.Pachd.Postgres
+
+	if postgres.CABundle != nil {
+		bundle, err := r.caBundle(ctx, pd.Namespace, postgres.CABundle)
+		if err != nil {
+			return err
+		}
+		postgres.CABundleData = bundle
+	}
+
+	if postgres.ClientCertificateSecretName != "" {
+		certSecret := &corev1.Secret{}
+		certSecretKey := types.NamespacedName{
+			Name:      postgres.ClientCertificateSecretName,
+			Namespace: pd.Namespace,
+		}
+		if err := r.Get(ctx, certSecretKey, certSecret); err != nil {
+			return err
+		}
+
+		for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+			if _, ok := certSecret.Data[key]; !ok {
+				return NewKeyError(
+					fmt.Sprintf("the key %s missing in secret %s",
+						key,
+						certSecretKey.Name),
+				)
+			}
+		}
+		postgres.ClientCertificate = certSecret.Data[corev1.TLSCertKey]
+		postgres.ClientKey = certSecret.Data[corev1.TLSPrivateKeyKey]
+	}
+
+	return nil
+}
+
 func (r *PachydermReconciler) googleCredentialsJSON(ctx context.Context, pd *aimlv1beta1.Pachyderm) ([]byte, error) {
 	gcsKey := types.NamespacedName{
 		Namespace: pd.Namespace,
@@ -285,6 +714,25 @@ func (r *PachydermReconciler) reconcilePachydermObj(ctx context.Context, pd *aim
 		return err
 	}
 
+	// dynamic credentials are only requested for a valid resource
+	if pd.IsUsingVault() {
+		if err := r.vaultCredentials(ctx, pd); err != nil {
+			return err
+		}
+	}
+
+	if err := r.checkObjectStorage(ctx, pd); err != nil {
+		return err
+	}
+
+	if err := r.rotatePostgresPassword(ctx, pd); err != nil {
+		return err
+	}
+
+	if err := r.reconcileEtcdTLS(ctx, pd); err != nil {
+		return err
+	}
+
 	components, err := generators.PrepareCluster(pd)
 	if err != nil {
 		return err
@@ -371,7 +819,15 @@ func (r *PachydermReconciler) reconcilePachydermObj(ctx context.Context, pd *aim
 		return err
 	}
 
-	return nil
+	if err := r.scaleEtcd(ctx, pd); err != nil {
+		return err
+	}
+
+	if err := r.maintainEtcd(ctx, pd); err != nil {
+		return err
+	}
+
+	return r.expandVolumes(ctx, pd)
 }
 
 // TODO: cleanup Pachyderm objects
@@ -469,15 +925,92 @@ func (r *PachydermReconciler) reconcileDeployments(ctx context.Context, componen
 		}
 		if err := r.Create(ctx, deployment); err != nil {
 			if errors.IsAlreadyExists(err) {
-				// TODO: add logic to check if update is required
-				return nil
+				if err := r.updatePodTemplate(ctx, deployment); err != nil {
+					return err
+				}
+				if deployment.Name == generators.PGBouncerName {
+					if err := r.updateReplicas(ctx, deployment); err != nil {
+						return err
+					}
+				}
+				continue
 			}
 			return err
 		}
 	}
+
+	// removed after pachd is updated to connect directly
+	if components.Pachyderm().Spec.PGBouncer.Disable {
+		return r.removePGBouncer(ctx, components.Pachyderm())
+	}
+
+	return nil
+}
+
+// updateReplicas scales an existing deployment
+// to the replicas of the generated deployment
+func (r *PachydermReconciler) updateReplicas(ctx context.Context, deployment *appsv1.Deployment) error {
+	if deployment.Spec.Replicas == nil {
+		return nil
+	}
+
+	current := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(deployment), current); err != nil {
+		return err
+	}
+
+	if current.Spec.Replicas != nil && *current.Spec.Replicas == *deployment.Spec.Replicas {
+		return nil
+	}
+
+	patch := client.MergeFrom(current.DeepCopy())
+	current.Spec.Replicas = deployment.Spec.Replicas
+
+	return r.Patch(ctx, current, patch)
+}
+
+// removePGBouncer deletes the pg-bouncer deployment and
+// service once pachd connects to the database directly
+func (r *PachydermReconciler) removePGBouncer(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	objects := []client.Object{
+		&appsv1.Deployment{},
+		&corev1.Service{},
+	}
+	for _, obj := range objects {
+		obj.SetName(generators.PGBouncerName)
+		obj.SetNamespace(pd.Namespace)
+		if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// updatePodTemplate replaces the pod template of an existing
+// deployment when the storage settings or credentials it consumes
+// have changed, triggering a rolling restart
+func (r *PachydermReconciler) updatePodTemplate(ctx context.Context, deployment *appsv1.Deployment) error {
+	hash, ok := deployment.Spec.Template.Annotations[aimlv1beta1.PachdConfigHashAnnotation]
+	if !ok {
+		return nil
+	}
+
+	current := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(deployment), current); err != nil {
+		return err
+	}
+
+	if current.Spec.Template.Annotations[aimlv1beta1.PachdConfigHashAnnotation] == hash {
+		return nil
+	}
+
+	patch := client.MergeFrom(current.DeepCopy())
+	current.Spec.Template = deployment.Spec.Template
+
+	return r.Patch(ctx, current, patch)
+}
+
 func isUpgradable(pd *aimlv1beta1.Pachyderm) bool {
 	desiredVersion := pd.Spec.Version
 	currentVersion := pd.Status.CurrentVersion
@@ -512,8 +1045,8 @@ func (r *PachydermReconciler) reconcileStatus(ctx context.Context, pd *aimlv1bet
 		return err
 	}
 
-	if pd.Status.PachdAddress == "" {
-		pd.Status.PachdAddress = pachdAddress(pd)
+	if address := pachdAddress(pd); pd.Status.PachdAddress != address {
+		pd.Status.PachdAddress = address
 	}
 
 	if pd.IsDeleted() && pd.Status.Phase != aimlv1beta1.PhaseDeleting {
@@ -534,6 +1067,8 @@ func (r *PachydermReconciler) reconcileStatus(ctx context.Context, pd *aimlv1bet
 		pd.Status.CurrentVersion = pd.Spec.Version
 	}
 
+	setPausedCondition(pd)
+
 	if !reflect.DeepEqual(pd.Status, current.Status) {
 		return r.Status().Patch(ctx, pd, client.MergeFrom(current))
 	}
@@ -541,18 +1076,48 @@ func (r *PachydermReconciler) reconcileStatus(ctx context.Context, pd *aimlv1bet
 	return nil
 }
 
+// setPausedCondition reports if pachd is paused
+// and the pachyderm export that paused it
+func setPausedCondition(pd *aimlv1beta1.Pachyderm) {
+	condition := metav1.Condition{
+		Type:               aimlv1beta1.ConditionPaused,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Resumed",
+		Message:            "pachd is not paused",
+		ObservedGeneration: pd.Generation,
+	}
+
+	if pd.IsPaused() {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "PauseAnnotation"
+		condition.Message = "pachd paused by the pause-cluster annotation"
+		if migration := pd.PausedByMigration(); migration != "" {
+			condition.Reason = "PachydermStorageMigration"
+			condition.Message = fmt.Sprintf("pachd paused by pachydermstoragemigration %s", migration)
+		} else if export := pd.PausedBy(); export != "" {
+			condition.Reason = "PachydermExport"
+			condition.Message = fmt.Sprintf("pachd paused by pachydermexport %s", export)
+		}
+	}
+
+	meta.SetStatusCondition(&pd.Status.Conditions, condition)
+}
+
 // ClusterStatus returns address of the Pachyderm cluster
 type ClusterStatus struct {
 	PachdAddress string `json:"pachd_address,omitempty"`
 }
 
 func pachdAddress(pd *aimlv1beta1.Pachyderm) string {
-	var port int32 = 30650
+	var port int32 = pachdAPIPort
 	var namespace string = pd.ObjectMeta.Namespace
 	cluster := ClusterStatus{
 		PachdAddress: fmt.Sprintf("%s.%s.svc.cluster.local:%d",
 			"pachd", namespace, port),
 	}
+	if pd.Spec.Pachd.TLS != nil {
+		cluster.PachdAddress = "grpcs://" + cluster.PachdAddress
+	}
 	data, err := json.Marshal(cluster)
 	if err != nil {
 		return ""
@@ -599,6 +1164,11 @@ func (r *PachydermReconciler) isPachydermRunning(ctx context.Context, pd *aimlv1
 		return false
 	}
 
+	// pachd API served with the trusted certificate
+	if pd.Spec.Pachd.TLS != nil && !r.isPachdServingTLS(ctx, pd) {
+		return false
+	}
+
 	// pachd-peer connection test
 	const retries = 3
 	for i := 0; i < retries; i++ {
@@ -658,7 +1228,10 @@ func (r *PachydermReconciler) reconcileServiceAccounts(ctx context.Context, comp
 
 		if err := r.Create(ctx, sa); err != nil {
 			if errors.IsAlreadyExists(err) {
-				return nil
+				if err := r.updateServiceAccountMetadata(ctx, sa); err != nil {
+					return err
+				}
+				continue
 			}
 
 			return err
@@ -668,6 +1241,36 @@ func (r *PachydermReconciler) reconcileServiceAccounts(ctx context.Context, comp
 	return nil
 }
 
+// updateServiceAccountMetadata adds the annotations and labels of
+// the generated service account to the existing service account.
+// These carry the cloud identity the pachd and worker pods assume
+func (r *PachydermReconciler) updateServiceAccountMetadata(ctx context.Context, sa *corev1.ServiceAccount) error {
+	current := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sa), current); err != nil {
+		return err
+	}
+
+	updated := current.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	for key, value := range sa.Annotations {
+		updated.Annotations[key] = value
+	}
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for key, value := range sa.Labels {
+		updated.Labels[key] = value
+	}
+
+	if reflect.DeepEqual(current.ObjectMeta, updated.ObjectMeta) {
+		return nil
+	}
+
+	return r.Update(ctx, updated)
+}
+
 // TODO(OchiengEd): remove owner reference and use finalizers to clean up roles
 func (r *PachydermReconciler) reconcileRoles(ctx context.Context, components *generators.PachydermCluster) error {
 
@@ -789,7 +1392,7 @@ func (r *PachydermReconciler) reconcileSecrets(ctx context.Context, components *
 		if err := r.Create(ctx, secret); err != nil {
 			if errors.IsAlreadyExists(err) {
 				if secret.Name == "postgres" {
-					return nil
+					continue
 				}
 				// Check if the secret contents have changed
 				currentSecret := &corev1.Secret{}
@@ -810,7 +1413,7 @@ func (r *PachydermReconciler) reconcileSecrets(ctx context.Context, components *
 					}
 				}
 				// secret exists
-				return nil
+				continue
 			}
 
 			return err
@@ -878,6 +1481,10 @@ func (r *PachydermReconciler) deployEtcd(ctx context.Context, components *genera
 }
 
 func (r *PachydermReconciler) deployPostgres(ctx context.Context, components *generators.PachydermCluster) error {
+	if components.Pachyderm().IsPostgresHA() {
+		return r.deployPostgresCluster(ctx, components)
+	}
+
 	postgres := components.PostgreStatefulset()
 	if err := controllerutil.SetControllerReference(components.Pachyderm(), postgres, r.Scheme); err != nil {
 		return err
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+func TestCustomStorageSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   map[string][]byte
+		failed bool
+	}{
+		{
+			name: "credentials from secret",
+			data: map[string][]byte{
+				"access-id":     []byte("access"),
+				"access-secret": []byte("secret"),
+			},
+		},
+		{
+			name: "missing access secret",
+			data: map[string][]byte{
+				"access-id": []byte("access"),
+			},
+			failed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := &aimlv1beta1.Pachyderm{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachyderm",
+					Namespace: "default",
+				},
+				Spec: aimlv1beta1.PachydermSpec{
+					Version: "v2.1.6",
+					Pachd: aimlv1beta1.PachdOptions{
+						Postgres: aimlv1beta1.PachdPostgresConfig{
+							Host:     "postgres",
+							Port:     5432,
+							SSL:      "disable",
+							User:     "pachyderm",
+							Database: "pachyderm",
+						},
+						Storage: aimlv1beta1.ObjectStorageOptions{
+							Backend: aimlv1beta1.CustomStorageBackend,
+							Custom: &aimlv1beta1.CustomStorageOptions{
+								Bucket:               "pachyderm",
+								Endpoint:             "ceph-rgw:8080",
+								CredentialSecretName: "ceph-credentials",
+							},
+						},
+					},
+				},
+			}
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ceph-credentials",
+					Namespace: pd.Namespace,
+				},
+				Data: test.data,
+			}
+
+			c, scheme := newFakeClient(t, pd, credentials)
+			r := &PachydermReconciler{Client: c, Scheme: scheme}
+
+			ctx := context.Background()
+			err := r.storageCredentials(ctx, pd)
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+			if err != nil {
+				return
+			}
+
+			components, err := generators.PrepareCluster(pd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.reconcileSecrets(ctx, components); err != nil {
+				t.Fatal(err)
+			}
+
+			secret := &corev1.Secret{}
+			if err := c.Get(ctx, types.NamespacedName{Name: storageSecretName, Namespace: pd.Namespace}, secret); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := map[string]string{
+				"AMAZON_BUCKET":   "pachyderm",
+				"AMAZON_ID":       "access",
+				"AMAZON_SECRET":   "secret",
+				"AMAZON_REGION":   "us-east-1",
+				"CUSTOM_ENDPOINT": "ceph-rgw:8080",
+			}
+			for key, value := range expected {
+				if actual := string(secret.Data[key]); actual != value {
+					t.Errorf("expected %s to be %q, got %q", key, value, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestVaultCredentials(t *testing.T) {
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/v1/aws/creds/pachd" || r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lease_id":       "aws/creds/pachd/lease",
+			"lease_duration": 3600,
+			"renewable":      true,
+			"data": map[string]string{
+				"access_key": "access",
+				"secret_key": "secret",
+			},
+		})
+	}))
+	defer server.Close()
+
+	pd := &aimlv1beta1.Pachyderm{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pachyderm",
+			Namespace: "default",
+		},
+		Spec: aimlv1beta1.PachydermSpec{
+			Pachd: aimlv1beta1.PachdOptions{
+				Storage: aimlv1beta1.ObjectStorageOptions{
+					Backend: aimlv1beta1.AmazonStorageBackend,
+					Amazon: &aimlv1beta1.AmazonStorageOptions{
+						Bucket: "pachyderm",
+						Region: "us-east-1",
+						Vault: &aimlv1beta1.AmazonStorageVault{
+							Address:         server.URL,
+							Role:            "pachd",
+							AWSMountPath:    "aws",
+							TokenSecretName: "vault-token",
+							CABundle: &aimlv1beta1.CABundleSource{
+								SecretName: "vault-ca",
+								Key:        "ca.crt",
+							},
+							TLSServerName: "example.com",
+						},
+					},
+				},
+			},
+		},
+	}
+	token := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vault-token",
+			Namespace: pd.Namespace,
+		},
+		Data: map[string][]byte{
+			"token": []byte("vault-token"),
+		},
+	}
+	ca := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vault-ca",
+			Namespace: pd.Namespace,
+		},
+		Data: map[string][]byte{
+			"ca.crt": pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE",
+				Bytes: server.Certificate().Raw,
+			}),
+		},
+	}
+
+	c, scheme := newFakeClient(t, pd, token, ca)
+	r := &PachydermReconciler{Client: c, Scheme: scheme}
+
+	// validation only resolves the bucket and region
+	ctx := context.Background()
+	if err := r.storageCredentials(ctx, pd); err != nil {
+		t.Fatal(err)
+	}
+	if count := atomic.LoadInt32(&requests); count != 0 {
+		t.Fatalf("expected no vault requests during validation, got %d", count)
+	}
+
+	if err := r.vaultCredentials(ctx, pd); err != nil {
+		t.Fatal(err)
+	}
+	amazon := pd.Spec.Pachd.Storage.Amazon
+	if amazon.ID != "access" || amazon.Secret != "secret" {
+		t.Fatalf("unexpected credentials %s/%s", amazon.ID, amazon.Secret)
+	}
+
+	current := &aimlv1beta1.Pachyderm{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pd), current); err != nil {
+		t.Fatal(err)
+	}
+	if lease := current.Status.VaultLease; lease == nil || lease.ID != "aws/creds/pachd/lease" {
+		t.Fatalf("unexpected vault lease %+v", lease)
+	}
+}
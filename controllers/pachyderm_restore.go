@@ -58,6 +58,10 @@ func (r *PachydermImportReconciler) restorePachyderm(ctx context.Context, req *a
 		return err
 	}
 
+	if err := r.verifyBackupContent(ctx, req, bk); err != nil {
+		return err
+	}
+
 	// create the pachyderm object returned from backup
 	if err := func(ctx context.Context, backup *backupContent) error {
 		if err := r.Create(ctx, backup.object); err != nil {
@@ -197,6 +201,8 @@ func newRestorequest(export *aimlv1beta1.PachydermImport) ([]byte, error) {
 type backupContent struct {
 	// holds database dump
 	database []byte
+	// holds the pachyderm object as stored in the backup
+	resource []byte
 	// holds pachyderm object backup
 	object *aimlv1beta1.Pachyderm
 }
@@ -248,6 +254,7 @@ func decodeBackupContent(req *aimlv1beta1.PachydermImport, restore *restoreservi
 
 	return &backupContent{
 		database: db,
+		resource: cr,
 		object:   pd,
 	}, nil
 }
@@ -304,5 +311,6 @@ func (r *PachydermImportReconciler) initiateDBRestore(ctx context.Context, pd *a
 
 func (r *PachydermExportReconciler) exitMaintenanceMode(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
 	delete(pd.Annotations, aimlv1beta1.PachydermPauseAnnotation)
+	delete(pd.Annotations, aimlv1beta1.PachydermPausedByAnnotation)
 	return r.Update(ctx, pd)
 }
@@ -17,38 +17,41 @@ limitations under the License.
 package controllers
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"reflect"
 	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	goerrors "errors"
 
-	backupcmd "github.com/opdev/backup-handler/cmd/command"
-	backupservice "github.com/opdev/backup-handler/gen/backup_service"
 	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	// duration an export may keep the pachyderm cluster paused
+	// when spec.pauseTimeout is not set
+	defaultPauseTimeout time.Duration = time.Hour
 )
 
 // PachydermExportReconciler reconciles a PachydermExport object
 type PachydermExportReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Config *rest.Config
 }
 
 //+kubebuilder:rbac:groups=aiml.pachyderm.com,resources=pachydermexports,verbs=get;list;watch;create;update;patch;delete
@@ -58,6 +61,7 @@ type PachydermExportReconciler struct {
 //+kubebuilder:rbac:groups=core,resources=pods/exec,verbs=create;get
 //+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile method runs when an event is triggered for the watched reesources
 func (r *PachydermExportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -85,23 +89,50 @@ func (r *PachydermExportReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
+	// Failed exports are not retried
+	if strings.EqualFold(export.Status.Phase, aimlv1beta1.ExportFailedStatus) {
+		return ctrl.Result{}, nil
+	}
+
 	if !reflect.DeepEqual(current.Status, aimlv1beta1.PachydermExportStatus{}) {
 		if err := r.checkBackupStatus(ctx, export); err != nil {
-			// Do nothing if the backup is not found
-			if strings.Contains(err.Error(), fmt.Sprintf("backup %s not found", export.Status.ID)) {
-				return ctrl.Result{}, nil
+			if goerrors.Is(err, ErrBackupFailed) {
+				return r.abortExport(ctx, export, current, err)
+			}
+			if goerrors.Is(err, ErrStoragePodNotReady) {
+				return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 			}
 			return ctrl.Result{}, err
 		}
 
+		if pauseTimeoutExpired(export) {
+			return r.abortExport(ctx, export, current, ErrPauseTimeout)
+		}
+
 		// Requeue the request if the status is still running
 		if strings.EqualFold(export.Status.Phase, aimlv1beta1.ExportRunningStatus) {
 			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 		}
+
+		if strings.EqualFold(export.Status.Phase, aimlv1beta1.ExportVerifyingStatus) {
+			if err := r.verifyBackup(ctx, export); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 	}
 
 	// If status is empty, create new backup
 	if err := r.newBackupTask(ctx, export); err != nil {
+		if goerrors.Is(err, ErrBackupFailed) {
+			return r.abortExport(ctx, export, current, err)
+		}
+		// the backup starts once pachd has scaled down
+		if goerrors.Is(err, ErrPachdPodsRunning) {
+			if err := r.Status().Patch(ctx, export, client.MergeFrom(current)); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
+		}
 		return ctrl.Result{}, err
 	}
 
@@ -109,128 +140,126 @@ func (r *PachydermExportReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
+	// Requeue the request until the export is completed
+	if !isExportFinished(export) {
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	if err := r.deleteStoragePod(ctx, export); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	return ctrl.Result{}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *PachydermExportReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&aimlv1beta1.PachydermExport{}).
-		Complete(r)
-}
+// abortExport marks the export failed and resumes
+// the pachyderm cluster if it was paused by the export
+func (r *PachydermExportReconciler) abortExport(ctx context.Context, export, current *aimlv1beta1.PachydermExport, reason error) (ctrl.Result, error) {
+	log.FromContext(ctx).Info("pachyderm export failed", "export", export.Name, "reason", reason.Error())
 
-func (r *PachydermExportReconciler) getStatefulSetPods(ctx context.Context, sts *appsv1.StatefulSet) (*corev1.PodList, error) {
-	listOptions := &client.ListOptions{
-		Namespace:     sts.Namespace,
-		LabelSelector: labels.SelectorFromSet(sts.Spec.Template.ObjectMeta.Labels),
+	export.Status.Phase = aimlv1beta1.ExportFailedStatus
+	export.Status.Status = reason.Error()
+	if export.Status.CompletedAt == "" {
+		export.Status.CompletedAt = time.Now().UTC().String()
 	}
-	pods := &corev1.PodList{}
-	if err := r.List(ctx, pods, listOptions); err != nil {
-		return nil, err
+
+	if err := r.stopBackupJob(ctx, export); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	return pods, nil
-}
+	if err := r.deleteBackupJobSecret(ctx, export); err != nil {
+		return ctrl.Result{}, err
+	}
 
-func newBackupRequest(export *aimlv1beta1.PachydermExport, pd *aimlv1beta1.Pachyderm, pod, container string, commands []string) ([]byte, error) {
-	cr, err := json.Marshal(pd)
-	if err != nil {
-		return nil, err
+	if err := r.resumePachyderm(ctx, export); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	c := backupcmd.Marshal(commands).String()
+	if err := r.deleteStoragePod(ctx, export); err != nil {
+		return ctrl.Result{}, err
+	}
 
-	storageSecret := export.Spec.StorageSecret
-	encodedCR := base64.StdEncoding.EncodeToString(cr)
+	if err := r.Status().Patch(ctx, export, client.MergeFrom(current)); err != nil {
+		return ctrl.Result{}, err
+	}
 
-	return json.Marshal(
-		&backup{
-			Name:               &export.Name,
-			Namespace:          &export.Namespace,
-			Pod:                &pod,
-			Container:          &container,
-			StorageSecret:      &storageSecret,
-			KubernetesResource: &encodedCR,
-			Command:            &c,
-		},
-	)
+	return ctrl.Result{}, nil
 }
 
-func createBackup(export *aimlv1beta1.PachydermExport, pd *aimlv1beta1.Pachyderm, pods *corev1.PodList) (*backupservice.Backupresult, error) {
-	if export.Status.ID != "" {
-		return nil, nil
-	}
+func isExportFinished(export *aimlv1beta1.PachydermExport) bool {
+	return strings.EqualFold(export.Status.Phase, aimlv1beta1.ExportCompletedStatus) ||
+		strings.EqualFold(export.Status.Phase, aimlv1beta1.ExportFailedStatus)
+}
 
-	// Backup the pachyderm resource
-	payload, err := newBackupRequest(
-		export,
-		pd,
-		pods.Items[0].Name,
-		"postgres",
-		[]string{"bash", "-c", "pg_dump -U pachyderm -Ft -d pachyderm"},
-	)
-	if err != nil {
-		return nil, err
+// pauseTimeoutExpired returns true if the export has kept
+// the pachyderm cluster paused longer than the pause timeout
+func pauseTimeoutExpired(export *aimlv1beta1.PachydermExport) bool {
+	if export.Status.PausedAt == "" || isExportFinished(export) {
+		return false
 	}
 
-	request, err := http.NewRequest(http.MethodPost, "http://localhost:8890/backups", bytes.NewBuffer(payload))
+	pausedAt, err := time.Parse(time.RFC3339, export.Status.PausedAt)
 	if err != nil {
-		return nil, err
+		return false
 	}
-	request.Header.Set("Content-Type", "application/json")
-	defer request.Body.Close()
 
-	client := &http.Client{}
-	response, err := client.Do(request)
-	if err != nil {
-		return nil, err
+	timeout := defaultPauseTimeout
+	if export.Spec.PauseTimeout != nil {
+		timeout = export.Spec.PauseTimeout.Duration
 	}
-	defer response.Body.Close()
 
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	return parseBackupresult(body)
+	return time.Since(pausedAt) > timeout
 }
 
-func parseBackupresult(body []byte) (*backupservice.Backupresult, error) {
-	temp := backup{}
-	if err := json.Unmarshal(body, &temp); err != nil {
-		return nil, err
-	}
-	result := backupservice.Backupresult(temp)
-	return &result, nil
+// SetupWithManager sets up the controller with the Manager.
+func (r *PachydermExportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&aimlv1beta1.PachydermExport{}).
+		Owns(&batchv1.Job{}).
+		Owns(&corev1.Pod{}).
+		Complete(r)
 }
 
-func getBackup(export *aimlv1beta1.PachydermExport) (*backupservice.Backupresult, error) {
-	url := fmt.Sprintf("http://localhost:8890/backups/%s", export.Status.ID)
-	request, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
+func (r *PachydermExportReconciler) getStatefulSetPods(ctx context.Context, sts *appsv1.StatefulSet) (*corev1.PodList, error) {
+	listOptions := &client.ListOptions{
+		Namespace:     sts.Namespace,
+		LabelSelector: labels.SelectorFromSet(sts.Spec.Template.ObjectMeta.Labels),
 	}
-	request.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	response, err := client.Do(request)
-	if err != nil {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, listOptions); err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode == http.StatusNotFound {
-		export.Status.CompletedAt = time.Now().UTC().String()
-		msg := fmt.Sprintf("backup %s not found", export.Status.ID)
-		return nil, goerrors.New(msg)
+	return pods, nil
+}
+
+// postgresPods returns the pods of the embedded database.
+// Only the primary is returned for a highly available database
+func (r *PachydermExportReconciler) postgresPods(ctx context.Context, pd *aimlv1beta1.Pachyderm) (*corev1.PodList, error) {
+	if pd.IsPostgresHA() {
+		pods := &corev1.PodList{}
+		if err := r.List(ctx, pods,
+			client.InNamespace(pd.Namespace),
+			client.MatchingLabels(generators.PostgresPrimarySelector()),
+		); err != nil {
+			return nil, err
+		}
+		return pods, nil
 	}
 
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
+	pg := &appsv1.StatefulSet{}
+	pgKey := types.NamespacedName{
+		Namespace: pd.Namespace,
+		Name:      "postgres",
+	}
+	if err := r.Get(ctx, pgKey, pg); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: postgres statefulset not found", ErrBackupFailed)
+		}
 		return nil, err
 	}
 
-	return parseBackupresult(body)
+	return r.getStatefulSetPods(ctx, pg)
 }
 
 func (r *PachydermExportReconciler) newBackupTask(ctx context.Context, export *aimlv1beta1.PachydermExport) error {
@@ -241,96 +270,201 @@ func (r *PachydermExportReconciler) newBackupTask(ctx context.Context, export *a
 
 	pd, err := r.pachydermForBackup(ctx, export)
 	if err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("%w: pachyderm %s not found", ErrBackupFailed, export.Spec.Target)
+		}
 		return err
 	}
 
-	if err := r.pausePachydermAnnotation(ctx, pd); err != nil {
-		return err
-	}
-
+	// only the embedded postgresql database can be backed up
 	if pd.Spec.Postgres.Disable {
-		return nil
+		return fmt.Errorf("%w: pachyderm %s uses an external database", ErrBackupFailed, pd.Name)
 	}
 
-	pg := &appsv1.StatefulSet{}
-	pgKey := types.NamespacedName{
-		Namespace: pd.Namespace,
-		Name:      "postgres",
-	}
-	if err := r.Get(ctx, pgKey, pg); err != nil {
-		return err
+	// pg_dump and etcd snapshots are consistent on their
+	// own, so online exports keep pachd running
+	export.Status.Mode = exportMode(export)
+	export.Status.Consistency = exportConsistency(export)
+	if export.Status.Mode == aimlv1beta1.ExportOfflineMode {
+		if err := r.pausePachydermAnnotation(ctx, export, pd); err != nil {
+			return err
+		}
+		if export.Status.PausedAt == "" {
+			export.Status.PausedAt = time.Now().UTC().Format(time.RFC3339)
+		}
+
+		// pachd writes to the database until its pods terminate
+		stopped, err := pachdStopped(ctx, r.Client, pd)
+		if err != nil {
+			return err
+		}
+		if !stopped {
+			export.Status.Status = "waiting for pachd to stop"
+			return ErrPachdPodsRunning
+		}
 	}
 
-	pods, err := r.getStatefulSetPods(ctx, pg)
+	pods, err := r.postgresPods(ctx, pd)
 	if err != nil {
 		return err
 	}
 
-	backup, err := createBackup(export, pd, pods)
-	if err != nil {
-		return err
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("%w: %s", ErrBackupFailed, ErrPostgresNotReady.Error())
 	}
 
-	if backup != nil {
-		if backup.ID != nil {
-			export.Status.ID = *backup.ID
+	if export.Spec.IncludeEtcd {
+		if _, err := r.etcdPod(ctx, pd); err != nil {
+			return err
 		}
+	}
 
-		if backup.Name != nil {
-			export.Status.Name = *backup.Name
+	job, err := r.startBackupJob(ctx, export, pd)
+	if err != nil {
+		if goerrors.Is(err, ErrBackupFailed) {
+			return err
 		}
-
-		if backup.CreatedAt != nil {
-			export.Status.StartedAt = *backup.CreatedAt
+		return fmt.Errorf("%w: %s", ErrBackupFailed, err.Error())
+	}
+
+	export.Status.ID = string(job.UID)
+	export.Status.Status = ""
+	export.Status.Name = job.Annotations[backupNameAnnotation]
+	export.Status.StartedAt = job.CreationTimestamp.UTC().String()
+	export.Status.Phase = aimlv1beta1.ExportRunningStatus
+	if export.Spec.IncludeEtcd {
+		export.Status.EtcdSnapshot = &aimlv1beta1.ExportSnapshot{
+			ID:    fmt.Sprintf("%s-etcd", export.Status.Name),
+			Phase: aimlv1beta1.ExportRunningStatus,
 		}
+	}
 
-		if backup.State != nil {
-			export.Status.Phase = *backup.State
+	return nil
+}
+
+// etcdPod returns the etcd pod snapshots are taken from
+func (r *PachydermExportReconciler) etcdPod(ctx context.Context, pd *aimlv1beta1.Pachyderm) (types.NamespacedName, error) {
+	etcd := &appsv1.StatefulSet{}
+	etcdKey := types.NamespacedName{
+		Namespace: pd.Namespace,
+		Name:      "etcd",
+	}
+	if err := r.Get(ctx, etcdKey, etcd); err != nil {
+		if errors.IsNotFound(err) {
+			return types.NamespacedName{}, fmt.Errorf("%w: etcd statefulset not found", ErrBackupFailed)
 		}
+		return types.NamespacedName{}, err
 	}
 
-	return nil
+	pods, err := r.getStatefulSetPods(ctx, etcd)
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+
+	if len(pods.Items) == 0 {
+		return types.NamespacedName{}, fmt.Errorf("%w: no etcd pods found", ErrBackupFailed)
+	}
+
+	return types.NamespacedName{
+		Namespace: pods.Items[0].Namespace,
+		Name:      pods.Items[0].Name,
+	}, nil
 }
 
+// exportMode returns the mode used by the export
+func exportMode(export *aimlv1beta1.PachydermExport) string {
+	if strings.EqualFold(export.Spec.Mode, aimlv1beta1.ExportOnlineMode) {
+		return aimlv1beta1.ExportOnlineMode
+	}
+
+	return aimlv1beta1.ExportOfflineMode
+}
+
+// exportConsistency describes the guarantees
+// provided by a backup taken in the export mode
+func exportConsistency(export *aimlv1beta1.PachydermExport) string {
+	if exportMode(export) == aimlv1beta1.ExportOfflineMode {
+		return "pachd is paused while the backup is taken"
+	}
+
+	consistency := "the database dump is transactionally consistent; " +
+		"changes made by running pipelines after the dump starts are not included"
+	if export.Spec.IncludeEtcd {
+		consistency += "; the etcd snapshot is taken separately and may not match the database dump"
+	}
+
+	return consistency
+}
+
+// checkBackupStatus updates the export from the status of the backup job
 func (r *PachydermExportReconciler) checkBackupStatus(ctx context.Context, export *aimlv1beta1.PachydermExport) error {
 	if export.Status.ID == "" || export.Status.CompletedAt != "" {
 		return nil
 	}
 
-	backup, err := getBackup(export)
-	if err != nil {
+	job := &batchv1.Job{}
+	jobKey := types.NamespacedName{
+		Name:      backupJobName(export),
+		Namespace: export.Namespace,
+	}
+	if err := r.Get(ctx, jobKey, job); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("%w: backup job %s not found", ErrBackupFailed, jobKey.Name)
+		}
 		return err
 	}
 
-	if backup == nil {
+	if failed, message := isJobFailed(job); failed {
+		// the containers report why they failed
+		if pod, err := jobPod(ctx, r.Client, job); err == nil && pod != nil {
+			for _, name := range []string{"etcd-snapshot", "backup"} {
+				if terminated := containerTermination(pod, name); terminated != nil && terminated.ExitCode != 0 {
+					message = strings.TrimSpace(terminated.Message)
+				}
+			}
+		}
+		return fmt.Errorf("%w: backup job %s failed: %s", ErrBackupFailed, job.Name, message)
+	}
+
+	if job.Status.Succeeded == 0 {
 		return nil
 	}
 
-	if backup.State != nil {
-		export.Status.Phase = *backup.State
+	if err := r.finishUploads(ctx, export, job, true); err != nil {
+		return err
 	}
 
-	if backup.Location != nil {
-		export.Status.Location = *backup.Location
+	export.Status.Phase = aimlv1beta1.ExportCompletedStatus
+	export.Status.Location = job.Annotations[backupLocationAnnotation]
+	export.Status.CompletedAt = time.Now().UTC().String()
+	if job.Status.CompletionTime != nil {
+		export.Status.CompletedAt = job.Status.CompletionTime.UTC().String()
+	}
+	if export.Status.EtcdSnapshot != nil {
+		export.Status.EtcdSnapshot.Phase = aimlv1beta1.ExportCompletedStatus
+		export.Status.EtcdSnapshot.Location = job.Annotations[snapshotLocationAnnotation]
 	}
 
-	if backup.DeletedAt != nil {
-		export.Status.CompletedAt = *backup.DeletedAt
+	if err := r.deleteBackupJobSecret(ctx, export); err != nil {
+		return err
 	}
 
-	if reflect.DeepEqual(export.Status, aimlv1beta1.PachydermExportStatus{}) {
-		return nil
+	return r.completeBackup(ctx, export, job)
+}
+
+// completeBackup resumes the pachyderm cluster and records
+// the contents of the backup uploaded by the backup job
+func (r *PachydermExportReconciler) completeBackup(ctx context.Context, export *aimlv1beta1.PachydermExport, job *batchv1.Job) error {
+	if err := r.resumePachyderm(ctx, export); err != nil {
+		return err
 	}
 
-	if strings.EqualFold(export.Status.Phase, aimlv1beta1.ExportCompletedStatus) {
-		pd, err := r.pachydermForBackup(ctx, export)
-		if err != nil {
-			return err
-		}
+	if err := r.recordBackupArtifacts(ctx, export, job); err != nil {
+		return err
+	}
 
-		if err := r.exitMaintenanceMode(ctx, pd); err != nil {
-			return err
-		}
+	if export.Spec.Verify && !export.Status.Verified {
+		export.Status.Phase = aimlv1beta1.ExportVerifyingStatus
 	}
 
 	return nil
@@ -349,12 +483,34 @@ func (r *PachydermExportReconciler) pachydermForBackup(ctx context.Context, expo
 	return pd, nil
 }
 
-func (r *PachydermExportReconciler) pausePachydermAnnotation(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+func (r *PachydermExportReconciler) pausePachydermAnnotation(ctx context.Context, export *aimlv1beta1.PachydermExport, pd *aimlv1beta1.Pachyderm) error {
 	if pd.Annotations == nil {
-		pd.Annotations = map[string]string{
-			aimlv1beta1.PachydermPauseAnnotation: "true",
-		}
+		pd.Annotations = map[string]string{}
 	}
+	pd.Annotations[aimlv1beta1.PachydermPauseAnnotation] = "true"
+	pd.Annotations[aimlv1beta1.PachydermPausedByAnnotation] = export.Name
 
 	return r.Update(ctx, pd)
 }
+
+// resumePachyderm removes the pause annotation from
+// the pachyderm cluster if it was paused by the export
+func (r *PachydermExportReconciler) resumePachyderm(ctx context.Context, export *aimlv1beta1.PachydermExport) error {
+	pd, err := r.pachydermForBackup(ctx, export)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	// clusters paused by hand or by another export stay paused
+	if pd.IsPaused() && pd.PausedBy() == export.Name {
+		if err := r.exitMaintenanceMode(ctx, pd); err != nil {
+			return err
+		}
+	}
+	export.Status.PausedAt = ""
+
+	return nil
+}
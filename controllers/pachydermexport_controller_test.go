@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+// newFakeClient returns a client backed by an
+// in-memory object tracker holding the given objects
+func newFakeClient(t *testing.T, objects ...client.Object) (client.Client, *runtime.Scheme) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := aimlv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(), scheme
+}
+
+func TestResumePachyderm(t *testing.T) {
+	tests := []struct {
+		name     string
+		pausedBy string
+		resumed  bool
+	}{
+		{
+			name:     "paused by the export",
+			pausedBy: "backup",
+			resumed:  true,
+		},
+		{
+			name:     "paused by hand",
+			pausedBy: "",
+		},
+		{
+			name:     "paused by another export",
+			pausedBy: "other",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := &aimlv1beta1.Pachyderm{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachyderm",
+					Namespace: "default",
+					Annotations: map[string]string{
+						aimlv1beta1.PachydermPauseAnnotation: "true",
+					},
+				},
+			}
+			if test.pausedBy != "" {
+				pd.Annotations[aimlv1beta1.PachydermPausedByAnnotation] = test.pausedBy
+			}
+			export := &aimlv1beta1.PachydermExport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "backup",
+					Namespace: "default",
+				},
+				Spec: aimlv1beta1.PachydermExportSpec{
+					Target: pd.Name,
+				},
+			}
+
+			c, scheme := newFakeClient(t, pd, export)
+			r := &PachydermExportReconciler{Client: c, Scheme: scheme}
+
+			ctx := context.Background()
+			if err := r.resumePachyderm(ctx, export); err != nil {
+				t.Fatal(err)
+			}
+
+			current := &aimlv1beta1.Pachyderm{}
+			if err := c.Get(ctx, types.NamespacedName{Name: pd.Name, Namespace: pd.Namespace}, current); err != nil {
+				t.Fatal(err)
+			}
+			if current.IsPaused() == test.resumed {
+				t.Fatalf("expected resumed %t", test.resumed)
+			}
+		})
+	}
+}
+
+func TestNewBackupTaskWaitsForPachd(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int32
+		running  int32
+		err      error
+	}{
+		{
+			name:     "pachd running",
+			replicas: 1,
+			running:  1,
+			err:      ErrPachdPodsRunning,
+		},
+		{
+			name:    "pachd terminating",
+			running: 1,
+			err:     ErrPachdPodsRunning,
+		},
+		{
+			// the backup proceeds to the missing database
+			name: "pachd stopped",
+			err:  ErrBackupFailed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := &aimlv1beta1.Pachyderm{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachyderm",
+					Namespace: "default",
+				},
+			}
+			pachd := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachd",
+					Namespace: pd.Namespace,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &test.replicas,
+				},
+				Status: appsv1.DeploymentStatus{
+					Replicas: test.running,
+				},
+			}
+			export := &aimlv1beta1.PachydermExport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "backup",
+					Namespace: pd.Namespace,
+				},
+				Spec: aimlv1beta1.PachydermExportSpec{
+					Target: pd.Name,
+				},
+			}
+
+			c, scheme := newFakeClient(t, pd, pachd, export)
+			r := &PachydermExportReconciler{Client: c, Scheme: scheme}
+
+			ctx := context.Background()
+			if err := r.newBackupTask(ctx, export); !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			current := &aimlv1beta1.Pachyderm{}
+			if err := c.Get(ctx, types.NamespacedName{Name: pd.Name, Namespace: pd.Namespace}, current); err != nil {
+				t.Fatal(err)
+			}
+			if current.PausedBy() != export.Name {
+				t.Fatal("expected pachyderm paused by the export")
+			}
+
+			jobs := &batchv1.JobList{}
+			if err := c.List(ctx, jobs); err != nil {
+				t.Fatal(err)
+			}
+			if len(jobs.Items) != 0 || export.Status.ID != "" {
+				t.Fatal("expected no backup job before pachd stops")
+			}
+		})
+	}
+}
+
+func TestCheckBackupStatusFailure(t *testing.T) {
+	export := &aimlv1beta1.PachydermExport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backup",
+			Namespace: "default",
+		},
+		Status: aimlv1beta1.PachydermExportStatus{
+			ID: "backup",
+		},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupJobName(export),
+			Namespace: export.Namespace,
+		},
+		Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{
+				{
+					Type:    batchv1.JobFailed,
+					Status:  corev1.ConditionTrue,
+					Message: "Job has reached the specified backoff limit",
+				},
+			},
+		},
+	}
+	reason := "pachyderm-backup.tar.gz holds 128849018880 bytes, more than the 100 parts of 1073741824 bytes the upload accepts"
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backup-backup-x7k2p",
+			Namespace: export.Namespace,
+			Labels:    map[string]string{"job-name": job.Name},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodFailed,
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  "etcd-snapshot",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}},
+				},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "backup",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: reason + "\n"},
+					},
+				},
+			},
+		},
+	}
+
+	c, scheme := newFakeClient(t, export, job, pod)
+	r := &PachydermExportReconciler{Client: c, Scheme: scheme}
+
+	err := r.checkBackupStatus(context.Background(), export)
+	if !errors.Is(err, ErrBackupFailed) {
+		t.Fatalf("expected error %v, got %v", ErrBackupFailed, err)
+	}
+	if !strings.HasSuffix(err.Error(), reason) {
+		t.Fatalf("expected the failure reported by the backup container, got %v", err)
+	}
+}
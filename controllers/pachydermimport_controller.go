@@ -40,6 +40,7 @@ type PachydermImportReconciler struct {
 //+kubebuilder:rbac:groups=aiml.pachyderm.com,resources=pachydermimports,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=aiml.pachyderm.com,resources=pachydermimports/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=aiml.pachyderm.com,resources=pachydermimports/finalizers,verbs=update
+//+kubebuilder:rbac:groups=aiml.pachyderm.com,resources=pachydermexports,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -77,6 +78,10 @@ func (r *PachydermImportReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		if err == ErrDatabaseNotFound {
 			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 		}
+		// Do not restore a backup that failed verification
+		if err == ErrChecksumMismatch {
+			return ctrl.Result{}, nil
+		}
 		if strings.Contains(err.Error(), "pachyderm resource not found") {
 			return ctrl.Result{}, nil
 		}
@@ -0,0 +1,415 @@
+/*
+Copyright 2021 Pachyderm.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	goerrors "errors"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+// PachydermStorageMigrationReconciler reconciles a PachydermStorageMigration object
+type PachydermStorageMigrationReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Config *rest.Config
+}
+
+//+kubebuilder:rbac:groups=aiml.pachyderm.com,resources=pachydermstoragemigrations,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=aiml.pachyderm.com,resources=pachydermstoragemigrations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=aiml.pachyderm.com,resources=pachydermstoragemigrations/finalizers,verbs=update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile method runs when an event is triggered for the watched resources
+func (r *PachydermStorageMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	migration := &aimlv1beta1.PachydermStorageMigration{}
+	if err := r.Get(ctx, req.NamespacedName, migration); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Completed and failed migrations are not retried
+	if isMigrationFinished(migration) {
+		return ctrl.Result{}, nil
+	}
+
+	current := migration.DeepCopy()
+
+	var err error
+	switch migration.Status.Phase {
+	case "":
+		err = r.startMigration(ctx, migration)
+	case aimlv1beta1.MigrationPausingStatus:
+		err = r.startCopy(ctx, migration)
+	case aimlv1beta1.MigrationCopyingStatus, aimlv1beta1.MigrationVerifyingStatus:
+		err = r.checkMigrationStatus(ctx, migration)
+	}
+	if err != nil {
+		if goerrors.Is(err, ErrMigrationFailed) {
+			return r.abortMigration(ctx, migration, current, err)
+		}
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Status().Patch(ctx, migration, client.MergeFrom(current)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Requeue the request until the migration is completed
+	if !isMigrationFinished(migration) {
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PachydermStorageMigrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&aimlv1beta1.PachydermStorageMigration{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
+
+func isMigrationFinished(migration *aimlv1beta1.PachydermStorageMigration) bool {
+	return migration.Status.Phase == aimlv1beta1.MigrationCompletedStatus ||
+		migration.Status.Phase == aimlv1beta1.MigrationFailedStatus
+}
+
+// migrationPausedBy returns the value of the paused-by
+// annotation set on the pachyderm cluster by the migration
+func migrationPausedBy(migration *aimlv1beta1.PachydermStorageMigration) string {
+	return aimlv1beta1.StorageMigrationPausePrefix + migration.Name
+}
+
+func (r *PachydermStorageMigrationReconciler) migrationTarget(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration) (*aimlv1beta1.Pachyderm, error) {
+	pd := &aimlv1beta1.Pachyderm{}
+	pdKey := types.NamespacedName{
+		Namespace: migration.Namespace,
+		Name:      migration.Spec.Target,
+	}
+	if err := r.Get(ctx, pdKey, pd); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: pachyderm %s not found", ErrMigrationFailed, migration.Spec.Target)
+		}
+		return nil, err
+	}
+
+	return pd, nil
+}
+
+// migrationRemotes resolves the credentials of the storage currently
+// used by pd and the destination of the migration
+func (r *PachydermStorageMigrationReconciler) migrationRemotes(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration, pd *aimlv1beta1.Pachyderm) (*migrationRemote, *migrationRemote, error) {
+	source := pd.DeepCopy()
+	destination := pd.DeepCopy()
+	destination.Spec.Pachd.Storage = *migration.Spec.Destination.DeepCopy()
+
+	resolver := &PachydermReconciler{
+		Client: r.Client,
+		Log:    log.FromContext(ctx),
+		Scheme: r.Scheme,
+		Config: r.Config,
+	}
+
+	remotes := []*migrationRemote{}
+	for _, storage := range []struct {
+		pd        *aimlv1beta1.Pachyderm
+		mountPath string
+	}{
+		{source, migrationSourceMountPath},
+		{destination, migrationDestinationMountPath},
+	} {
+		// vault leases are recorded in the status of the
+		// pachyderm resource and can not be requested here
+		if storage.pd.IsUsingVault() {
+			return nil, nil, fmt.Errorf("%w: credentials issued by vault are not supported", ErrMigrationFailed)
+		}
+
+		if err := resolver.storageCredentials(ctx, storage.pd); err != nil {
+			var keyErr *ErrKeyNotFound
+			if errors.IsNotFound(err) || goerrors.As(err, &keyErr) ||
+				goerrors.Is(err, ErrMissingStorageConfig) {
+				return nil, nil, fmt.Errorf("%w: %s", ErrMigrationFailed, err.Error())
+			}
+			return nil, nil, err
+		}
+
+		remote, err := storageRemote(storage.pd, storage.mountPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		remotes = append(remotes, remote)
+	}
+
+	return remotes[0], remotes[1], nil
+}
+
+// startMigration validates the migration and pauses the pachyderm cluster
+func (r *PachydermStorageMigrationReconciler) startMigration(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration) error {
+	pd, err := r.migrationTarget(ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	if pausedBy := pd.PausedBy(); pausedBy != "" && pausedBy != migrationPausedBy(migration) {
+		return fmt.Errorf("%w: pachyderm %s is paused by %s", ErrMigrationFailed, pd.Name, pausedBy)
+	}
+
+	// pachd is only scaled down once the cluster is running
+	if pd.Status.Phase != aimlv1beta1.PhaseRunning {
+		migration.Status.Status = fmt.Sprintf("waiting for pachyderm %s to be running", pd.Name)
+		return nil
+	}
+
+	source, destination, err := r.migrationRemotes(ctx, migration, pd)
+	if err != nil {
+		return err
+	}
+
+	if source.location == destination.location {
+		return fmt.Errorf("%w: pachyderm %s already stores objects in %s", ErrMigrationFailed, pd.Name, destination.location)
+	}
+
+	if pd.Annotations == nil {
+		pd.Annotations = map[string]string{}
+	}
+	pd.Annotations[aimlv1beta1.PachydermPauseAnnotation] = "true"
+	pd.Annotations[aimlv1beta1.PachydermPausedByAnnotation] = migrationPausedBy(migration)
+	if err := r.Update(ctx, pd); err != nil {
+		return err
+	}
+
+	migration.Status.Phase = aimlv1beta1.MigrationPausingStatus
+	migration.Status.StartedAt = time.Now().UTC().String()
+	migration.Status.Source = source.location
+	migration.Status.Destination = destination.location
+	migration.Status.PreviousBackend = pd.Spec.Pachd.Storage.Backend
+	migration.Status.Status = fmt.Sprintf("waiting for pachd of %s to stop", pd.Name)
+
+	return nil
+}
+
+// pachdStopped returns true once the pachd pods have terminated
+func pachdStopped(ctx context.Context, c client.Reader, pd *aimlv1beta1.Pachyderm) (bool, error) {
+	pachd := &appsv1.Deployment{}
+	pachdKey := types.NamespacedName{
+		Name:      "pachd",
+		Namespace: pd.Namespace,
+	}
+	if err := c.Get(ctx, pachdKey, pachd); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if pachd.Spec.Replicas != nil && *pachd.Spec.Replicas != 0 {
+		return false, nil
+	}
+
+	return pachd.Status.Replicas == 0, nil
+}
+
+// startCopy starts the migration job once pachd has stopped
+func (r *PachydermStorageMigrationReconciler) startCopy(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration) error {
+	pd, err := r.migrationTarget(ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	if pd.PausedByMigration() != migration.Name {
+		return fmt.Errorf("%w: pachyderm %s was resumed before the migration started", ErrMigrationFailed, pd.Name)
+	}
+
+	stopped, err := pachdStopped(ctx, r.Client, pd)
+	if err != nil || !stopped {
+		return err
+	}
+
+	image, err := migrationImage(migration, pd)
+	if err != nil {
+		return err
+	}
+
+	source, destination, err := r.migrationRemotes(ctx, migration, pd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.startMigrationJob(ctx, migration, image, source, destination); err != nil {
+		return err
+	}
+
+	migration.Status.Phase = aimlv1beta1.MigrationCopyingStatus
+	migration.Status.Status = fmt.Sprintf("copying objects from %s", migration.Status.Source)
+
+	return nil
+}
+
+// checkMigrationStatus updates the migration from the status of the
+// migration job and switches pachyderm to the destination once verified
+func (r *PachydermStorageMigrationReconciler) checkMigrationStatus(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration) error {
+	job := &batchv1.Job{}
+	jobKey := types.NamespacedName{
+		Name:      migrationJobName(migration),
+		Namespace: migration.Namespace,
+	}
+	if err := r.Get(ctx, jobKey, job); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("%w: migration job %s not found", ErrMigrationFailed, jobKey.Name)
+		}
+		return err
+	}
+
+	if failed, message := isJobFailed(job); failed {
+		return fmt.Errorf("%w: migration job %s failed: %s", ErrMigrationFailed, job.Name, message)
+	}
+
+	pod, err := jobPod(ctx, r.Client, job)
+	if err != nil || pod == nil {
+		return err
+	}
+
+	if job.Status.Succeeded == 0 {
+		if isCopyCompleted(pod) {
+			migration.Status.Phase = aimlv1beta1.MigrationVerifyingStatus
+			migration.Status.Status = fmt.Sprintf("verifying objects in %s", migration.Status.Destination)
+		}
+		return nil
+	}
+
+	result, err := migrationResult(pod)
+	if err != nil {
+		return err
+	}
+	migration.Status.SourceStats = &aimlv1beta1.StorageMigrationStats{
+		Objects: result.Source.Count,
+		Bytes:   result.Source.Bytes,
+	}
+	migration.Status.DestinationStats = &aimlv1beta1.StorageMigrationStats{
+		Objects: result.Destination.Count,
+		Bytes:   result.Destination.Bytes,
+	}
+
+	// objects already present in the destination are kept
+	if result.Destination.Count < result.Source.Count {
+		return fmt.Errorf("%w: %d objects copied to %s, %d expected",
+			ErrMigrationFailed, result.Destination.Count, migration.Status.Destination, result.Source.Count)
+	}
+	migration.Status.Verified = true
+
+	return r.completeMigration(ctx, migration)
+}
+
+// completeMigration points the pachyderm cluster
+// at the destination storage and resumes it
+func (r *PachydermStorageMigrationReconciler) completeMigration(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration) error {
+	pd, err := r.migrationTarget(ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	pd.Spec.Pachd.Storage = *migration.Spec.Destination.DeepCopy()
+	if pd.PausedByMigration() == migration.Name {
+		delete(pd.Annotations, aimlv1beta1.PachydermPauseAnnotation)
+		delete(pd.Annotations, aimlv1beta1.PachydermPausedByAnnotation)
+	}
+	if err := r.Update(ctx, pd); err != nil {
+		return err
+	}
+
+	if err := r.deleteMigrationJobSecret(ctx, migration); err != nil {
+		return err
+	}
+
+	migration.Status.Phase = aimlv1beta1.MigrationCompletedStatus
+	migration.Status.CompletedAt = time.Now().UTC().String()
+	migration.Status.Status = fmt.Sprintf("pachyderm %s stores objects in %s", pd.Name, migration.Status.Destination)
+
+	return nil
+}
+
+// abortMigration marks the migration failed and resumes the pachyderm
+// cluster on its original storage if it was paused by the migration
+func (r *PachydermStorageMigrationReconciler) abortMigration(ctx context.Context, migration, current *aimlv1beta1.PachydermStorageMigration, reason error) (ctrl.Result, error) {
+	log.FromContext(ctx).Info("pachyderm storage migration failed", "migration", migration.Name, "reason", reason.Error())
+
+	migration.Status.Phase = aimlv1beta1.MigrationFailedStatus
+	migration.Status.Status = reason.Error()
+	migration.Status.CompletedAt = time.Now().UTC().String()
+
+	if err := r.stopMigrationJob(ctx, migration); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.deleteMigrationJobSecret(ctx, migration); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.resumePachyderm(ctx, migration); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Status().Patch(ctx, migration, client.MergeFrom(current)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// resumePachyderm removes the pause annotation from
+// the pachyderm cluster if it was paused by the migration
+func (r *PachydermStorageMigrationReconciler) resumePachyderm(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration) error {
+	pd := &aimlv1beta1.Pachyderm{}
+	pdKey := types.NamespacedName{
+		Namespace: migration.Namespace,
+		Name:      migration.Spec.Target,
+	}
+	if err := r.Get(ctx, pdKey, pd); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if pd.PausedByMigration() != migration.Name {
+		return nil
+	}
+
+	delete(pd.Annotations, aimlv1beta1.PachydermPauseAnnotation)
+	delete(pd.Annotations, aimlv1beta1.PachydermPausedByAnnotation)
+	return r.Update(ctx, pd)
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+func TestStorageMigrationPhases(t *testing.T) {
+	tests := []struct {
+		name string
+		// termination message of the verify container
+		result string
+		// phase once the migration job succeeded
+		expected string
+		// backend of pachyderm once the migration finished
+		backend string
+	}{
+		{
+			name:     "objects copied",
+			result:   `{"source":{"count":3,"bytes":1024},"destination":{"count":3,"bytes":1024}}`,
+			expected: aimlv1beta1.MigrationCompletedStatus,
+			backend:  aimlv1beta1.MinioStorageBackend,
+		},
+		{
+			name:     "objects missing in the destination",
+			result:   `{"source":{"count":3,"bytes":1024},"destination":{"count":2,"bytes":512}}`,
+			expected: aimlv1beta1.MigrationFailedStatus,
+			backend:  aimlv1beta1.LocalStorageBackend,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := &aimlv1beta1.Pachyderm{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachyderm",
+					Namespace: "default",
+				},
+				Spec: aimlv1beta1.PachydermSpec{
+					Pachd: aimlv1beta1.PachdOptions{
+						Storage: aimlv1beta1.ObjectStorageOptions{
+							Backend: aimlv1beta1.LocalStorageBackend,
+						},
+					},
+				},
+				Status: aimlv1beta1.PachydermStatus{
+					Phase: aimlv1beta1.PhaseRunning,
+				},
+			}
+			migration := &aimlv1beta1.PachydermStorageMigration{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "to-minio",
+					Namespace: pd.Namespace,
+				},
+				Spec: aimlv1beta1.PachydermStorageMigrationSpec{
+					Target: pd.Name,
+					Destination: aimlv1beta1.ObjectStorageOptions{
+						Backend: aimlv1beta1.MinioStorageBackend,
+						Minio: &aimlv1beta1.MinioStorageOptions{
+							Bucket:   "pachyderm",
+							Endpoint: "minio.default.svc:9000",
+							ID:       "access",
+							Secret:   "secret",
+						},
+					},
+					Image: "docker.io/rclone/rclone:1.59.2",
+				},
+			}
+			replicas := int32(1)
+			pachd := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachd",
+					Namespace: pd.Namespace,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+				},
+				Status: appsv1.DeploymentStatus{
+					Replicas: replicas,
+				},
+			}
+
+			c, scheme := newFakeClient(t, pd, migration, pachd)
+			r := &PachydermStorageMigrationReconciler{
+				Client: c,
+				Scheme: scheme,
+			}
+
+			ctx := context.Background()
+			reconcile := func(expected string) {
+				t.Helper()
+
+				req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(migration)}
+				if _, err := r.Reconcile(ctx, req); err != nil {
+					t.Fatal(err)
+				}
+				if err := c.Get(ctx, req.NamespacedName, migration); err != nil {
+					t.Fatal(err)
+				}
+				if migration.Status.Phase != expected {
+					t.Fatalf("expected phase %q, got %q: %s", expected, migration.Status.Phase, migration.Status.Status)
+				}
+			}
+
+			reconcile(aimlv1beta1.MigrationPausingStatus)
+			if err := c.Get(ctx, client.ObjectKeyFromObject(pd), pd); err != nil {
+				t.Fatal(err)
+			}
+			if pd.PausedByMigration() != migration.Name {
+				t.Fatalf("expected pachyderm paused by %s, got %q", migration.Name, pd.PausedBy())
+			}
+
+			// the copy only starts once pachd has stopped
+			reconcile(aimlv1beta1.MigrationPausingStatus)
+			replicas = 0
+			pachd.Spec.Replicas = &replicas
+			pachd.Status.Replicas = 0
+			if err := c.Update(ctx, pachd); err != nil {
+				t.Fatal(err)
+			}
+			reconcile(aimlv1beta1.MigrationCopyingStatus)
+
+			job := &batchv1.Job{}
+			jobKey := client.ObjectKey{Name: migrationJobName(migration), Namespace: migration.Namespace}
+			if err := c.Get(ctx, jobKey, job); err != nil {
+				t.Fatal(err)
+			}
+			if image := job.Spec.Template.Spec.Containers[0].Image; image != migration.Spec.Image {
+				t.Fatalf("expected image %s, got %s", migration.Spec.Image, image)
+			}
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      job.Name + "-pod",
+					Namespace: job.Namespace,
+					Labels:    map[string]string{"job-name": job.Name},
+				},
+				Status: corev1.PodStatus{
+					InitContainerStatuses: []corev1.ContainerStatus{
+						{
+							Name:  "copy",
+							State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+						},
+					},
+				},
+			}
+			if err := c.Create(ctx, pod); err != nil {
+				t.Fatal(err)
+			}
+			reconcile(aimlv1beta1.MigrationCopyingStatus)
+
+			pod.Status.InitContainerStatuses[0].State = corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{},
+			}
+			if err := c.Update(ctx, pod); err != nil {
+				t.Fatal(err)
+			}
+			reconcile(aimlv1beta1.MigrationVerifyingStatus)
+
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{
+					Name: "verify",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{Message: test.result},
+					},
+				},
+			}
+			if err := c.Update(ctx, pod); err != nil {
+				t.Fatal(err)
+			}
+			job.Status.Succeeded = 1
+			if err := c.Status().Update(ctx, job); err != nil {
+				t.Fatal(err)
+			}
+			reconcile(test.expected)
+
+			if err := c.Get(ctx, client.ObjectKeyFromObject(pd), pd); err != nil {
+				t.Fatal(err)
+			}
+			if pd.Spec.Pachd.Storage.Backend != test.backend {
+				t.Errorf("expected pachyderm to use the %s backend, got %s", test.backend, pd.Spec.Pachd.Storage.Backend)
+			}
+			if pd.PausedBy() != "" {
+				t.Errorf("expected pachyderm resumed, paused by %s", pd.PausedBy())
+			}
+
+			secretKey := client.ObjectKey{Name: migrationJobName(migration), Namespace: migration.Namespace}
+			if err := c.Get(ctx, secretKey, &corev1.Secret{}); !errors.IsNotFound(err) {
+				t.Errorf("expected the migration secret deleted, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+// label used by the postgres operator to find the secret of a database user
+const postgresUserLabel string = "postgres-operator.crunchydata.com/pguser"
+
+// deployPostgresCluster creates or updates the PostgresCluster running
+// a highly available embedded database. The passwords generated by the
+// chart are handed to the postgres operator so pachd and pg-bouncer
+// keep reading them from the postgres secret
+func (r *PachydermReconciler) deployPostgresCluster(ctx context.Context, components *generators.PachydermCluster) error {
+	pd := components.Pachyderm()
+
+	if err := r.reconcilePostgresUserSecrets(ctx, pd); err != nil {
+		return err
+	}
+
+	cluster := components.PostgresCluster()
+	if err := controllerutil.SetControllerReference(pd, cluster, r.Scheme); err != nil {
+		return err
+	}
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(generators.PostgresClusterGVK)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(cluster), current); err != nil {
+		if meta.IsNoMatchError(err) {
+			return ErrPostgresOperatorMissing
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		return r.Create(ctx, cluster)
+	}
+
+	// only replace the fields set by the pachyderm operator,
+	// keeping the defaults applied by the postgres operator
+	spec, _, err := unstructured.NestedMap(current.Object, "spec")
+	if err != nil {
+		return err
+	}
+	desired, _, _ := unstructured.NestedMap(cluster.Object, "spec")
+
+	changed := false
+	for key, value := range desired {
+		if !reflect.DeepEqual(spec[key], value) {
+			spec[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := unstructured.SetNestedMap(current.Object, spec, "spec"); err != nil {
+		return err
+	}
+	return r.Update(ctx, current)
+}
+
+// reconcilePostgresUserSecrets copies the passwords of the pachd
+// and postgres users from the postgres secret to the secrets
+// the postgres operator sets the role passwords from
+func (r *PachydermReconciler) reconcilePostgresUserSecrets(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      postgresSecretName,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		return err
+	}
+
+	users := map[string]string{
+		"postgres": "postgresql-postgres-password",
+	}
+	// the pachd user is the superuser when both have the same
+	// name. The password belongs to the rotation role while
+	// pachd logs in as it, which is not managed by the operator
+	if pd.PostgresLoginUser() == pd.Spec.Pachd.Postgres.User {
+		users[pd.Spec.Pachd.Postgres.User] = postgresPasswordKey
+	}
+
+	for user, key := range users {
+		password, ok := secret.Data[key]
+		if !ok {
+			continue
+		}
+		if err := r.setPostgresUserPassword(ctx, pd, user, password); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setPostgresUserPassword writes the password of a database user to the
+// secret read by the postgres operator. The verifier is removed so the
+// postgres operator derives it from the new password and alters the role
+func (r *PachydermReconciler) setPostgresUserPassword(ctx context.Context, pd *aimlv1beta1.Pachyderm, user string, password []byte) error {
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      generators.PostgresUserSecretName(user),
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		// not owned by the pachyderm resource,
+		// the postgres operator adopts the secret
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretKey.Name,
+				Namespace: secretKey.Namespace,
+				Labels: map[string]string{
+					generators.PostgresClusterLabel: generators.PostgresClusterName,
+					postgresUserLabel:               user,
+				},
+			},
+			Data: map[string][]byte{
+				"password": password,
+			},
+		}
+		return r.Create(ctx, secret)
+	}
+
+	if bytes.Equal(secret.Data["password"], password) {
+		return nil
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data["password"] = password
+	delete(secret.Data, "verifier")
+
+	return r.Update(ctx, secret)
+}
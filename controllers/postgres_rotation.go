@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/lib/pq"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	// secret rendered by the pachyderm chart with the database passwords
+	postgresSecretName string = "postgres"
+	// key of the password of the pachd database user read by pachd and pg-bouncer
+	postgresPasswordKey string = "postgresql-password"
+	// key of the database role the password belongs to. It is
+	// missing until the first rotation, while the user of the
+	// pachyderm resource is used
+	postgresUsernameKey string = "postgresql-username"
+	// key holding a new password until the database role is altered.
+	// A failed rotation is resumed with the same password
+	postgresPendingPasswordKey string = "postgresql-password-pending"
+	postgresPasswordLength     int    = 32
+	postgresPasswordCharacters string = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// suffix of the role alternating with the pachd
+	// database user when the password is rotated
+	postgresRotationRoleSuffix string = "_rotation"
+)
+
+// rotatePostgresPassword replaces the password of the pachd database
+// user when the rotation interval elapsed or a rotation is requested.
+// A role has a single password, so pachd alternates between the user
+// and a rotation role that is a member of the user. The new password
+// is set on the role not in use, then the postgres secret switches
+// pachd and pg-bouncer to it in a single update. Pods started before
+// the rotation keep logging in with the previous role and password
+// until the rolling update replaces them. A rotation only starts once
+// the previous one finished rolling out
+func (r *PachydermReconciler) rotatePostgresPassword(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	if !postgresRotationDue(pd) {
+		return nil
+	}
+
+	// pods of the previous rollout may still use the standby role
+	if rolledOut, err := r.postgresClientsRolledOut(ctx, pd); err != nil || !rolledOut {
+		return err
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      postgresSecretName,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		return err
+	}
+
+	password, ok := secret.Data[postgresPendingPasswordKey]
+	if !ok {
+		generated, err := generatePassword(postgresPasswordLength)
+		if err != nil {
+			return err
+		}
+		password = []byte(generated)
+
+		secret.Data[postgresPendingPasswordKey] = password
+		if err := r.Update(ctx, secret); err != nil {
+			return err
+		}
+	}
+
+	user := standbyPostgresUser(pd)
+
+	// the postgres operator would restore the previous password
+	// of the user it manages. The rotation role is not managed
+	if pd.IsPostgresHA() && user == pd.Spec.Pachd.Postgres.User {
+		if err := r.setPostgresUserPassword(ctx, pd, user, password); err != nil {
+			return err
+		}
+	}
+
+	setRolePassword := r.alterPostgresPassword
+	if r.setRolePassword != nil {
+		setRolePassword = r.setRolePassword
+	}
+	if err := setRolePassword(ctx, pd, user, string(password)); err != nil {
+		return err
+	}
+
+	if err := r.updatePasswordSecret(ctx, pd, password); err != nil {
+		return err
+	}
+
+	secret.Data[postgresPasswordKey] = password
+	secret.Data[postgresUsernameKey] = []byte(user)
+	delete(secret.Data, postgresPendingPasswordKey)
+	if err := r.Update(ctx, secret); err != nil {
+		return err
+	}
+
+	current := pd.DeepCopy()
+	rotatedAt := metav1.Now()
+	pd.Status.PostgresPasswordRotatedAt = &rotatedAt
+	if err := r.Status().Patch(ctx, pd, client.MergeFrom(current)); err != nil {
+		return err
+	}
+
+	if _, ok := pd.Annotations[aimlv1beta1.RotatePostgresPasswordAnnotation]; ok {
+		current := pd.DeepCopy()
+		delete(pd.Annotations, aimlv1beta1.RotatePostgresPasswordAnnotation)
+		if err := r.Patch(ctx, pd, client.MergeFrom(current)); err != nil {
+			return err
+		}
+	}
+
+	// patches decode the stored resource, dropping runtime values
+	pd.Spec.Pachd.Postgres.LoginUser = user
+	if pd.Spec.Pachd.Postgres.PasswordSecretName != "" {
+		pd.Spec.Pachd.Postgres.Password = string(password)
+	}
+
+	log.FromContext(ctx).Info("rotated postgres password", "pachyderm", pd.Name, "user", user)
+	return nil
+}
+
+// standbyPostgresUser returns the database
+// role pachd is switched to by the next rotation
+func standbyPostgresUser(pd *aimlv1beta1.Pachyderm) string {
+	user := pd.Spec.Pachd.Postgres.User
+	if pd.PostgresLoginUser() == user {
+		return user + postgresRotationRoleSuffix
+	}
+	return user
+}
+
+// postgresLoginUser reads the database role pachd
+// logs in as from the postgres secret
+func postgresLoginUser(ctx context.Context, c client.Reader, pd *aimlv1beta1.Pachyderm) error {
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      postgresSecretName,
+		Namespace: pd.Namespace,
+	}
+	if err := c.Get(ctx, secretKey, secret); err != nil {
+		// rendered by the chart on the first reconcile
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	pd.Spec.Pachd.Postgres.LoginUser = string(secret.Data[postgresUsernameKey])
+	return nil
+}
+
+// postgresClientsRolledOut returns true when every pod of
+// the pachd and pg-bouncer deployments runs the latest template
+func (r *PachydermReconciler) postgresClientsRolledOut(ctx context.Context, pd *aimlv1beta1.Pachyderm) (bool, error) {
+	for _, name := range []string{"pachd", generators.PGBouncerName} {
+		deployment := &appsv1.Deployment{}
+		deploymentKey := types.NamespacedName{
+			Name:      name,
+			Namespace: pd.Namespace,
+		}
+		if err := r.Get(ctx, deploymentKey, deployment); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		status := deployment.Status
+		if status.ObservedGeneration < deployment.Generation ||
+			status.UpdatedReplicas < replicas ||
+			status.Replicas > status.UpdatedReplicas {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// alterPostgresPassword sets the password of a database role
+// using the postgres admin credentials. The rotation role is
+// created as a member of the pachd database user, and objects
+// it creates are owned by the user
+func (r *PachydermReconciler) alterPostgresPassword(ctx context.Context, pd *aimlv1beta1.Pachyderm, user, password string) error {
+	adminPassword, err := r.getPostgresAdminPassword(ctx, pd)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("postgres", postgresDataSource(pd, "postgres", adminPassword))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	queries := []string{}
+	if owner := pd.Spec.Pachd.Postgres.User; user != owner {
+		var exists bool
+		if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", user).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			queries = append(queries, fmt.Sprintf("CREATE ROLE %s LOGIN INHERIT IN ROLE %s",
+				pq.QuoteIdentifier(user),
+				pq.QuoteIdentifier(owner),
+			))
+		}
+		queries = append(queries, fmt.Sprintf("ALTER ROLE %s SET role TO %s",
+			pq.QuoteIdentifier(user),
+			pq.QuoteLiteral(owner),
+		))
+	}
+	// ALTER ROLE does not accept bind parameters
+	queries = append(queries, fmt.Sprintf("ALTER ROLE %s WITH PASSWORD %s",
+		pq.QuoteIdentifier(user),
+		pq.QuoteLiteral(password),
+	))
+
+	for _, query := range queries {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updatePasswordSecret stores the new password in the
+// password secret referenced by the pachyderm resource
+func (r *PachydermReconciler) updatePasswordSecret(ctx context.Context, pd *aimlv1beta1.Pachyderm, password []byte) error {
+	name := pd.Spec.Pachd.Postgres.PasswordSecretName
+	if name == "" {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      name,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		return err
+	}
+
+	key := "postgres-password"
+	if _, ok := secret.Data[key]; !ok {
+		if _, ok := secret.Data["postgresql-password"]; ok {
+			key = "postgresql-password"
+		}
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[key] = password
+
+	return r.Update(ctx, secret)
+}
+
+// postgresRotationDue returns true if the password of the pachd
+// database user was requested or is scheduled to be rotated
+func postgresRotationDue(pd *aimlv1beta1.Pachyderm) bool {
+	if pd.IsDeleted() || pd.VerifyDatabaseOnly() || pd.Status.Phase != aimlv1beta1.PhaseRunning {
+		return false
+	}
+
+	if _, ok := pd.Annotations[aimlv1beta1.RotatePostgresPasswordAnnotation]; ok {
+		return true
+	}
+
+	rotateAfter, ok := postgresRotateAfter(pd)
+	return ok && rotateAfter <= 0
+}
+
+// postgresRotateAfter returns the time until the password
+// of the pachd database user is rotated by the rotation interval
+func postgresRotateAfter(pd *aimlv1beta1.Pachyderm) (time.Duration, bool) {
+	rotation := pd.Spec.Pachd.Postgres.PasswordRotation
+	if rotation == nil || rotation.Interval == nil || rotation.Interval.Duration <= 0 ||
+		pd.IsDeleted() || pd.VerifyDatabaseOnly() {
+		return 0, false
+	}
+
+	rotatedAt := pd.CreationTimestamp.Time
+	if pd.Status.PostgresPasswordRotatedAt != nil {
+		rotatedAt = pd.Status.PostgresPasswordRotatedAt.Time
+	}
+
+	return time.Until(rotatedAt.Add(rotation.Interval.Duration)), true
+}
+
+func generatePassword(length int) (string, error) {
+	max := big.NewInt(int64(len(postgresPasswordCharacters)))
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = postgresPasswordCharacters[n.Int64()]
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+func TestRotatePostgresPassword(t *testing.T) {
+	errAlter := errors.New("connection refused")
+
+	tests := []struct {
+		name string
+		// data of the postgres secret
+		data map[string][]byte
+		// replicas of pachd not running the latest template
+		outdated int32
+		alterErr error
+		// role and password expected to be altered
+		expectedUser     string
+		expectedPassword string
+		rotated          bool
+	}{
+		{
+			name: "resume pending password",
+			data: map[string][]byte{
+				postgresPasswordKey:        []byte("current"),
+				postgresPendingPasswordKey: []byte("pending"),
+			},
+			expectedUser:     "pachyderm_rotation",
+			expectedPassword: "pending",
+			rotated:          true,
+		},
+		{
+			name: "switch back to the user",
+			data: map[string][]byte{
+				postgresPasswordKey:        []byte("current"),
+				postgresUsernameKey:        []byte("pachyderm_rotation"),
+				postgresPendingPasswordKey: []byte("pending"),
+			},
+			expectedUser:     "pachyderm",
+			expectedPassword: "pending",
+			rotated:          true,
+		},
+		{
+			name: "keep pending password when the role is not altered",
+			data: map[string][]byte{
+				postgresPasswordKey:        []byte("current"),
+				postgresPendingPasswordKey: []byte("pending"),
+			},
+			alterErr:         errAlter,
+			expectedUser:     "pachyderm_rotation",
+			expectedPassword: "pending",
+		},
+		{
+			name: "wait for the previous rollout",
+			data: map[string][]byte{
+				postgresPasswordKey:        []byte("current"),
+				postgresPendingPasswordKey: []byte("pending"),
+			},
+			outdated: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := &aimlv1beta1.Pachyderm{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachyderm",
+					Namespace: "default",
+					Annotations: map[string]string{
+						aimlv1beta1.RotatePostgresPasswordAnnotation: "",
+					},
+				},
+				Spec: aimlv1beta1.PachydermSpec{
+					Pachd: aimlv1beta1.PachdOptions{
+						Postgres: aimlv1beta1.PachdPostgresConfig{
+							User: "pachyderm",
+						},
+					},
+				},
+				Status: aimlv1beta1.PachydermStatus{
+					Phase: aimlv1beta1.PhaseRunning,
+				},
+			}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      postgresSecretName,
+					Namespace: pd.Namespace,
+				},
+				Data: test.data,
+			}
+			replicas := int32(2)
+			pachd := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachd",
+					Namespace: pd.Namespace,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+				},
+				Status: appsv1.DeploymentStatus{
+					Replicas:        replicas,
+					UpdatedReplicas: replicas - test.outdated,
+				},
+			}
+
+			c, scheme := newFakeClient(t, pd, secret, pachd)
+			var alteredUser, alteredPassword string
+			r := &PachydermReconciler{
+				Client: c,
+				Scheme: scheme,
+				setRolePassword: func(ctx context.Context, pd *aimlv1beta1.Pachyderm, user, password string) error {
+					alteredUser, alteredPassword = user, password
+					return test.alterErr
+				},
+			}
+
+			ctx := context.Background()
+			if err := postgresLoginUser(ctx, c, pd); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.rotatePostgresPassword(ctx, pd); !errors.Is(err, test.alterErr) {
+				t.Fatalf("expected error %v, got %v", test.alterErr, err)
+			}
+
+			if alteredUser != test.expectedUser || alteredPassword != test.expectedPassword {
+				t.Fatalf("expected role %q altered with %q, got role %q altered with %q",
+					test.expectedUser, test.expectedPassword, alteredUser, alteredPassword)
+			}
+
+			current := &corev1.Secret{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(secret), current); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := test.data
+			if test.rotated {
+				expected = map[string][]byte{
+					postgresPasswordKey: []byte(test.expectedPassword),
+					postgresUsernameKey: []byte(test.expectedUser),
+				}
+			}
+			for _, key := range []string{postgresPasswordKey, postgresUsernameKey, postgresPendingPasswordKey} {
+				if string(current.Data[key]) != string(expected[key]) {
+					t.Errorf("expected %s to be %q, got %q", key, expected[key], current.Data[key])
+				}
+			}
+
+			if test.rotated && pd.PostgresLoginUser() != test.expectedUser {
+				t.Errorf("expected pachd to log in as %s, got %s", test.expectedUser, pd.PostgresLoginUser())
+			}
+
+			rotated := &aimlv1beta1.Pachyderm{}
+			if err := c.Get(ctx, types.NamespacedName{Name: pd.Name, Namespace: pd.Namespace}, rotated); err != nil {
+				t.Fatal(err)
+			}
+			if _, requested := rotated.Annotations[aimlv1beta1.RotatePostgresPasswordAnnotation]; requested == test.rotated {
+				t.Errorf("expected rotation request cleared %t", test.rotated)
+			}
+			if (rotated.Status.PostgresPasswordRotatedAt != nil) != test.rotated {
+				t.Errorf("expected rotation time set %t", test.rotated)
+			}
+		})
+	}
+}
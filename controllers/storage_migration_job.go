@@ -0,0 +1,508 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	// names of the rclone remotes configured in the migration job
+	migrationSourceRemote      string = "SOURCE"
+	migrationDestinationRemote string = "DESTINATION"
+	// paths the local storage volumes are mounted at
+	migrationSourceMountPath      string = "/source"
+	migrationDestinationMountPath string = "/destination"
+)
+
+// migrationScriptPreamble writes the certificate authorities
+// trusted by both remotes to a file read by rclone
+const migrationScriptPreamble = `set -eu
+if [ -n "${MIGRATION_CA_BUNDLE:-}" ]; then
+  printf '%s\n' "${MIGRATION_CA_BUNDLE}" > /tmp/ca.crt
+  export RCLONE_CA_CERT=/tmp/ca.crt
+fi
+`
+
+// migrationCopyScript copies every object missing
+// or different in the destination bucket
+const migrationCopyScript = migrationScriptPreamble + `
+echo "copying objects from ${SOURCE_LOCATION} to ${DESTINATION_LOCATION}"
+rclone copy "source:${SOURCE_PATH}" "destination:${DESTINATION_PATH}" --stats 30s --stats-one-line -v
+echo "copy completed"
+`
+
+// migrationVerifyScript checks every object of the source bucket
+// exists in the destination bucket and reports the object counts
+// of both buckets in the termination message of the container
+const migrationVerifyScript = migrationScriptPreamble + `
+echo "verifying objects in ${DESTINATION_LOCATION}"
+rclone check "source:${SOURCE_PATH}" "destination:${DESTINATION_PATH}" --one-way ${CHECK_FLAGS:-}
+source=$(rclone size --json "source:${SOURCE_PATH}")
+destination=$(rclone size --json "destination:${DESTINATION_PATH}")
+printf '{"source":%s,"destination":%s}' "${source}" "${destination}" > /dev/termination-log
+echo "verification completed"
+`
+
+// migrationRemote describes an object store as an rclone remote
+type migrationRemote struct {
+	// rclone options of the remote, keyed by option name
+	options map[string]string
+	// path of the bucket in the remote
+	path string
+	// location of the bucket reported in the status
+	location string
+	// volume holding the objects of a local storage backend
+	volume *corev1.VolumeSource
+	// certificate authorities trusted by the remote
+	caBundle []byte
+	// skip verification of the server certificate
+	insecure bool
+}
+
+// migrationJobResult is written by the verify container
+type migrationJobResult struct {
+	Source      migrationBucketSize `json:"source"`
+	Destination migrationBucketSize `json:"destination"`
+}
+
+// migrationBucketSize is the output of rclone size --json
+type migrationBucketSize struct {
+	Count int64 `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+func migrationJobName(migration *aimlv1beta1.PachydermStorageMigration) string {
+	return fmt.Sprintf("%s-migration", migration.Name)
+}
+
+// s3Endpoint adds the scheme expected by rclone to the endpoint
+func s3Endpoint(endpoint string, secure bool) string {
+	if endpoint == "" || strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	if secure {
+		return fmt.Sprintf("https://%s", endpoint)
+	}
+	return fmt.Sprintf("http://%s", endpoint)
+}
+
+// migrationImage returns the rclone image of the migration job.
+// Unless set in the migration, it is the rclone image shipped
+// with the version of pachyderm deployed
+func migrationImage(migration *aimlv1beta1.PachydermStorageMigration, pd *aimlv1beta1.Pachyderm) (string, error) {
+	if migration.Spec.Image != "" {
+		return migration.Spec.Image, nil
+	}
+
+	image, err := generators.RcloneImage(pd)
+	if err != nil {
+		return "", err
+	}
+	if image == nil {
+		return "", fmt.Errorf("%w: no rclone image is shipped with pachyderm %s, set the image of the migration",
+			ErrMigrationFailed, pd.Spec.Version)
+	}
+
+	return image.Name(), nil
+}
+
+// storageRemote returns the rclone remote for the storage options
+// of pd. Credentials must have been resolved by storageCredentials
+func storageRemote(pd *aimlv1beta1.Pachyderm, mountPath string) (*migrationRemote, error) {
+	storage := pd.Spec.Pachd.Storage
+
+	switch storage.Backend {
+	case aimlv1beta1.AmazonStorageBackend:
+		if storage.Amazon == nil {
+			break
+		}
+		if pd.IsUsingAmazonIAMRole() || pd.IsUsingVault() {
+			return nil, fmt.Errorf("%w: the %s backend must use credentials from a secret", ErrMigrationFailed, storage.Backend)
+		}
+		remote := &migrationRemote{
+			options: map[string]string{
+				"TYPE":              "s3",
+				"PROVIDER":          "AWS",
+				"ACCESS_KEY_ID":     storage.Amazon.ID,
+				"SECRET_ACCESS_KEY": storage.Amazon.Secret,
+				"REGION":            storage.Amazon.Region,
+			},
+			path:     storage.Amazon.Bucket,
+			location: fmt.Sprintf("s3://%s", storage.Amazon.Bucket),
+			insecure: !storage.Amazon.IsVerifyingSSL(),
+		}
+		if storage.Amazon.Token != "" {
+			remote.options["SESSION_TOKEN"] = storage.Amazon.Token
+		}
+		if storage.Amazon.CustomEndpoint != "" {
+			remote.options["PROVIDER"] = "Other"
+			remote.options["ENDPOINT"] = s3Endpoint(storage.Amazon.CustomEndpoint, !storage.Amazon.DisableSSL)
+		}
+		return remote, nil
+	case aimlv1beta1.MinioStorageBackend:
+		if storage.Minio == nil {
+			break
+		}
+		return &migrationRemote{
+			options: map[string]string{
+				"TYPE":              "s3",
+				"PROVIDER":          "Minio",
+				"ACCESS_KEY_ID":     storage.Minio.ID,
+				"SECRET_ACCESS_KEY": storage.Minio.Secret,
+				"REGION":            "us-east-1",
+				"ENDPOINT":          s3Endpoint(storage.Minio.Endpoint, storage.Minio.IsSecure()),
+			},
+			path:     storage.Minio.Bucket,
+			location: fmt.Sprintf("s3://%s/%s", storage.Minio.Endpoint, storage.Minio.Bucket),
+			caBundle: storage.Minio.CABundleData,
+		}, nil
+	case aimlv1beta1.CustomStorageBackend:
+		if storage.Custom == nil {
+			break
+		}
+		return &migrationRemote{
+			options: map[string]string{
+				"TYPE":              "s3",
+				"PROVIDER":          "Other",
+				"ACCESS_KEY_ID":     storage.Custom.ID,
+				"SECRET_ACCESS_KEY": storage.Custom.Secret,
+				"REGION":            storage.Custom.Region,
+				"ENDPOINT":          s3Endpoint(storage.Custom.Endpoint, !storage.Custom.DisableSSL),
+			},
+			path:     storage.Custom.Bucket,
+			location: fmt.Sprintf("s3://%s/%s", storage.Custom.Endpoint, storage.Custom.Bucket),
+			caBundle: storage.Custom.CABundleData,
+			insecure: storage.Custom.InsecureSkipVerify,
+		}, nil
+	case aimlv1beta1.GoogleStorageBackend:
+		if storage.Google == nil {
+			break
+		}
+		if pd.IsUsingGCSWorkloadIdentity() {
+			return nil, fmt.Errorf("%w: the %s backend must use credentials from a secret", ErrMigrationFailed, storage.Backend)
+		}
+		return &migrationRemote{
+			options: map[string]string{
+				"TYPE":                        "google cloud storage",
+				"SERVICE_ACCOUNT_CREDENTIALS": string(storage.Google.CredentialsData),
+				"BUCKET_POLICY_ONLY":          "true",
+			},
+			path:     storage.Google.Bucket,
+			location: fmt.Sprintf("gs://%s", storage.Google.Bucket),
+		}, nil
+	case aimlv1beta1.MicrosoftStorageBackend:
+		if storage.Microsoft == nil {
+			break
+		}
+		if pd.IsUsingAzureWorkloadIdentity() {
+			return nil, fmt.Errorf("%w: the %s backend must use credentials from a secret", ErrMigrationFailed, storage.Backend)
+		}
+		return &migrationRemote{
+			options: map[string]string{
+				"TYPE":    "azureblob",
+				"ACCOUNT": storage.Microsoft.ID,
+				"KEY":     storage.Microsoft.Secret,
+			},
+			path:     storage.Microsoft.Container,
+			location: fmt.Sprintf("https://%s.blob.core.windows.net/%s", storage.Microsoft.ID, storage.Microsoft.Container),
+		}, nil
+	case aimlv1beta1.LocalStorageBackend:
+		local := storage.Local
+		if local == nil {
+			local = &aimlv1beta1.LocalStorageOptions{}
+		}
+		hostPath := local.HostPath
+		if hostPath == "" {
+			hostPath = "/var/pachyderm/"
+		}
+
+		// the chart stores pachd objects under the pachd directory
+		remote := &migrationRemote{
+			options: map[string]string{
+				"TYPE": "local",
+			},
+			path:     mountPath,
+			location: fmt.Sprintf("file://%spachd", hostPath),
+			volume: &corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: fmt.Sprintf("%spachd", hostPath),
+				},
+			},
+		}
+		if local.ClaimName != "" {
+			remote.location = fmt.Sprintf("pvc://%s", local.ClaimName)
+			remote.volume = &corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: local.ClaimName,
+				},
+			}
+		}
+		return remote, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s storage options not set", ErrMigrationFailed, storage.Backend)
+}
+
+// migrationJobConfig returns the contents of the secret
+// holding the environment of the migration job
+func migrationJobConfig(migration *aimlv1beta1.PachydermStorageMigration, source, destination *migrationRemote) map[string]string {
+	config := map[string]string{
+		"SOURCE_PATH":          source.path,
+		"SOURCE_LOCATION":      source.location,
+		"DESTINATION_PATH":     destination.path,
+		"DESTINATION_LOCATION": destination.location,
+		// remotes are configured from the environment only
+		"RCLONE_CONFIG": "/tmp/rclone.conf",
+	}
+
+	for name, remote := range map[string]*migrationRemote{
+		migrationSourceRemote:      source,
+		migrationDestinationRemote: destination,
+	} {
+		for option, value := range remote.options {
+			config[fmt.Sprintf("RCLONE_CONFIG_%s_%s", name, option)] = value
+		}
+	}
+
+	bundles := []string{}
+	for _, bundle := range [][]byte{source.caBundle, destination.caBundle} {
+		if len(bundle) > 0 {
+			bundles = append(bundles, strings.TrimSpace(string(bundle)))
+		}
+	}
+	if len(bundles) > 0 {
+		config["MIGRATION_CA_BUNDLE"] = strings.Join(bundles, "\n")
+	}
+
+	if source.insecure || destination.insecure {
+		config["RCLONE_NO_CHECK_CERTIFICATE"] = "true"
+	}
+
+	transfers := migration.Spec.Transfers
+	if transfers < 1 {
+		transfers = 8
+	}
+	config["RCLONE_TRANSFERS"] = fmt.Sprintf("%d", transfers)
+
+	if migration.Spec.Verification == aimlv1beta1.MigrationDownloadVerification {
+		config["CHECK_FLAGS"] = "--download"
+	}
+
+	return config
+}
+
+func newMigrationJob(migration *aimlv1beta1.PachydermStorageMigration, image string, source, destination *migrationRemote) *batchv1.Job {
+	envFrom := []corev1.EnvFromSource{
+		{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: migrationJobName(migration),
+				},
+			},
+		},
+	}
+
+	mounts := []corev1.VolumeMount{}
+	volumes := []corev1.Volume{}
+	for _, local := range []struct {
+		name      string
+		mountPath string
+		remote    *migrationRemote
+	}{
+		{"source", migrationSourceMountPath, source},
+		{"destination", migrationDestinationMountPath, destination},
+	} {
+		if local.remote.volume == nil {
+			continue
+		}
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      local.name,
+			MountPath: local.mountPath,
+		})
+		volumes = append(volumes, corev1.Volume{
+			Name:         local.name,
+			VolumeSource: *local.remote.volume,
+		})
+	}
+
+	labels := map[string]string{
+		"app":   "pachyderm-storage-migration",
+		"suite": "pachyderm",
+	}
+
+	var backoffLimit int32 = 0
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      migrationJobName(migration),
+			Namespace: migration.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					// allowed to mount the host path of local storage
+					ServiceAccountName: pachdServiceAccountName,
+					InitContainers: []corev1.Container{
+						{
+							Name:         "copy",
+							Image:        image,
+							Command:      []string{"sh", "-c", migrationCopyScript},
+							EnvFrom:      envFrom,
+							VolumeMounts: mounts,
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:                     "verify",
+							Image:                    image,
+							Command:                  []string{"sh", "-c", migrationVerifyScript},
+							EnvFrom:                  envFrom,
+							VolumeMounts:             mounts,
+							TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+}
+
+// startMigrationJob creates the migration job and the secret holding
+// its configuration. The existing job is returned if it was already created
+func (r *PachydermStorageMigrationReconciler) startMigrationJob(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration, image string, source, destination *migrationRemote) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+	jobKey := types.NamespacedName{
+		Name:      migrationJobName(migration),
+		Namespace: migration.Namespace,
+	}
+	if err := r.Get(ctx, jobKey, job); err == nil {
+		return job, nil
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      migrationJobName(migration),
+			Namespace: migration.Namespace,
+			Labels: map[string]string{
+				"app":   "pachyderm-storage-migration",
+				"suite": "pachyderm",
+			},
+		},
+		StringData: migrationJobConfig(migration, source, destination),
+	}
+	if err := controllerutil.SetControllerReference(migration, secret, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, secret); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return nil, err
+		}
+
+		// replace the configuration left by an earlier attempt
+		existing := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(secret), existing); err != nil {
+			return nil, err
+		}
+		existing.StringData = secret.StringData
+		if err := r.Update(ctx, existing); err != nil {
+			return nil, err
+		}
+	}
+
+	job = newMigrationJob(migration, image, source, destination)
+	if err := controllerutil.SetControllerReference(migration, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// migrationResult parses the object counts
+// reported by the verify container of the pod
+func migrationResult(pod *corev1.Pod) (*migrationJobResult, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != "verify" || status.State.Terminated == nil {
+			continue
+		}
+
+		result := &migrationJobResult{}
+		if err := json.Unmarshal([]byte(status.State.Terminated.Message), result); err != nil {
+			return nil, fmt.Errorf("%w: unable to read the object counts: %s", ErrMigrationFailed, err.Error())
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("%w: the verify container did not report the object counts", ErrMigrationFailed)
+}
+
+// isCopyCompleted returns true once the copy
+// container of the migration pod has succeeded
+func isCopyCompleted(pod *corev1.Pod) bool {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == "copy" && status.State.Terminated != nil {
+			return status.State.Terminated.ExitCode == 0
+		}
+	}
+	return false
+}
+
+// stopMigrationJob deletes the migration job if it is still running
+func (r *PachydermStorageMigrationReconciler) stopMigrationJob(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration) error {
+	job := &batchv1.Job{}
+	jobKey := types.NamespacedName{
+		Name:      migrationJobName(migration),
+		Namespace: migration.Namespace,
+	}
+	if err := r.Get(ctx, jobKey, job); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if job.Status.Active == 0 {
+		return nil
+	}
+
+	return r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+}
+
+// deleteMigrationJobSecret removes the storage credentials
+// once the migration job no longer needs them
+func (r *PachydermStorageMigrationReconciler) deleteMigrationJobSecret(ctx context.Context, migration *aimlv1beta1.PachydermStorageMigration) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      migrationJobName(migration),
+			Namespace: migration.Namespace,
+		},
+	}
+	if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
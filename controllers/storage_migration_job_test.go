@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+func TestStorageRemote(t *testing.T) {
+	verifySSL := false
+
+	tests := []struct {
+		name     string
+		storage  aimlv1beta1.ObjectStorageOptions
+		options  map[string]string
+		path     string
+		location string
+		insecure bool
+		volume   *corev1.VolumeSource
+		failed   bool
+	}{
+		{
+			name: "amazon",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.AmazonStorageBackend,
+				Amazon: &aimlv1beta1.AmazonStorageOptions{
+					Bucket: "pachyderm",
+					Region: "us-east-2",
+					ID:     "access",
+					Secret: "secret",
+					Token:  "session",
+				},
+			},
+			options: map[string]string{
+				"TYPE":              "s3",
+				"PROVIDER":          "AWS",
+				"ACCESS_KEY_ID":     "access",
+				"SECRET_ACCESS_KEY": "secret",
+				"SESSION_TOKEN":     "session",
+				"REGION":            "us-east-2",
+			},
+			path:     "pachyderm",
+			location: "s3://pachyderm",
+		},
+		{
+			name: "amazon custom endpoint",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.AmazonStorageBackend,
+				Amazon: &aimlv1beta1.AmazonStorageOptions{
+					Bucket:         "pachyderm",
+					Region:         "us-east-2",
+					ID:             "access",
+					Secret:         "secret",
+					CustomEndpoint: "s3.example.com",
+					VerifySSL:      &verifySSL,
+				},
+			},
+			options: map[string]string{
+				"TYPE":              "s3",
+				"PROVIDER":          "Other",
+				"ACCESS_KEY_ID":     "access",
+				"SECRET_ACCESS_KEY": "secret",
+				"REGION":            "us-east-2",
+				"ENDPOINT":          "https://s3.example.com",
+			},
+			path:     "pachyderm",
+			location: "s3://pachyderm",
+			insecure: true,
+		},
+		{
+			name: "minio",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.MinioStorageBackend,
+				Minio: &aimlv1beta1.MinioStorageOptions{
+					Bucket:   "pachyderm",
+					Endpoint: "minio.default.svc:9000",
+					ID:       "access",
+					Secret:   "secret",
+				},
+			},
+			options: map[string]string{
+				"TYPE":              "s3",
+				"PROVIDER":          "Minio",
+				"ACCESS_KEY_ID":     "access",
+				"SECRET_ACCESS_KEY": "secret",
+				"REGION":            "us-east-1",
+				"ENDPOINT":          "http://minio.default.svc:9000",
+			},
+			path:     "pachyderm",
+			location: "s3://minio.default.svc:9000/pachyderm",
+		},
+		{
+			name: "custom",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.CustomStorageBackend,
+				Custom: &aimlv1beta1.CustomStorageOptions{
+					Bucket:             "pachyderm",
+					Endpoint:           "objects.example.com",
+					Region:             "eu",
+					ID:                 "access",
+					Secret:             "secret",
+					InsecureSkipVerify: true,
+				},
+			},
+			options: map[string]string{
+				"TYPE":              "s3",
+				"PROVIDER":          "Other",
+				"ACCESS_KEY_ID":     "access",
+				"SECRET_ACCESS_KEY": "secret",
+				"REGION":            "eu",
+				"ENDPOINT":          "https://objects.example.com",
+			},
+			path:     "pachyderm",
+			location: "s3://objects.example.com/pachyderm",
+			insecure: true,
+		},
+		{
+			name: "google",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.GoogleStorageBackend,
+				Google: &aimlv1beta1.GoogleStorageOptions{
+					Bucket:          "pachyderm",
+					CredentialsData: []byte(`{"type":"service_account"}`),
+				},
+			},
+			options: map[string]string{
+				"TYPE":                        "google cloud storage",
+				"SERVICE_ACCOUNT_CREDENTIALS": `{"type":"service_account"}`,
+				"BUCKET_POLICY_ONLY":          "true",
+			},
+			path:     "pachyderm",
+			location: "gs://pachyderm",
+		},
+		{
+			name: "microsoft",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.MicrosoftStorageBackend,
+				Microsoft: &aimlv1beta1.MicrosoftStorageOptions{
+					Container: "pachyderm",
+					ID:        "account",
+					Secret:    "key",
+				},
+			},
+			options: map[string]string{
+				"TYPE":    "azureblob",
+				"ACCOUNT": "account",
+				"KEY":     "key",
+			},
+			path:     "pachyderm",
+			location: "https://account.blob.core.windows.net/pachyderm",
+		},
+		{
+			name: "local host path",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.LocalStorageBackend,
+			},
+			options:  map[string]string{"TYPE": "local"},
+			path:     migrationSourceMountPath,
+			location: "file:///var/pachyderm/pachd",
+			volume: &corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: "/var/pachyderm/pachd"},
+			},
+		},
+		{
+			name: "local volume claim",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.LocalStorageBackend,
+				Local: &aimlv1beta1.LocalStorageOptions{
+					ClaimName: "pachd-objects",
+				},
+			},
+			options:  map[string]string{"TYPE": "local"},
+			path:     migrationSourceMountPath,
+			location: "pvc://pachd-objects",
+			volume: &corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pachd-objects"},
+			},
+		},
+		{
+			name: "amazon IAM role",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.AmazonStorageBackend,
+				Amazon: &aimlv1beta1.AmazonStorageOptions{
+					Bucket:  "pachyderm",
+					IAMRole: "arn:aws:iam::123456789012:role/pachd",
+				},
+			},
+			failed: true,
+		},
+		{
+			name: "amazon vault credentials",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.AmazonStorageBackend,
+				Amazon: &aimlv1beta1.AmazonStorageOptions{
+					Bucket: "pachyderm",
+					Vault:  &aimlv1beta1.AmazonStorageVault{},
+				},
+			},
+			failed: true,
+		},
+		{
+			name: "google workload identity",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.GoogleStorageBackend,
+				Google: &aimlv1beta1.GoogleStorageOptions{
+					Bucket:             "pachyderm",
+					ServiceAccountName: "pachd@project.iam.gserviceaccount.com",
+				},
+			},
+			failed: true,
+		},
+		{
+			name: "microsoft workload identity",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.MicrosoftStorageBackend,
+				Microsoft: &aimlv1beta1.MicrosoftStorageOptions{
+					Container:        "pachyderm",
+					ID:               "account",
+					WorkloadIdentity: &aimlv1beta1.AzureWorkloadIdentity{},
+				},
+			},
+			failed: true,
+		},
+		{
+			name: "missing options",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.MinioStorageBackend,
+			},
+			failed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := &aimlv1beta1.Pachyderm{}
+			pd.Spec.Pachd.Storage = test.storage
+
+			remote, err := storageRemote(pd, migrationSourceMountPath)
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrMigrationFailed) {
+					t.Fatalf("expected error %v, got %v", ErrMigrationFailed, err)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(remote.options, test.options) {
+				t.Errorf("expected options %v, got %v", test.options, remote.options)
+			}
+			if remote.path != test.path || remote.location != test.location {
+				t.Errorf("expected path %s at %s, got %s at %s", test.path, test.location, remote.path, remote.location)
+			}
+			if remote.insecure != test.insecure {
+				t.Errorf("expected insecure %t", test.insecure)
+			}
+			if !reflect.DeepEqual(remote.volume, test.volume) {
+				t.Errorf("expected volume %+v, got %+v", test.volume, remote.volume)
+			}
+		})
+	}
+}
+
+func TestMigrationResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []corev1.ContainerStatus
+		expected *migrationJobResult
+		failed   bool
+	}{
+		{
+			name: "object counts",
+			statuses: []corev1.ContainerStatus{
+				{
+					Name: "verify",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							Message: `{"source":{"count":3,"bytes":1024},"destination":{"count":4,"bytes":2048}}`,
+						},
+					},
+				},
+			},
+			expected: &migrationJobResult{
+				Source:      migrationBucketSize{Count: 3, Bytes: 1024},
+				Destination: migrationBucketSize{Count: 4, Bytes: 2048},
+			},
+		},
+		{
+			name: "output of the container logs",
+			statuses: []corev1.ContainerStatus{
+				{
+					Name: "verify",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							Message: "ERROR : 2 differences found",
+						},
+					},
+				},
+			},
+			failed: true,
+		},
+		{
+			name: "verify container running",
+			statuses: []corev1.ContainerStatus{
+				{
+					Name: "verify",
+					State: corev1.ContainerState{
+						Running: &corev1.ContainerStateRunning{},
+					},
+				},
+			},
+			failed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				Status: corev1.PodStatus{
+					ContainerStatuses: test.statuses,
+				},
+			}
+
+			result, err := migrationResult(pod)
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrMigrationFailed) {
+					t.Fatalf("expected error %v, got %v", ErrMigrationFailed, err)
+				}
+				return
+			}
+
+			if *result != *test.expected {
+				t.Fatalf("expected result %+v, got %+v", test.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/destinations"
+	"github.com/pachyderm/openshift-operator/controllers/generators"
+)
+
+const (
+	// object written to the bucket by the storage pre-flight check
+	storageProbeKey string = ".pachyderm-operator-probe"
+	// time allowed for the storage pre-flight check
+	storageProbeTimeout time.Duration = 30 * time.Second
+)
+
+// storageProbeDestination returns a client for the object store
+// configured for pachd. It returns nil if the object store
+// is only reachable with credentials or volumes of the pachd pods
+func storageProbeDestination(ctx context.Context, pd *aimlv1beta1.Pachyderm) (destinations.Destination, error) {
+	storage := pd.Spec.Pachd.Storage
+
+	switch storage.Backend {
+	case aimlv1beta1.AmazonStorageBackend:
+		if storage.Amazon == nil || pd.IsUsingAmazonIAMRole() {
+			return nil, nil
+		}
+		return destinations.NewS3(destinations.S3Options{
+			Bucket:             storage.Amazon.Bucket,
+			Region:             storage.Amazon.Region,
+			AccessID:           storage.Amazon.ID,
+			AccessSecret:       storage.Amazon.Secret,
+			SessionToken:       storage.Amazon.Token,
+			Endpoint:           storage.Amazon.CustomEndpoint,
+			DisableSSL:         storage.Amazon.DisableSSL,
+			InsecureSkipVerify: !storage.Amazon.IsVerifyingSSL(),
+		})
+	case aimlv1beta1.MinioStorageBackend:
+		if storage.Minio == nil {
+			return nil, nil
+		}
+		scheme := "http"
+		if storage.Minio.IsSecure() {
+			scheme = "https"
+		}
+		return destinations.NewS3(destinations.S3Options{
+			Bucket:       storage.Minio.Bucket,
+			Region:       "us-east-1",
+			AccessID:     storage.Minio.ID,
+			AccessSecret: storage.Minio.Secret,
+			Endpoint:     fmt.Sprintf("%s://%s", scheme, storage.Minio.Endpoint),
+			DisableSSL:   !storage.Minio.IsSecure(),
+			CABundle:     storage.Minio.CABundleData,
+		})
+	case aimlv1beta1.CustomStorageBackend:
+		if storage.Custom == nil {
+			return nil, nil
+		}
+		scheme := "https"
+		if storage.Custom.DisableSSL {
+			scheme = "http"
+		}
+		return destinations.NewS3(destinations.S3Options{
+			Bucket:             storage.Custom.Bucket,
+			Region:             storage.Custom.Region,
+			AccessID:           storage.Custom.ID,
+			AccessSecret:       storage.Custom.Secret,
+			Endpoint:           fmt.Sprintf("%s://%s", scheme, storage.Custom.Endpoint),
+			DisableSSL:         storage.Custom.DisableSSL,
+			InsecureSkipVerify: storage.Custom.InsecureSkipVerify,
+			CABundle:           storage.Custom.CABundleData,
+		})
+	case aimlv1beta1.GoogleStorageBackend:
+		if storage.Google == nil || pd.IsUsingGCSWorkloadIdentity() {
+			return nil, nil
+		}
+		return destinations.NewGCS(ctx, destinations.GCSOptions{
+			Bucket:          storage.Google.Bucket,
+			CredentialsJSON: storage.Google.CredentialsData,
+		})
+	case aimlv1beta1.MicrosoftStorageBackend:
+		if storage.Microsoft == nil || pd.IsUsingAzureWorkloadIdentity() {
+			return nil, nil
+		}
+		return destinations.NewAzure(destinations.AzureOptions{
+			Container:   storage.Microsoft.Container,
+			AccountName: storage.Microsoft.ID,
+			AccountKey:  storage.Microsoft.Secret,
+		})
+	}
+
+	return nil, nil
+}
+
+// probeObjectStorage writes, reads back and deletes a small object
+func probeObjectStorage(ctx context.Context, destination destinations.Destination) error {
+	content := []byte(fmt.Sprintf("pachyderm operator storage check %d", time.Now().UnixNano()))
+
+	if err := destination.Upload(ctx, storageProbeKey, bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("unable to write %s: %w", destination.Location(storageProbeKey), err)
+	}
+
+	body, err := destination.Download(ctx, storageProbeKey)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", destination.Location(storageProbeKey), err)
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", destination.Location(storageProbeKey), err)
+	}
+	if !bytes.Equal(data, content) {
+		return fmt.Errorf("content of %s does not match the object written", destination.Location(storageProbeKey))
+	}
+
+	if deleter, ok := destination.(destinations.Deleter); ok {
+		if err := deleter.Delete(ctx, storageProbeKey); err != nil {
+			return fmt.Errorf("unable to delete %s: %w", destination.Location(storageProbeKey), err)
+		}
+	}
+
+	return nil
+}
+
+// checkObjectStorage runs the storage pre-flight check and reports
+// the result in the StorageReachable condition. The check is repeated
+// when the resource or the credentials it references change
+func (r *PachydermReconciler) checkObjectStorage(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	if pd.IsDeleted() {
+		return nil
+	}
+
+	key := client.ObjectKeyFromObject(pd)
+	hash := generators.ConfigHash(pd)
+	if probed, ok := r.storageProbes.Load(key); ok && probed == hash {
+		condition := meta.FindStatusCondition(pd.Status.Conditions, aimlv1beta1.ConditionStorageReachable)
+		if condition != nil && condition.ObservedGeneration == pd.Generation {
+			return nil
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               aimlv1beta1.ConditionStorageReachable,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ProbeSucceeded",
+		Message:            "object storage is readable and writable",
+		ObservedGeneration: pd.Generation,
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, storageProbeTimeout)
+	defer cancel()
+
+	destination, err := storageProbeDestination(probeCtx, pd)
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "InvalidConfiguration"
+		condition.Message = err.Error()
+	case destination == nil:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "ProbeSkipped"
+		condition.Message = "object storage is only reachable from the pachd pods"
+	default:
+		if err := probeObjectStorage(probeCtx, destination); err != nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "ProbeFailed"
+			condition.Message = err.Error()
+		}
+	}
+
+	current := pd.DeepCopy()
+	meta.SetStatusCondition(&pd.Status.Conditions, condition)
+	if err := r.Status().Patch(ctx, pd, client.MergeFrom(current)); err != nil {
+		return err
+	}
+
+	if condition.Status == metav1.ConditionFalse {
+		r.storageProbes.Delete(key)
+		return fmt.Errorf("%w: %s", ErrStorageUnreachable, condition.Message)
+	}
+
+	r.storageProbes.Store(key, hash)
+	return nil
+}
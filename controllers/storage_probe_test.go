@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+// newObjectStore returns a handler storing
+// objects in memory under the request path
+func newObjectStore() http.Handler {
+	var lock sync.Mutex
+	objects := map[string][]byte{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := ioutil.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			object, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(object)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func TestProbeObjectStorage(t *testing.T) {
+	plaintext := httptest.NewServer(newObjectStore())
+	defer plaintext.Close()
+	selfSigned := httptest.NewTLSServer(newObjectStore())
+	defer selfSigned.Close()
+
+	verifySSL := false
+
+	tests := []struct {
+		name    string
+		storage aimlv1beta1.ObjectStorageOptions
+		skipped bool
+		failed  bool
+	}{
+		{
+			name: "minio",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.MinioStorageBackend,
+				Minio: &aimlv1beta1.MinioStorageOptions{
+					Bucket:   "pachyderm",
+					Endpoint: strings.TrimPrefix(plaintext.URL, "http://"),
+					ID:       "access",
+					Secret:   "secret",
+				},
+			},
+		},
+		{
+			name: "amazon verifies certificates by default",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.AmazonStorageBackend,
+				Amazon: &aimlv1beta1.AmazonStorageOptions{
+					Bucket:         "pachyderm",
+					Region:         "us-east-1",
+					ID:             "access",
+					Secret:         "secret",
+					CustomEndpoint: selfSigned.URL,
+				},
+			},
+			failed: true,
+		},
+		{
+			name: "amazon without certificate verification",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.AmazonStorageBackend,
+				Amazon: &aimlv1beta1.AmazonStorageOptions{
+					Bucket:         "pachyderm",
+					Region:         "us-east-1",
+					ID:             "access",
+					Secret:         "secret",
+					CustomEndpoint: selfSigned.URL,
+					VerifySSL:      &verifySSL,
+				},
+			},
+		},
+		{
+			name: "custom verifies certificates by default",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.CustomStorageBackend,
+				Custom: &aimlv1beta1.CustomStorageOptions{
+					Bucket:   "pachyderm",
+					Region:   "us-east-1",
+					Endpoint: strings.TrimPrefix(selfSigned.URL, "https://"),
+					ID:       "access",
+					Secret:   "secret",
+				},
+			},
+			failed: true,
+		},
+		{
+			name: "amazon iam role",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.AmazonStorageBackend,
+				Amazon: &aimlv1beta1.AmazonStorageOptions{
+					Bucket:  "pachyderm",
+					IAMRole: "arn:aws:iam::123456789012:role/pachyderm",
+				},
+			},
+			skipped: true,
+		},
+		{
+			name: "local",
+			storage: aimlv1beta1.ObjectStorageOptions{
+				Backend: aimlv1beta1.LocalStorageBackend,
+			},
+			skipped: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pd := &aimlv1beta1.Pachyderm{}
+			pd.Spec.Pachd.Storage = test.storage
+
+			ctx := context.Background()
+			destination, err := storageProbeDestination(ctx, pd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if skipped := destination == nil; skipped != test.skipped {
+				t.Fatalf("expected skipped %t", test.skipped)
+			}
+			if destination == nil {
+				return
+			}
+
+			err = probeObjectStorage(ctx, destination)
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+		})
+	}
+}
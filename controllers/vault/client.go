@@ -0,0 +1,227 @@
+// Package vault retrieves dynamic object storage
+// credentials from a HashiCorp Vault server
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNoAuth is returned when a request is made before logging in
+var ErrNoAuth = errors.New("vault client is not authenticated")
+
+// Client is a minimal client for the Vault HTTP API
+type Client struct {
+	address    string
+	token      string
+	httpClient *http.Client
+}
+
+// Lease describes the lifetime of a vault secret
+type Lease struct {
+	ID        string
+	Duration  time.Duration
+	Renewable bool
+}
+
+// AWSCredentials are the credentials issued
+// by the vault AWS secrets engine
+type AWSCredentials struct {
+	AccessKey     string
+	SecretKey     string
+	SecurityToken string
+	Lease         Lease
+}
+
+type secretResponse struct {
+	LeaseID       string          `json:"lease_id"`
+	LeaseDuration int             `json:"lease_duration"`
+	Renewable     bool            `json:"renewable"`
+	Data          json.RawMessage `json:"data"`
+	Auth          *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+}
+
+type errorResponse struct {
+	Errors []string `json:"errors"`
+}
+
+// New returns a vault client for the server at address
+func New(address string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &Client{
+		address:    strings.TrimSuffix(address, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// NewHTTPClient returns an HTTP client verifying the vault server
+// certificate with the system and caBundle certificate authorities.
+// A non-empty serverName replaces the host of the address during
+// certificate verification
+func NewHTTPClient(caBundle []byte, serverName string) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if len(caBundle) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, errors.New("no certificates found in CA bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   30 * time.Second,
+	}, nil
+}
+
+// SetToken sets the vault token used to authenticate requests
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// LoginKubernetes exchanges a service account token for a vault
+// token using the kubernetes auth method mounted at mount
+func (c *Client) LoginKubernetes(ctx context.Context, mount, role, jwt string) error {
+	request := map[string]string{
+		"role": role,
+		"jwt":  jwt,
+	}
+
+	response, err := c.do(ctx, http.MethodPost, fmt.Sprintf("auth/%s/login", mount), request)
+	if err != nil {
+		return err
+	}
+
+	if response.Auth == nil || response.Auth.ClientToken == "" {
+		return errors.New("vault login returned no client token")
+	}
+	c.token = response.Auth.ClientToken
+
+	return nil
+}
+
+// AWSCredentials generates credentials for role
+// from the AWS secrets engine mounted at mount
+func (c *Client) AWSCredentials(ctx context.Context, mount, role string) (*AWSCredentials, error) {
+	if c.token == "" {
+		return nil, ErrNoAuth
+	}
+
+	response, err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/creds/%s", mount, role), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data := struct {
+		AccessKey     string `json:"access_key"`
+		SecretKey     string `json:"secret_key"`
+		SecurityToken string `json:"security_token"`
+	}{}
+	if err := json.Unmarshal(response.Data, &data); err != nil {
+		return nil, err
+	}
+
+	return &AWSCredentials{
+		AccessKey:     data.AccessKey,
+		SecretKey:     data.SecretKey,
+		SecurityToken: data.SecurityToken,
+		Lease:         response.lease(),
+	}, nil
+}
+
+// RenewLease extends the lease of a secret by increment
+func (c *Client) RenewLease(ctx context.Context, leaseID string, increment time.Duration) (*Lease, error) {
+	if c.token == "" {
+		return nil, ErrNoAuth
+	}
+
+	request := map[string]interface{}{
+		"lease_id": leaseID,
+	}
+	// vault applies the default TTL of the secret without an increment
+	if increment > 0 {
+		request["increment"] = int(increment.Seconds())
+	}
+
+	response, err := c.do(ctx, http.MethodPut, "sys/leases/renew", request)
+	if err != nil {
+		return nil, err
+	}
+
+	lease := response.lease()
+	return &lease, nil
+}
+
+func (r *secretResponse) lease() Lease {
+	return Lease{
+		ID:        r.LeaseID,
+		Duration:  time.Duration(r.LeaseDuration) * time.Second,
+		Renewable: r.Renewable,
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) (*secretResponse, error) {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/v1/%s", c.address, path), payload)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		failure := &errorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(failure); err == nil && len(failure.Errors) > 0 {
+			return nil, fmt.Errorf("vault %s %s: %s", method, path, strings.Join(failure.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault %s %s: %s", method, path, resp.Status)
+	}
+
+	response := &secretResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
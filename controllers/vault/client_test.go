@@ -0,0 +1,208 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testJWT   = "service-account-token"
+	testToken = "vault-token"
+)
+
+// newVaultServer returns a handler implementing the
+// kubernetes login, AWS credentials and lease renewal APIs
+func newVaultServer() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v1/auth/kubernetes/login", func(w http.ResponseWriter, r *http.Request) {
+		request := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request["jwt"] != testJWT {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(errorResponse{Errors: []string{"invalid jwt"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"auth": map[string]string{"client_token": testToken},
+		})
+	})
+
+	mux.HandleFunc("/v1/aws/creds/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != testToken || !strings.HasSuffix(r.URL.Path, "/pachd") {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(errorResponse{Errors: []string{"permission denied"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lease_id":       "aws/creds/pachd/lease",
+			"lease_duration": 3600,
+			"renewable":      true,
+			"data": map[string]string{
+				"access_key":     "access",
+				"secret_key":     "secret",
+				"security_token": "session",
+			},
+		})
+	})
+
+	mux.HandleFunc("/v1/sys/leases/renew", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("X-Vault-Token") != testToken {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		request := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&request)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lease_id":       request["lease_id"],
+			"lease_duration": 7200,
+			"renewable":      true,
+		})
+	})
+
+	return mux
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewTLSServer(newVaultServer())
+	defer server.Close()
+
+	caBundle := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+
+	tests := []struct {
+		name     string
+		caBundle []byte
+		// httptest certificates are issued for example.com
+		serverName string
+		jwt        string
+		role       string
+		expected   *AWSCredentials
+		err        error
+		failed     bool
+	}{
+		{
+			name:     "credentials with kubernetes login",
+			caBundle: caBundle,
+			jwt:      testJWT,
+			role:     "pachd",
+			expected: &AWSCredentials{
+				AccessKey:     "access",
+				SecretKey:     "secret",
+				SecurityToken: "session",
+				Lease: Lease{
+					ID:        "aws/creds/pachd/lease",
+					Duration:  time.Hour,
+					Renewable: true,
+				},
+			},
+		},
+		{
+			name:       "matching server name",
+			caBundle:   caBundle,
+			serverName: "example.com",
+			jwt:        testJWT,
+			role:       "pachd",
+			expected: &AWSCredentials{
+				AccessKey:     "access",
+				SecretKey:     "secret",
+				SecurityToken: "session",
+				Lease: Lease{
+					ID:        "aws/creds/pachd/lease",
+					Duration:  time.Hour,
+					Renewable: true,
+				},
+			},
+		},
+		{
+			name:       "mismatched server name",
+			caBundle:   caBundle,
+			serverName: "vault.example.org",
+			jwt:        testJWT,
+			role:       "pachd",
+			failed:     true,
+		},
+		{
+			name:   "untrusted certificate authority",
+			jwt:    testJWT,
+			role:   "pachd",
+			failed: true,
+		},
+		{
+			name:     "rejected login",
+			caBundle: caBundle,
+			jwt:      "expired",
+			role:     "pachd",
+			failed:   true,
+		},
+		{
+			name:     "unauthenticated",
+			caBundle: caBundle,
+			role:     "pachd",
+			err:      ErrNoAuth,
+			failed:   true,
+		},
+		{
+			name:     "denied role",
+			caBundle: caBundle,
+			jwt:      testJWT,
+			role:     "admin",
+			failed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			httpClient, err := NewHTTPClient(test.caBundle, test.serverName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := New(server.URL+"/", httpClient)
+
+			ctx := context.Background()
+			credentials, err := func() (*AWSCredentials, error) {
+				if test.jwt != "" {
+					if err := client.LoginKubernetes(ctx, "kubernetes", "pachd", test.jwt); err != nil {
+						return nil, err
+					}
+				}
+				return client.AWSCredentials(ctx, "aws", test.role)
+			}()
+			if failed := err != nil; failed != test.failed {
+				t.Fatalf("expected failed %t, got %v", test.failed, err)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if *credentials != *test.expected {
+				t.Fatalf("expected credentials %+v, got %+v", test.expected, credentials)
+			}
+
+			lease, err := client.RenewLease(ctx, credentials.Lease.ID, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lease.ID != credentials.Lease.ID || lease.Duration != 2*time.Hour {
+				t.Fatalf("unexpected renewed lease %+v", lease)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	if _, err := NewHTTPClient([]byte("not a certificate"), ""); err == nil {
+		t.Fatal("expected invalid CA bundle to fail")
+	}
+}
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+	"github.com/pachyderm/openshift-operator/controllers/vault"
+)
+
+const (
+	// secret rendered by the pachyderm chart with the object storage credentials
+	storageSecretName string = "pachyderm-storage-secret"
+	// service account used by pachd to authenticate with vault
+	pachdServiceAccountName string = "pachyderm"
+	// lifetime of the service account token exchanged for a vault token
+	vaultLoginTokenExpiry int64 = 600
+)
+
+// vaultCredentials sets the S3 access keys used by pachd from the vault
+// AWS secrets engine. Credentials already written to the storage secret
+// are reused until the lease is due, when it is renewed or replaced
+func (r *PachydermReconciler) vaultCredentials(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	amazon := pd.Spec.Pachd.Storage.Amazon
+	settings := amazon.Vault
+	lease := pd.Status.VaultLease
+	now := time.Now()
+
+	current, err := r.storageSecretCredentials(ctx, pd)
+	if err != nil {
+		return err
+	}
+
+	if current != nil && lease != nil &&
+		(lease.RenewTime == nil || now.Before(lease.RenewTime.Time)) {
+		setVaultCredentials(amazon, current)
+		return nil
+	}
+
+	client, err := r.vaultClient(ctx, pd)
+	if err != nil {
+		return err
+	}
+
+	if current != nil && lease != nil && lease.Renewable &&
+		lease.ExpireTime != nil && now.Before(lease.ExpireTime.Time) {
+		renewed, err := client.RenewLease(ctx, lease.ID, 0)
+		if err == nil {
+			if renewed.ID == "" {
+				renewed.ID = lease.ID
+			}
+			if err := r.updateVaultLease(ctx, pd, renewed, now); err != nil {
+				return err
+			}
+			setVaultCredentials(pd.Spec.Pachd.Storage.Amazon, current)
+			return nil
+		}
+		r.Log.Info("unable to renew vault lease, requesting new credentials", "lease", lease.ID, "error", err.Error())
+	}
+
+	mount := settings.AWSMountPath
+	if mount == "" {
+		mount = "aws"
+	}
+	credentials, err := client.AWSCredentials(ctx, mount, settings.Role)
+	if err != nil {
+		return err
+	}
+	if err := r.updateVaultLease(ctx, pd, &credentials.Lease, now); err != nil {
+		return err
+	}
+	// the status patch decodes the stored resource without credentials
+	setVaultCredentials(pd.Spec.Pachd.Storage.Amazon, credentials)
+
+	return nil
+}
+
+func setVaultCredentials(amazon *aimlv1beta1.AmazonStorageOptions, credentials *vault.AWSCredentials) {
+	amazon.ID = credentials.AccessKey
+	amazon.Secret = credentials.SecretKey
+	amazon.Token = credentials.SecurityToken
+}
+
+// storageSecretCredentials returns the credentials currently used by pachd
+func (r *PachydermReconciler) storageSecretCredentials(ctx context.Context, pd *aimlv1beta1.Pachyderm) (*vault.AWSCredentials, error) {
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{
+		Name:      storageSecretName,
+		Namespace: pd.Namespace,
+	}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if len(secret.Data["AMAZON_ID"]) == 0 {
+		return nil, nil
+	}
+
+	return &vault.AWSCredentials{
+		AccessKey:     string(secret.Data["AMAZON_ID"]),
+		SecretKey:     string(secret.Data["AMAZON_SECRET"]),
+		SecurityToken: string(secret.Data["AMAZON_TOKEN"]),
+	}, nil
+}
+
+// vaultClient returns a vault client authenticated
+// with the method configured in the pachyderm resource
+func (r *PachydermReconciler) vaultClient(ctx context.Context, pd *aimlv1beta1.Pachyderm) (*vault.Client, error) {
+	settings := pd.Spec.Pachd.Storage.Amazon.Vault
+
+	var httpClient *http.Client
+	if settings.CABundle != nil || settings.TLSServerName != "" {
+		if settings.CABundle != nil {
+			bundle, err := r.caBundle(ctx, pd.Namespace, settings.CABundle)
+			if err != nil {
+				return nil, err
+			}
+			settings.CABundleData = bundle
+		}
+
+		var err error
+		httpClient, err = vault.NewHTTPClient(settings.CABundleData, settings.TLSServerName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client := vault.New(settings.Address, httpClient)
+
+	switch {
+	case settings.Kubernetes != nil:
+		jwt, err := r.serviceAccountToken(ctx, pd.Namespace, pachdServiceAccountName, settings.Kubernetes.Audience)
+		if err != nil {
+			return nil, err
+		}
+
+		mount := settings.Kubernetes.MountPath
+		if mount == "" {
+			mount = "kubernetes"
+		}
+		if err := client.LoginKubernetes(ctx, mount, settings.Kubernetes.Role, jwt); err != nil {
+			return nil, err
+		}
+	case settings.TokenSecretName != "":
+		tokenSecret := &corev1.Secret{}
+		tokenSecretKey := types.NamespacedName{
+			Name:      settings.TokenSecretName,
+			Namespace: pd.Namespace,
+		}
+		if err := r.Get(ctx, tokenSecretKey, tokenSecret); err != nil {
+			return nil, err
+		}
+
+		token, ok := tokenSecret.Data["token"]
+		if !ok {
+			return nil, NewKeyError(
+				fmt.Sprintf("the key %s missing in secret %s",
+					"token",
+					tokenSecretKey.Name),
+			)
+		}
+		client.SetToken(string(token))
+	default:
+		client.SetToken(settings.Token)
+	}
+
+	return client, nil
+}
+
+// serviceAccountToken requests a short lived token for the service account
+func (r *PachydermReconciler) serviceAccountToken(ctx context.Context, namespace, name, audience string) (string, error) {
+	clientset, err := kubernetes.NewForConfig(r.Config)
+	if err != nil {
+		return "", err
+	}
+
+	expiry := vaultLoginTokenExpiry
+	request := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expiry,
+		},
+	}
+	if audience != "" {
+		request.Spec.Audiences = []string{audience}
+	}
+
+	token, err := clientset.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, name, request, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return token.Status.Token, nil
+}
+
+// updateVaultLease records the lease in the pachyderm status.
+// Credentials are refreshed after two thirds of the lease duration
+func (r *PachydermReconciler) updateVaultLease(ctx context.Context, pd *aimlv1beta1.Pachyderm, lease *vault.Lease, issued time.Time) error {
+	current := pd.DeepCopy()
+
+	status := &aimlv1beta1.VaultLeaseStatus{
+		ID:        lease.ID,
+		Renewable: lease.Renewable,
+	}
+	if lease.Duration > 0 {
+		renewTime := metav1.NewTime(issued.Add(lease.Duration * 2 / 3))
+		expireTime := metav1.NewTime(issued.Add(lease.Duration))
+		status.RenewTime = &renewTime
+		status.ExpireTime = &expireTime
+	}
+	pd.Status.VaultLease = status
+
+	return r.Status().Patch(ctx, pd, client.MergeFrom(current))
+}
+
+// vaultRenewAfter returns the time until the
+// vault credentials used by pachd must be refreshed
+func vaultRenewAfter(pd *aimlv1beta1.Pachyderm) (time.Duration, bool) {
+	if !pd.IsUsingVault() || pd.IsDeleted() {
+		return 0, false
+	}
+
+	lease := pd.Status.VaultLease
+	if lease == nil || lease.RenewTime == nil {
+		return 0, false
+	}
+
+	renewAfter := time.Until(lease.RenewTime.Time)
+	if renewAfter < time.Second {
+		renewAfter = time.Second
+	}
+
+	return renewAfter, true
+}
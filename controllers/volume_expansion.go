@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aimlv1beta1 "github.com/pachyderm/openshift-operator/api/v1beta1"
+)
+
+// expandVolumes grows the persistent volume claims of the etcd and
+// postgresql statefulsets to the requested storage size. The volume
+// claim templates of a statefulset are immutable, so the claims of
+// each replica are patched directly and resized online by the storage
+// driver. The size of every claim is reported in the status
+func (r *PachydermReconciler) expandVolumes(ctx context.Context, pd *aimlv1beta1.Pachyderm) error {
+	if pd.IsDeleted() {
+		return nil
+	}
+
+	targets := map[string]string{
+		"etcd": pd.EtcdStorageSize(),
+	}
+	// the postgres operator expands the volumes of a PostgresCluster
+	if pd.DeployPostgres() && !pd.IsPostgresHA() {
+		targets["postgres"] = pd.PostgresStorageSize()
+	}
+
+	volumes := []aimlv1beta1.VolumeStatus{}
+	unsupported := []string{}
+	for _, name := range []string{"etcd", "postgres"} {
+		size, ok := targets[name]
+		if !ok || size == "" {
+			continue
+		}
+		requested, err := resource.ParseQuantity(size)
+		if err != nil {
+			return err
+		}
+
+		claims, err := r.statefulSetClaims(ctx, pd.Namespace, name)
+		if err != nil {
+			return err
+		}
+
+		for _, claim := range claims {
+			status, err := r.expandClaim(ctx, claim, requested)
+			if err != nil {
+				if !goerrors.Is(err, ErrVolumeExpansionNotSupported) {
+					return err
+				}
+				unsupported = append(unsupported, claim.Name)
+			}
+			volumes = append(volumes, status)
+		}
+	}
+
+	resizing := []string{}
+	for _, volume := range volumes {
+		if volume.Resizing {
+			resizing = append(resizing, volume.ClaimName)
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               aimlv1beta1.ConditionVolumesExpanded,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Expanded",
+		Message:            "volumes match the requested storage size",
+		ObservedGeneration: pd.Generation,
+	}
+	switch {
+	case len(unsupported) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ExpansionNotSupported"
+		condition.Message = fmt.Sprintf("the storage class does not allow volume expansion: %s", strings.Join(unsupported, ", "))
+	case len(resizing) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Resizing"
+		condition.Message = fmt.Sprintf("waiting for volumes to be resized: %s", strings.Join(resizing, ", "))
+	}
+
+	current := pd.DeepCopy()
+	pd.Status.Volumes = volumes
+	meta.SetStatusCondition(&pd.Status.Conditions, condition)
+	if !reflect.DeepEqual(current.Status, pd.Status) {
+		if err := r.Status().Patch(ctx, pd, client.MergeFrom(current)); err != nil {
+			return err
+		}
+	}
+
+	if len(resizing) > 0 {
+		return fmt.Errorf("%w: %s", ErrVolumesResizing, strings.Join(resizing, ", "))
+	}
+
+	return nil
+}
+
+// statefulSetClaims returns the persistent volume
+// claims created for the replicas of a statefulset
+func (r *PachydermReconciler) statefulSetClaims(ctx context.Context, namespace, name string) ([]*corev1.PersistentVolumeClaim, error) {
+	sts := &appsv1.StatefulSet{}
+	stsKey := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	if err := r.Get(ctx, stsKey, sts); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+
+	claims := []*corev1.PersistentVolumeClaim{}
+	for _, template := range sts.Spec.VolumeClaimTemplates {
+		for ordinal := int32(0); ordinal < replicas; ordinal++ {
+			claim := &corev1.PersistentVolumeClaim{}
+			claimKey := types.NamespacedName{
+				Name:      fmt.Sprintf("%s-%s-%d", template.Name, sts.Name, ordinal),
+				Namespace: namespace,
+			}
+			if err := r.Get(ctx, claimKey, claim); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			claims = append(claims, claim)
+		}
+	}
+
+	return claims, nil
+}
+
+// expandClaim requests the size of a persistent volume claim to
+// be increased, if its storage class allows volume expansion
+func (r *PachydermReconciler) expandClaim(ctx context.Context, claim *corev1.PersistentVolumeClaim, requested resource.Quantity) (aimlv1beta1.VolumeStatus, error) {
+	size := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+	capacity := claim.Status.Capacity[corev1.ResourceStorage]
+	status := aimlv1beta1.VolumeStatus{
+		ClaimName: claim.Name,
+		Requested: requested.String(),
+		Capacity:  capacity.String(),
+	}
+
+	if requested.Cmp(size) > 0 {
+		expandable, err := r.allowsVolumeExpansion(ctx, claim)
+		if err != nil {
+			return status, err
+		}
+		if !expandable {
+			return status, fmt.Errorf("%w: %s", ErrVolumeExpansionNotSupported, claim.Name)
+		}
+
+		patch := client.MergeFrom(claim.DeepCopy())
+		claim.Spec.Resources.Requests[corev1.ResourceStorage] = requested
+		if err := r.Patch(ctx, claim, patch); err != nil {
+			return status, err
+		}
+		size = requested
+	}
+
+	status.Resizing = capacity.Cmp(size) < 0
+	return status, nil
+}
+
+// allowsVolumeExpansion returns true if the storage class
+// of the claim allows its volumes to be expanded
+func (r *PachydermReconciler) allowsVolumeExpansion(ctx context.Context, claim *corev1.PersistentVolumeClaim) (bool, error) {
+	if claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName == "" {
+		return false, nil
+	}
+
+	sc := &storagev1.StorageClass{}
+	if err := r.Get(ctx, types.NamespacedName{Name: *claim.Spec.StorageClassName}, sc); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
+}